@@ -0,0 +1,179 @@
+package dbhandler
+
+import (
+	"UserStorage/models"
+	"context"
+	"errors"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+	"sync"
+)
+
+type APIKeyStore interface {
+	CreateAPIKey(ctx context.Context, key models.APIKey) error
+	FindAPIKey(ctx context.Context, hash string) (models.APIKey, error)
+	GetAPIKey(ctx context.Context, id string) (models.APIKey, error)
+	ListAPIKeys(ctx context.Context, userID string) ([]models.APIKey, error)
+	DeleteAPIKey(ctx context.Context, userID string, id string) error
+	DeleteUserAPIKeys(ctx context.Context, userID string) error
+}
+
+type MongoAPIKeyStore struct {
+	coll *mongo.Collection
+}
+
+func NewMongoAPIKeyStore(db *mongo.Database) *MongoAPIKeyStore {
+	col := db.Collection("apikeys")
+	_, err := col.Indexes().CreateMany(context.Background(), []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "hash", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		},
+		{
+			Keys:    bson.D{{Key: "expiresAt", Value: 1}},
+			Options: options.Index().SetExpireAfterSeconds(0),
+		},
+		{
+			Keys: bson.D{{Key: "userID", Value: 1}},
+		},
+	})
+	if err != nil {
+		panic(err)
+	}
+	return &MongoAPIKeyStore{col}
+}
+
+func (m MongoAPIKeyStore) CreateAPIKey(ctx context.Context, key models.APIKey) error {
+	_, err := m.coll.InsertOne(ctx, key)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (m MongoAPIKeyStore) FindAPIKey(ctx context.Context, hash string) (models.APIKey, error) {
+	var key models.APIKey
+	err := m.coll.FindOne(ctx, bson.M{"hash": hash}).Decode(&key)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return models.APIKey{}, ErrNotFound
+	}
+	if err != nil {
+		return models.APIKey{}, err
+	}
+	return key, nil
+}
+
+func (m MongoAPIKeyStore) GetAPIKey(ctx context.Context, id string) (models.APIKey, error) {
+	var key models.APIKey
+	err := m.coll.FindOne(ctx, bson.M{"_id": id}).Decode(&key)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return models.APIKey{}, ErrNotFound
+	}
+	if err != nil {
+		return models.APIKey{}, err
+	}
+	return key, nil
+}
+
+func (m MongoAPIKeyStore) ListAPIKeys(ctx context.Context, userID string) ([]models.APIKey, error) {
+	cur, err := m.coll.Find(ctx, bson.M{"userID": userID})
+	if err != nil {
+		return nil, err
+	}
+	keys := []models.APIKey{}
+	if err = cur.All(ctx, &keys); err != nil {
+		return nil, err
+	}
+	return keys, nil
+}
+
+func (m MongoAPIKeyStore) DeleteAPIKey(ctx context.Context, userID string, id string) error {
+	res, err := m.coll.DeleteOne(ctx, bson.M{"_id": id, "userID": userID})
+	if err != nil {
+		return err
+	}
+	if res.DeletedCount == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (m MongoAPIKeyStore) DeleteUserAPIKeys(ctx context.Context, userID string) error {
+	_, err := m.coll.DeleteMany(ctx, bson.M{"userID": userID})
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+type MemoryAPIKeyStore struct {
+	mu   sync.Mutex
+	keys map[string]models.APIKey
+}
+
+func NewMemoryAPIKeyStore() *MemoryAPIKeyStore {
+	return &MemoryAPIKeyStore{keys: map[string]models.APIKey{}}
+}
+
+func (m *MemoryAPIKeyStore) CreateAPIKey(_ context.Context, key models.APIKey) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.keys[key.ID] = key
+	return nil
+}
+
+func (m *MemoryAPIKeyStore) FindAPIKey(_ context.Context, hash string) (models.APIKey, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, key := range m.keys {
+		if key.Hash == hash {
+			return key, nil
+		}
+	}
+	return models.APIKey{}, ErrNotFound
+}
+
+func (m *MemoryAPIKeyStore) GetAPIKey(_ context.Context, id string) (models.APIKey, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	key, ok := m.keys[id]
+	if !ok {
+		return models.APIKey{}, ErrNotFound
+	}
+	return key, nil
+}
+
+func (m *MemoryAPIKeyStore) ListAPIKeys(_ context.Context, userID string) ([]models.APIKey, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	keys := []models.APIKey{}
+	for _, key := range m.keys {
+		if key.UserID == userID {
+			keys = append(keys, key)
+		}
+	}
+	return keys, nil
+}
+
+func (m *MemoryAPIKeyStore) DeleteAPIKey(_ context.Context, userID string, id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	key, ok := m.keys[id]
+	if !ok || key.UserID != userID {
+		return ErrNotFound
+	}
+	delete(m.keys, id)
+	return nil
+}
+
+func (m *MemoryAPIKeyStore) DeleteUserAPIKeys(_ context.Context, userID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for id, key := range m.keys {
+		if key.UserID == userID {
+			delete(m.keys, id)
+		}
+	}
+	return nil
+}
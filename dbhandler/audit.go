@@ -0,0 +1,349 @@
+package dbhandler
+
+import (
+	"UserStorage/models"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+	"sync"
+	"time"
+)
+
+const auditAppendAttempts = 10
+
+type AuditStore interface {
+	AppendAudit(ctx context.Context, entry models.AuditEntry) (models.AuditEntry, error)
+	QueryAudit(ctx context.Context, query models.AuditQuery) ([]models.AuditEntry, error)
+	EachAudit(ctx context.Context, fn func(models.AuditEntry) error) error
+	AuditSubject(ctx context.Context, userID string, create bool) (string, error)
+	AuditKey(ctx context.Context, subject string) ([]byte, error)
+	ForgetAuditSubject(ctx context.Context, userID string) error
+}
+
+type auditSubject struct {
+	UserID  string `bson:"_id"`
+	Subject string `bson:"subject"`
+	Key     []byte `bson:"key"`
+}
+
+func newAuditSubject() (string, error) {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return "sub_" + hex.EncodeToString(buf), nil
+}
+
+func newAuditKey() ([]byte, error) {
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+func AuditHash(entry models.AuditEntry) string {
+	entry.Hash = ""
+	raw, _ := json.Marshal(entry)
+	sum := sha256.Sum256(raw)
+	return hex.EncodeToString(sum[:])
+}
+
+func chainAudit(entry models.AuditEntry, last models.AuditEntry) models.AuditEntry {
+	entry.Seq = last.Seq + 1
+	entry.PrevHash = last.Hash
+	entry.Timestamp = entry.Timestamp.UTC().Truncate(time.Millisecond)
+	entry.Hash = AuditHash(entry)
+	return entry
+}
+
+func VerifyAudit(ctx context.Context, store AuditStore) (models.AuditVerification, error) {
+	var last models.AuditEntry
+	result := models.AuditVerification{Valid: true}
+	errBroken := errors.New("audit chain broken")
+	err := store.EachAudit(ctx, func(entry models.AuditEntry) error {
+		if entry.Seq != last.Seq+1 || entry.PrevHash != last.Hash || entry.Hash != AuditHash(entry) {
+			result.Valid = false
+			result.BrokenAt = last.Seq + 1
+			return errBroken
+		}
+		result.Checked++
+		last = entry
+		return nil
+	})
+	if err != nil && !errors.Is(err, errBroken) {
+		return models.AuditVerification{}, err
+	}
+	return result, nil
+}
+
+func matchesAudit(q models.AuditQuery, entry models.AuditEntry) bool {
+	return entry.Seq > q.AfterSeq &&
+		(q.Actor == "" || entry.Actor == q.Actor) &&
+		(q.Target == "" || entry.Target == q.Target) &&
+		(q.Action == "" || entry.Action == q.Action) &&
+		(q.From.IsZero() || !entry.Timestamp.Before(q.From)) &&
+		(q.To.IsZero() || entry.Timestamp.Before(q.To))
+}
+
+type MongoAuditStore struct {
+	coll     *mongo.Collection
+	subjects *mongo.Collection
+}
+
+func NewMongoAuditStore(db *mongo.Database) *MongoAuditStore {
+	col := db.Collection("audit", options.Collection().SetBSONOptions(&options.BSONOptions{DefaultDocumentM: true}))
+	_, err := col.Indexes().CreateMany(context.Background(), []mongo.IndexModel{
+		{
+			Keys: bson.D{{Key: "target", Value: 1}, {Key: "_id", Value: 1}},
+		},
+		{
+			Keys: bson.D{{Key: "actor", Value: 1}, {Key: "_id", Value: 1}},
+		},
+	})
+	if err != nil {
+		panic(err)
+	}
+	subjects := db.Collection("audit_subjects")
+	_, err = subjects.Indexes().CreateOne(context.Background(), mongo.IndexModel{
+		Keys:    bson.D{{Key: "subject", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	})
+	if err != nil {
+		panic(err)
+	}
+	return &MongoAuditStore{col, subjects}
+}
+
+func (m MongoAuditStore) AppendAudit(ctx context.Context, entry models.AuditEntry) (models.AuditEntry, error) {
+	for range auditAppendAttempts {
+		var last models.AuditEntry
+		err := m.coll.FindOne(ctx, bson.M{}, options.FindOne().SetSort(bson.D{{Key: "_id", Value: -1}})).Decode(&last)
+		if err != nil && !errors.Is(err, mongo.ErrNoDocuments) {
+			return models.AuditEntry{}, err
+		}
+		chained := chainAudit(entry, last)
+		_, err = m.coll.InsertOne(ctx, chained)
+		if mongo.IsDuplicateKeyError(err) {
+			continue
+		}
+		if err != nil {
+			return models.AuditEntry{}, err
+		}
+		return chained, nil
+	}
+	return models.AuditEntry{}, errors.New("audit log is under contention, entry not appended")
+}
+
+func (m MongoAuditStore) QueryAudit(ctx context.Context, query models.AuditQuery) ([]models.AuditEntry, error) {
+	filter := bson.M{"_id": bson.M{"$gt": query.AfterSeq}}
+	if query.Actor != "" {
+		filter["actor"] = query.Actor
+	}
+	if query.Target != "" {
+		filter["target"] = query.Target
+	}
+	if query.Action != "" {
+		filter["action"] = query.Action
+	}
+	timestamp := bson.M{}
+	if !query.From.IsZero() {
+		timestamp["$gte"] = query.From
+	}
+	if !query.To.IsZero() {
+		timestamp["$lt"] = query.To
+	}
+	if len(timestamp) > 0 {
+		filter["timestamp"] = timestamp
+	}
+	opts := options.Find().SetSort(bson.D{{Key: "_id", Value: 1}})
+	if query.Limit > 0 {
+		opts.SetLimit(int64(query.Limit))
+	}
+	cursor, err := m.coll.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	entries := []models.AuditEntry{}
+	if err = cursor.All(ctx, &entries); err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+func (m MongoAuditStore) EachAudit(ctx context.Context, fn func(models.AuditEntry) error) error {
+	cursor, err := m.coll.Find(ctx, bson.M{}, options.Find().SetSort(bson.D{{Key: "_id", Value: 1}}))
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(ctx)
+	for cursor.Next(ctx) {
+		var entry models.AuditEntry
+		if err = cursor.Decode(&entry); err != nil {
+			return err
+		}
+		if err = fn(entry); err != nil {
+			return err
+		}
+	}
+	return cursor.Err()
+}
+
+func (m MongoAuditStore) AuditSubject(ctx context.Context, userID string, create bool) (string, error) {
+	var doc auditSubject
+	if !create {
+		err := m.subjects.FindOne(ctx, bson.M{"_id": userID}).Decode(&doc)
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return "", ErrNotFound
+		}
+		if err != nil {
+			return "", err
+		}
+		return doc.Subject, nil
+	}
+	subject, err := newAuditSubject()
+	if err != nil {
+		return "", err
+	}
+	key, err := newAuditKey()
+	if err != nil {
+		return "", err
+	}
+	err = m.subjects.FindOneAndUpdate(ctx, bson.M{"_id": userID}, bson.M{"$setOnInsert": bson.M{"subject": subject, "key": key}},
+		options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After)).Decode(&doc)
+	if err != nil {
+		return "", err
+	}
+	return doc.Subject, nil
+}
+
+func (m MongoAuditStore) AuditKey(ctx context.Context, subject string) ([]byte, error) {
+	var doc auditSubject
+	err := m.subjects.FindOne(ctx, bson.M{"subject": subject}).Decode(&doc)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(doc.Key) > 0 {
+		return doc.Key, nil
+	}
+	key, err := newAuditKey()
+	if err != nil {
+		return nil, err
+	}
+	err = m.subjects.FindOneAndUpdate(ctx, bson.M{"subject": subject}, []bson.M{{"$set": bson.M{"key": bson.M{"$ifNull": bson.A{"$key", key}}}}},
+		options.FindOneAndUpdate().SetReturnDocument(options.After)).Decode(&doc)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return doc.Key, nil
+}
+
+func (m MongoAuditStore) ForgetAuditSubject(ctx context.Context, userID string) error {
+	_, err := m.subjects.DeleteOne(ctx, bson.M{"_id": userID})
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+type MemoryAuditStore struct {
+	mu       sync.Mutex
+	entries  []models.AuditEntry
+	subjects map[string]string
+	keys     map[string][]byte
+}
+
+func NewMemoryAuditStore() *MemoryAuditStore {
+	return &MemoryAuditStore{subjects: map[string]string{}, keys: map[string][]byte{}}
+}
+
+func (m *MemoryAuditStore) AppendAudit(_ context.Context, entry models.AuditEntry) (models.AuditEntry, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var last models.AuditEntry
+	if len(m.entries) > 0 {
+		last = m.entries[len(m.entries)-1]
+	}
+	entry = chainAudit(entry, last)
+	m.entries = append(m.entries, entry)
+	return entry, nil
+}
+
+func (m *MemoryAuditStore) QueryAudit(_ context.Context, query models.AuditQuery) ([]models.AuditEntry, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	entries := []models.AuditEntry{}
+	for _, entry := range m.entries {
+		if query.Limit > 0 && len(entries) == query.Limit {
+			break
+		}
+		if matchesAudit(query, entry) {
+			entries = append(entries, entry)
+		}
+	}
+	return entries, nil
+}
+
+func (m *MemoryAuditStore) EachAudit(_ context.Context, fn func(models.AuditEntry) error) error {
+	m.mu.Lock()
+	entries := append([]models.AuditEntry(nil), m.entries...)
+	m.mu.Unlock()
+	for _, entry := range entries {
+		if err := fn(entry); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (m *MemoryAuditStore) AuditSubject(_ context.Context, userID string, create bool) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if subject, ok := m.subjects[userID]; ok {
+		return subject, nil
+	}
+	if !create {
+		return "", ErrNotFound
+	}
+	subject, err := newAuditSubject()
+	if err != nil {
+		return "", err
+	}
+	key, err := newAuditKey()
+	if err != nil {
+		return "", err
+	}
+	m.subjects[userID] = subject
+	m.keys[subject] = key
+	return subject, nil
+}
+
+func (m *MemoryAuditStore) AuditKey(_ context.Context, subject string) ([]byte, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	key, ok := m.keys[subject]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return key, nil
+}
+
+func (m *MemoryAuditStore) ForgetAuditSubject(_ context.Context, userID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.keys, m.subjects[userID])
+	delete(m.subjects, userID)
+	return nil
+}
@@ -0,0 +1,59 @@
+package dbhandler
+
+import (
+	"UserStorage/models"
+	"context"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestAuditChain(t *testing.T) {
+	store := NewMemoryAuditStore()
+	for _, target := range []string{"a@test.pl", "b@test.pl", "c@test.pl"} {
+		_, err := store.AppendAudit(context.Background(), models.AuditEntry{Timestamp: time.Now(), Action: models.AuditUserCreated, Target: target})
+		assert.NoError(t, err)
+	}
+	assert.Equal(t, store.entries[1].PrevHash, store.entries[0].Hash)
+	result, err := VerifyAudit(context.Background(), store)
+	assert.NoError(t, err)
+	assert.Equal(t, result, models.AuditVerification{Valid: true, Checked: 3})
+
+	store.entries[1].Target = "x@test.pl"
+	result, err = VerifyAudit(context.Background(), store)
+	assert.NoError(t, err)
+	assert.Equal(t, result, models.AuditVerification{Valid: false, Checked: 1, BrokenAt: 2})
+
+	store.entries[1].Target = "b@test.pl"
+	store.entries = append(store.entries[:1], store.entries[2:]...)
+	result, err = VerifyAudit(context.Background(), store)
+	assert.NoError(t, err)
+	assert.False(t, result.Valid)
+}
+
+func TestAuditSubject(t *testing.T) {
+	store := NewMemoryAuditStore()
+	ctx := context.Background()
+	_, err := store.AuditSubject(ctx, "a@test.pl", false)
+	assert.ErrorIs(t, err, ErrNotFound)
+	subject, err := store.AuditSubject(ctx, "a@test.pl", true)
+	assert.NoError(t, err)
+	assert.NotContains(t, subject, "a@test.pl")
+	again, err := store.AuditSubject(ctx, "a@test.pl", false)
+	assert.NoError(t, err)
+	assert.Equal(t, again, subject)
+	other, err := store.AuditSubject(ctx, "b@test.pl", true)
+	assert.NoError(t, err)
+	assert.NotEqual(t, other, subject)
+	key, err := store.AuditKey(ctx, subject)
+	assert.NoError(t, err)
+	assert.Len(t, key, 32)
+	otherKey, err := store.AuditKey(ctx, other)
+	assert.NoError(t, err)
+	assert.NotEqual(t, otherKey, key)
+	assert.NoError(t, store.ForgetAuditSubject(ctx, "a@test.pl"))
+	_, err = store.AuditSubject(ctx, "a@test.pl", false)
+	assert.ErrorIs(t, err, ErrNotFound)
+	_, err = store.AuditKey(ctx, subject)
+	assert.ErrorIs(t, err, ErrNotFound)
+}
@@ -3,15 +3,50 @@ package dbhandler
 import (
 	"UserStorage/models"
 	"context"
+	"errors"
+	"time"
 )
 
+var (
+	ErrNotFound        = errors.New("not found")
+	ErrInvalidCursor   = errors.New("invalid cursor")
+	ErrDuplicate       = errors.New("user already exists")
+	ErrVersionConflict = errors.New("user was modified concurrently")
+)
+
+const AnyVersion int64 = -1
+
+const AllRevisions = 0
+
 type DBHandler interface {
-	GetUsers(ctx context.Context) ([]models.User, error)
+	GetUsers(ctx context.Context, query models.UserQuery) (models.UserPage, error)
+	ExportUsers(ctx context.Context, fn func(models.User) error) error
+	SearchUsers(ctx context.Context, q string, limit int) ([]models.SearchHit, error)
 	GetUser(ctx context.Context, id string) (models.User, error)
+	GetUserIncludingDeleted(ctx context.Context, id string) (models.User, error)
 	CreateUser(ctx context.Context, usr models.User) error
+	CreateUsers(ctx context.Context, users []models.User) ([]error, error)
+	ExistingUserIDs(ctx context.Context, ids []string) ([]string, error)
 	UpdateUser(ctx context.Context, usr models.User) error
-	DeleteUser(ctx context.Context, id string) error
-	AddFileToUser(ctx context.Context, id string, file models.File) error
-	DeleteFilesFromUser(ctx context.Context, id string) error
+	DeleteUser(ctx context.Context, id string, version int64) error
+	RestoreUser(ctx context.Context, id string) error
+	PurgeDeletedUsers(ctx context.Context, deletedBefore time.Time) ([]models.User, error)
+	EraseUser(ctx context.Context, id string) error
+	GetUserHistory(ctx context.Context, id string, limit int) ([]models.UserRevision, error)
+	GetUserRevision(ctx context.Context, id string, version int64) (models.UserRevision, error)
+	GetUserAsOf(ctx context.Context, id string, at time.Time) (models.UserRevision, error)
+	AddFileToUser(ctx context.Context, id string, file models.File, version int64) error
+	DeleteFilesFromUser(ctx context.Context, id string, version int64) error
 	GetUserFiles(ctx context.Context, id string) ([]models.File, error)
+	SetLockout(ctx context.Context, id string, lockout models.Lockout) error
+	RecordFailedLogin(ctx context.Context, id string) (models.Lockout, error)
+	LockAccount(ctx context.Context, id string, threshold int, lockout models.Lockout) (bool, error)
+	UpdatePassword(ctx context.Context, id string, password string) error
+	SetTOTP(ctx context.Context, id string, totp models.TOTP) error
+	UseTOTPStep(ctx context.Context, id string, step int64) error
+	GetUserByIdentity(ctx context.Context, identity models.Identity) (models.User, error)
+	AddIdentity(ctx context.Context, id string, identity models.Identity) error
+	SaveToken(ctx context.Context, token models.Token) error
+	ConsumeToken(ctx context.Context, id string, kind string) (models.Token, error)
+	DeleteTokens(ctx context.Context, userID string, kind string) error
 }
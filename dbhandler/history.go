@@ -0,0 +1,107 @@
+package dbhandler
+
+import (
+	"UserStorage/models"
+	"context"
+	"errors"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+	"time"
+)
+
+func (m MongoHandler) writeUser(ctx context.Context, filter bson.M, update bson.M) (models.User, error) {
+	update["$inc"] = bson.M{"version": 1}
+	var user models.User
+	err := m.coll.FindOneAndUpdate(ctx, filter, update, options.FindOneAndUpdate().SetReturnDocument(options.After)).Decode(&user)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return models.User{}, ErrNotFound
+	}
+	if err != nil {
+		return models.User{}, err
+	}
+	return user, m.recordHistory(ctx, user)
+}
+
+func (m MongoHandler) updateCredentials(ctx context.Context, filter bson.M, update bson.M) error {
+	res, err := m.coll.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return err
+	}
+	if res.MatchedCount == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+var historyCredentials = bson.M{"user.password": "", "user.totp": "", "user.lockout": "", "user.identities": ""}
+
+func historyProjection() bson.M {
+	projection := bson.M{}
+	for field := range historyCredentials {
+		projection[field] = 0
+	}
+	return projection
+}
+
+func (m MongoHandler) scrubHistory(ctx context.Context) error {
+	_, err := m.history.UpdateMany(ctx, bson.M{}, bson.M{"$unset": historyCredentials})
+	return err
+}
+
+func (m MongoHandler) recordHistory(ctx context.Context, user models.User) error {
+	user.Password = ""
+	user.TOTP = models.TOTP{}
+	user.Lockout = models.Lockout{}
+	user.Identities = nil
+	_, err := m.history.InsertOne(ctx, models.UserRevision{
+		UserID:    user.Email,
+		Version:   user.Version,
+		ChangedAt: time.Now(),
+		User:      user,
+	})
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (m MongoHandler) GetUserHistory(ctx context.Context, id string, limit int) ([]models.UserRevision, error) {
+	opts := options.Find().
+		SetSort(bson.D{{Key: "version", Value: -1}}).
+		SetLimit(int64(limit)).
+		SetProjection(historyProjection())
+	cursor, err := m.history.Find(ctx, bson.M{"userID": id}, opts)
+	if err != nil {
+		return nil, err
+	}
+	revisions := []models.UserRevision{}
+	if err = cursor.All(ctx, &revisions); err != nil {
+		return nil, err
+	}
+	return revisions, nil
+}
+
+func (m MongoHandler) GetUserRevision(ctx context.Context, id string, version int64) (models.UserRevision, error) {
+	return m.findRevision(ctx, bson.M{"userID": id, "version": version}, nil)
+}
+
+func (m MongoHandler) GetUserAsOf(ctx context.Context, id string, at time.Time) (models.UserRevision, error) {
+	return m.findRevision(ctx, bson.M{"userID": id, "changedAt": bson.M{"$lte": at}}, bson.D{{Key: "version", Value: -1}})
+}
+
+func (m MongoHandler) findRevision(ctx context.Context, filter bson.M, sort bson.D) (models.UserRevision, error) {
+	opts := options.FindOne().SetProjection(historyProjection())
+	if sort != nil {
+		opts.SetSort(sort)
+	}
+	var revision models.UserRevision
+	err := m.history.FindOne(ctx, filter, opts).Decode(&revision)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return models.UserRevision{}, ErrNotFound
+	}
+	if err != nil {
+		return models.UserRevision{}, err
+	}
+	return revision, nil
+}
@@ -0,0 +1,32 @@
+package dbhandler
+
+import (
+	"context"
+	"errors"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"time"
+)
+
+type migration struct {
+	ID        string    `bson:"_id"`
+	AppliedAt time.Time `bson:"appliedAt"`
+}
+
+func (m MongoHandler) migrate(ctx context.Context, id string, fn func(context.Context) error) error {
+	err := m.migrations.FindOne(ctx, bson.M{"_id": id}).Err()
+	if err == nil {
+		return nil
+	}
+	if !errors.Is(err, mongo.ErrNoDocuments) {
+		return err
+	}
+	if err = fn(ctx); err != nil {
+		return err
+	}
+	_, err = m.migrations.InsertOne(ctx, migration{ID: id, AppliedAt: time.Now()})
+	if mongo.IsDuplicateKeyError(err) {
+		return nil
+	}
+	return err
+}
@@ -8,6 +8,7 @@ import (
 	models "UserStorage/models"
 	context "context"
 	reflect "reflect"
+	time "time"
 
 	gomock "github.com/golang/mock/gomock"
 )
@@ -36,17 +37,46 @@ func (m *MockDBHandler) EXPECT() *MockDBHandlerMockRecorder {
 }
 
 // AddFileToUser mocks base method.
-func (m *MockDBHandler) AddFileToUser(ctx context.Context, id string, file models.File) error {
+func (m *MockDBHandler) AddFileToUser(ctx context.Context, id string, file models.File, version int64) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "AddFileToUser", ctx, id, file)
+	ret := m.ctrl.Call(m, "AddFileToUser", ctx, id, file, version)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
 // AddFileToUser indicates an expected call of AddFileToUser.
-func (mr *MockDBHandlerMockRecorder) AddFileToUser(ctx, id, file interface{}) *gomock.Call {
+func (mr *MockDBHandlerMockRecorder) AddFileToUser(ctx, id, file, version interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddFileToUser", reflect.TypeOf((*MockDBHandler)(nil).AddFileToUser), ctx, id, file)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddFileToUser", reflect.TypeOf((*MockDBHandler)(nil).AddFileToUser), ctx, id, file, version)
+}
+
+// AddIdentity mocks base method.
+func (m *MockDBHandler) AddIdentity(ctx context.Context, id string, identity models.Identity) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AddIdentity", ctx, id, identity)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// AddIdentity indicates an expected call of AddIdentity.
+func (mr *MockDBHandlerMockRecorder) AddIdentity(ctx, id, identity interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddIdentity", reflect.TypeOf((*MockDBHandler)(nil).AddIdentity), ctx, id, identity)
+}
+
+// ConsumeToken mocks base method.
+func (m *MockDBHandler) ConsumeToken(ctx context.Context, id, kind string) (models.Token, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ConsumeToken", ctx, id, kind)
+	ret0, _ := ret[0].(models.Token)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ConsumeToken indicates an expected call of ConsumeToken.
+func (mr *MockDBHandlerMockRecorder) ConsumeToken(ctx, id, kind interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ConsumeToken", reflect.TypeOf((*MockDBHandler)(nil).ConsumeToken), ctx, id, kind)
 }
 
 // CreateUser mocks base method.
@@ -63,32 +93,104 @@ func (mr *MockDBHandlerMockRecorder) CreateUser(ctx, usr interface{}) *gomock.Ca
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateUser", reflect.TypeOf((*MockDBHandler)(nil).CreateUser), ctx, usr)
 }
 
+// CreateUsers mocks base method.
+func (m *MockDBHandler) CreateUsers(ctx context.Context, users []models.User) ([]error, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateUsers", ctx, users)
+	ret0, _ := ret[0].([]error)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CreateUsers indicates an expected call of CreateUsers.
+func (mr *MockDBHandlerMockRecorder) CreateUsers(ctx, users interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateUsers", reflect.TypeOf((*MockDBHandler)(nil).CreateUsers), ctx, users)
+}
+
 // DeleteFilesFromUser mocks base method.
-func (m *MockDBHandler) DeleteFilesFromUser(ctx context.Context, id string) error {
+func (m *MockDBHandler) DeleteFilesFromUser(ctx context.Context, id string, version int64) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "DeleteFilesFromUser", ctx, id)
+	ret := m.ctrl.Call(m, "DeleteFilesFromUser", ctx, id, version)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
 // DeleteFilesFromUser indicates an expected call of DeleteFilesFromUser.
-func (mr *MockDBHandlerMockRecorder) DeleteFilesFromUser(ctx, id interface{}) *gomock.Call {
+func (mr *MockDBHandlerMockRecorder) DeleteFilesFromUser(ctx, id, version interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteFilesFromUser", reflect.TypeOf((*MockDBHandler)(nil).DeleteFilesFromUser), ctx, id)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteFilesFromUser", reflect.TypeOf((*MockDBHandler)(nil).DeleteFilesFromUser), ctx, id, version)
+}
+
+// DeleteTokens mocks base method.
+func (m *MockDBHandler) DeleteTokens(ctx context.Context, userID, kind string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteTokens", ctx, userID, kind)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteTokens indicates an expected call of DeleteTokens.
+func (mr *MockDBHandlerMockRecorder) DeleteTokens(ctx, userID, kind interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteTokens", reflect.TypeOf((*MockDBHandler)(nil).DeleteTokens), ctx, userID, kind)
 }
 
 // DeleteUser mocks base method.
-func (m *MockDBHandler) DeleteUser(ctx context.Context, id string) error {
+func (m *MockDBHandler) DeleteUser(ctx context.Context, id string, version int64) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "DeleteUser", ctx, id)
+	ret := m.ctrl.Call(m, "DeleteUser", ctx, id, version)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
 // DeleteUser indicates an expected call of DeleteUser.
-func (mr *MockDBHandlerMockRecorder) DeleteUser(ctx, id interface{}) *gomock.Call {
+func (mr *MockDBHandlerMockRecorder) DeleteUser(ctx, id, version interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteUser", reflect.TypeOf((*MockDBHandler)(nil).DeleteUser), ctx, id, version)
+}
+
+// EraseUser mocks base method.
+func (m *MockDBHandler) EraseUser(ctx context.Context, id string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "EraseUser", ctx, id)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// EraseUser indicates an expected call of EraseUser.
+func (mr *MockDBHandlerMockRecorder) EraseUser(ctx, id interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "EraseUser", reflect.TypeOf((*MockDBHandler)(nil).EraseUser), ctx, id)
+}
+
+// ExistingUserIDs mocks base method.
+func (m *MockDBHandler) ExistingUserIDs(ctx context.Context, ids []string) ([]string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ExistingUserIDs", ctx, ids)
+	ret0, _ := ret[0].([]string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ExistingUserIDs indicates an expected call of ExistingUserIDs.
+func (mr *MockDBHandlerMockRecorder) ExistingUserIDs(ctx, ids interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ExistingUserIDs", reflect.TypeOf((*MockDBHandler)(nil).ExistingUserIDs), ctx, ids)
+}
+
+// ExportUsers mocks base method.
+func (m *MockDBHandler) ExportUsers(ctx context.Context, fn func(models.User) error) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ExportUsers", ctx, fn)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// ExportUsers indicates an expected call of ExportUsers.
+func (mr *MockDBHandlerMockRecorder) ExportUsers(ctx, fn interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteUser", reflect.TypeOf((*MockDBHandler)(nil).DeleteUser), ctx, id)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ExportUsers", reflect.TypeOf((*MockDBHandler)(nil).ExportUsers), ctx, fn)
 }
 
 // GetUser mocks base method.
@@ -106,6 +208,36 @@ func (mr *MockDBHandlerMockRecorder) GetUser(ctx, id interface{}) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUser", reflect.TypeOf((*MockDBHandler)(nil).GetUser), ctx, id)
 }
 
+// GetUserAsOf mocks base method.
+func (m *MockDBHandler) GetUserAsOf(ctx context.Context, id string, at time.Time) (models.UserRevision, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetUserAsOf", ctx, id, at)
+	ret0, _ := ret[0].(models.UserRevision)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetUserAsOf indicates an expected call of GetUserAsOf.
+func (mr *MockDBHandlerMockRecorder) GetUserAsOf(ctx, id, at interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUserAsOf", reflect.TypeOf((*MockDBHandler)(nil).GetUserAsOf), ctx, id, at)
+}
+
+// GetUserByIdentity mocks base method.
+func (m *MockDBHandler) GetUserByIdentity(ctx context.Context, identity models.Identity) (models.User, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetUserByIdentity", ctx, identity)
+	ret0, _ := ret[0].(models.User)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetUserByIdentity indicates an expected call of GetUserByIdentity.
+func (mr *MockDBHandlerMockRecorder) GetUserByIdentity(ctx, identity interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUserByIdentity", reflect.TypeOf((*MockDBHandler)(nil).GetUserByIdentity), ctx, identity)
+}
+
 // GetUserFiles mocks base method.
 func (m *MockDBHandler) GetUserFiles(ctx context.Context, id string) ([]models.File, error) {
 	m.ctrl.T.Helper()
@@ -121,19 +253,194 @@ func (mr *MockDBHandlerMockRecorder) GetUserFiles(ctx, id interface{}) *gomock.C
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUserFiles", reflect.TypeOf((*MockDBHandler)(nil).GetUserFiles), ctx, id)
 }
 
+// GetUserHistory mocks base method.
+func (m *MockDBHandler) GetUserHistory(ctx context.Context, id string, limit int) ([]models.UserRevision, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetUserHistory", ctx, id, limit)
+	ret0, _ := ret[0].([]models.UserRevision)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetUserHistory indicates an expected call of GetUserHistory.
+func (mr *MockDBHandlerMockRecorder) GetUserHistory(ctx, id, limit interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUserHistory", reflect.TypeOf((*MockDBHandler)(nil).GetUserHistory), ctx, id, limit)
+}
+
+// GetUserIncludingDeleted mocks base method.
+func (m *MockDBHandler) GetUserIncludingDeleted(ctx context.Context, id string) (models.User, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetUserIncludingDeleted", ctx, id)
+	ret0, _ := ret[0].(models.User)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetUserIncludingDeleted indicates an expected call of GetUserIncludingDeleted.
+func (mr *MockDBHandlerMockRecorder) GetUserIncludingDeleted(ctx, id interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUserIncludingDeleted", reflect.TypeOf((*MockDBHandler)(nil).GetUserIncludingDeleted), ctx, id)
+}
+
+// GetUserRevision mocks base method.
+func (m *MockDBHandler) GetUserRevision(ctx context.Context, id string, version int64) (models.UserRevision, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetUserRevision", ctx, id, version)
+	ret0, _ := ret[0].(models.UserRevision)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetUserRevision indicates an expected call of GetUserRevision.
+func (mr *MockDBHandlerMockRecorder) GetUserRevision(ctx, id, version interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUserRevision", reflect.TypeOf((*MockDBHandler)(nil).GetUserRevision), ctx, id, version)
+}
+
 // GetUsers mocks base method.
-func (m *MockDBHandler) GetUsers(ctx context.Context) ([]models.User, error) {
+func (m *MockDBHandler) GetUsers(ctx context.Context, query models.UserQuery) (models.UserPage, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "GetUsers", ctx)
-	ret0, _ := ret[0].([]models.User)
+	ret := m.ctrl.Call(m, "GetUsers", ctx, query)
+	ret0, _ := ret[0].(models.UserPage)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // GetUsers indicates an expected call of GetUsers.
-func (mr *MockDBHandlerMockRecorder) GetUsers(ctx interface{}) *gomock.Call {
+func (mr *MockDBHandlerMockRecorder) GetUsers(ctx, query interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUsers", reflect.TypeOf((*MockDBHandler)(nil).GetUsers), ctx, query)
+}
+
+// LockAccount mocks base method.
+func (m *MockDBHandler) LockAccount(ctx context.Context, id string, threshold int, lockout models.Lockout) (bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "LockAccount", ctx, id, threshold, lockout)
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// LockAccount indicates an expected call of LockAccount.
+func (mr *MockDBHandlerMockRecorder) LockAccount(ctx, id, threshold, lockout interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "LockAccount", reflect.TypeOf((*MockDBHandler)(nil).LockAccount), ctx, id, threshold, lockout)
+}
+
+// PurgeDeletedUsers mocks base method.
+func (m *MockDBHandler) PurgeDeletedUsers(ctx context.Context, deletedBefore time.Time) ([]models.User, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "PurgeDeletedUsers", ctx, deletedBefore)
+	ret0, _ := ret[0].([]models.User)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// PurgeDeletedUsers indicates an expected call of PurgeDeletedUsers.
+func (mr *MockDBHandlerMockRecorder) PurgeDeletedUsers(ctx, deletedBefore interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PurgeDeletedUsers", reflect.TypeOf((*MockDBHandler)(nil).PurgeDeletedUsers), ctx, deletedBefore)
+}
+
+// RecordFailedLogin mocks base method.
+func (m *MockDBHandler) RecordFailedLogin(ctx context.Context, id string) (models.Lockout, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RecordFailedLogin", ctx, id)
+	ret0, _ := ret[0].(models.Lockout)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// RecordFailedLogin indicates an expected call of RecordFailedLogin.
+func (mr *MockDBHandlerMockRecorder) RecordFailedLogin(ctx, id interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RecordFailedLogin", reflect.TypeOf((*MockDBHandler)(nil).RecordFailedLogin), ctx, id)
+}
+
+// RestoreUser mocks base method.
+func (m *MockDBHandler) RestoreUser(ctx context.Context, id string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RestoreUser", ctx, id)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RestoreUser indicates an expected call of RestoreUser.
+func (mr *MockDBHandlerMockRecorder) RestoreUser(ctx, id interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RestoreUser", reflect.TypeOf((*MockDBHandler)(nil).RestoreUser), ctx, id)
+}
+
+// SaveToken mocks base method.
+func (m *MockDBHandler) SaveToken(ctx context.Context, token models.Token) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SaveToken", ctx, token)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SaveToken indicates an expected call of SaveToken.
+func (mr *MockDBHandlerMockRecorder) SaveToken(ctx, token interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUsers", reflect.TypeOf((*MockDBHandler)(nil).GetUsers), ctx)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SaveToken", reflect.TypeOf((*MockDBHandler)(nil).SaveToken), ctx, token)
+}
+
+// SearchUsers mocks base method.
+func (m *MockDBHandler) SearchUsers(ctx context.Context, q string, limit int) ([]models.SearchHit, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SearchUsers", ctx, q, limit)
+	ret0, _ := ret[0].([]models.SearchHit)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// SearchUsers indicates an expected call of SearchUsers.
+func (mr *MockDBHandlerMockRecorder) SearchUsers(ctx, q, limit interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SearchUsers", reflect.TypeOf((*MockDBHandler)(nil).SearchUsers), ctx, q, limit)
+}
+
+// SetLockout mocks base method.
+func (m *MockDBHandler) SetLockout(ctx context.Context, id string, lockout models.Lockout) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetLockout", ctx, id, lockout)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SetLockout indicates an expected call of SetLockout.
+func (mr *MockDBHandlerMockRecorder) SetLockout(ctx, id, lockout interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetLockout", reflect.TypeOf((*MockDBHandler)(nil).SetLockout), ctx, id, lockout)
+}
+
+// SetTOTP mocks base method.
+func (m *MockDBHandler) SetTOTP(ctx context.Context, id string, totp models.TOTP) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetTOTP", ctx, id, totp)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SetTOTP indicates an expected call of SetTOTP.
+func (mr *MockDBHandlerMockRecorder) SetTOTP(ctx, id, totp interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetTOTP", reflect.TypeOf((*MockDBHandler)(nil).SetTOTP), ctx, id, totp)
+}
+
+// UpdatePassword mocks base method.
+func (m *MockDBHandler) UpdatePassword(ctx context.Context, id, password string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdatePassword", ctx, id, password)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UpdatePassword indicates an expected call of UpdatePassword.
+func (mr *MockDBHandlerMockRecorder) UpdatePassword(ctx, id, password interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdatePassword", reflect.TypeOf((*MockDBHandler)(nil).UpdatePassword), ctx, id, password)
 }
 
 // UpdateUser mocks base method.
@@ -149,3 +456,17 @@ func (mr *MockDBHandlerMockRecorder) UpdateUser(ctx, usr interface{}) *gomock.Ca
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateUser", reflect.TypeOf((*MockDBHandler)(nil).UpdateUser), ctx, usr)
 }
+
+// UseTOTPStep mocks base method.
+func (m *MockDBHandler) UseTOTPStep(ctx context.Context, id string, step int64) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UseTOTPStep", ctx, id, step)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UseTOTPStep indicates an expected call of UseTOTPStep.
+func (mr *MockDBHandlerMockRecorder) UseTOTPStep(ctx, id, step interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UseTOTPStep", reflect.TypeOf((*MockDBHandler)(nil).UseTOTPStep), ctx, id, step)
+}
@@ -3,13 +3,18 @@ package dbhandler
 import (
 	"UserStorage/models"
 	"context"
+	"errors"
 	"go.mongodb.org/mongo-driver/v2/bson"
 	"go.mongodb.org/mongo-driver/v2/mongo"
 	"go.mongodb.org/mongo-driver/v2/mongo/options"
+	"time"
 )
 
 type MongoHandler struct {
-	coll *mongo.Collection
+	coll       *mongo.Collection
+	tokens     *mongo.Collection
+	history    *mongo.Collection
+	migrations *mongo.Collection
 }
 
 func NewMongoHandler(mongoURI string) *MongoHandler {
@@ -17,85 +22,405 @@ func NewMongoHandler(mongoURI string) *MongoHandler {
 	if err != nil {
 		panic(err)
 	}
-	col := client.Database("users").Collection("users")
-	return &MongoHandler{col}
+	db := client.Database("users")
+	col := db.Collection("users")
+	tokens := db.Collection("tokens")
+	_, err = tokens.Indexes().CreateOne(context.Background(), mongo.IndexModel{
+		Keys:    bson.D{{Key: "expiresAt", Value: 1}},
+		Options: options.Index().SetExpireAfterSeconds(0),
+	})
+	if err != nil {
+		panic(err)
+	}
+	_, err = col.Indexes().CreateMany(context.Background(), []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "identities.issuer", Value: 1}, {Key: "identities.subject", Value: 1}},
+			Options: options.Index().SetUnique(true).SetSparse(true),
+		},
+		{
+			Keys: bson.D{{Key: "username", Value: 1}, {Key: "_id", Value: 1}},
+		},
+		{
+			Keys: bson.D{{Key: "age", Value: 1}, {Key: "_id", Value: 1}},
+		},
+		{
+			Keys:    bson.D{{Key: "deletedAt", Value: 1}},
+			Options: options.Index().SetSparse(true),
+		},
+	})
+	if err != nil {
+		panic(err)
+	}
+	history := db.Collection("user_history")
+	_, err = history.Indexes().CreateOne(context.Background(), mongo.IndexModel{
+		Keys:    bson.D{{Key: "userID", Value: 1}, {Key: "version", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	})
+	if err != nil {
+		panic(err)
+	}
+	m := &MongoHandler{col, tokens, history, db.Collection("migrations")}
+	if err = m.migrate(context.Background(), "search-text-index", m.migrateSearch); err != nil {
+		panic(err)
+	}
+	if err = m.migrate(context.Background(), "history-without-credentials", m.scrubHistory); err != nil {
+		panic(err)
+	}
+	return m
 }
 
-func (m MongoHandler) GetUsers(ctx context.Context) ([]models.User, error) {
-	var users []models.User
-	cursor, err := m.coll.Find(ctx, bson.D{})
+func (m MongoHandler) Database() *mongo.Database {
+	return m.coll.Database()
+}
+
+func (m MongoHandler) GetUsers(ctx context.Context, query models.UserQuery) (models.UserPage, error) {
+	if query.Sort == "" {
+		query.Sort = models.SortEmail
+	}
+	if query.Limit <= 0 {
+		query.Limit = models.DefaultPageSize
+	}
+	filter, sort, err := pageQuery(query)
 	if err != nil {
-		return nil, err
+		return models.UserPage{}, err
+	}
+	opts := options.Find().SetSort(sort).SetLimit(int64(query.Limit) + 1).SetProjection(bson.M{"password": 0})
+	cursor, err := m.coll.Find(ctx, filter, opts)
+	if err != nil {
+		return models.UserPage{}, err
 	}
+	users := []models.User{}
 	if err = cursor.All(ctx, &users); err != nil {
-		return nil, err
+		return models.UserPage{}, err
 	}
-	return users, nil
+	page := models.UserPage{Users: users}
+	if len(users) > query.Limit {
+		page.Users = users[:query.Limit]
+		page.NextCursor = encodeCursor(query, page.Users[query.Limit-1])
+	}
+	return page, nil
+}
+
+func (m MongoHandler) ExportUsers(ctx context.Context, fn func(models.User) error) error {
+	opts := options.Find().SetSort(bson.D{{Key: "_id", Value: 1}}).SetProjection(bson.M{"password": 0})
+	cursor, err := m.coll.Find(ctx, bson.M{"deletedAt": nil}, opts)
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(ctx)
+	for cursor.Next(ctx) {
+		var user models.User
+		if err = cursor.Decode(&user); err != nil {
+			return err
+		}
+		if err = fn(user); err != nil {
+			return err
+		}
+	}
+	return cursor.Err()
 }
 
 func (m MongoHandler) GetUser(ctx context.Context, id string) (models.User, error) {
+	return m.findUser(ctx, bson.M{"_id": id, "deletedAt": nil})
+}
+
+func (m MongoHandler) GetUserIncludingDeleted(ctx context.Context, id string) (models.User, error) {
+	return m.findUser(ctx, bson.M{"_id": id})
+}
+
+func (m MongoHandler) findUser(ctx context.Context, filter bson.M) (models.User, error) {
 	var user models.User
-	if err := m.coll.FindOne(ctx, bson.M{"_id": id}).Decode(&user); err != nil {
+	err := m.coll.FindOne(ctx, filter).Decode(&user)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return models.User{}, ErrNotFound
+	}
+	if err != nil {
 		return models.User{}, err
 	}
 	return user, nil
 }
 
 func (m MongoHandler) CreateUser(ctx context.Context, usr models.User) error {
-
-	_, err := m.coll.InsertOne(ctx, usr)
+	usr.Version = 1
+	_, err := m.coll.InsertOne(ctx, newUserDocument(usr))
+	if mongo.IsDuplicateKeyError(err) {
+		return ErrDuplicate
+	}
 	if err != nil {
 		return err
 	}
-	return nil
+	return m.recordHistory(ctx, usr)
+}
+
+func (m MongoHandler) CreateUsers(ctx context.Context, users []models.User) ([]error, error) {
+	errs := make([]error, len(users))
+	if len(users) == 0 {
+		return errs, nil
+	}
+	docs := make([]userDocument, len(users))
+	for i, usr := range users {
+		usr.Version = 1
+		docs[i] = newUserDocument(usr)
+	}
+	_, err := m.coll.InsertMany(ctx, docs, options.InsertMany().SetOrdered(false))
+	var bulkErr mongo.BulkWriteException
+	if errors.As(err, &bulkErr) && bulkErr.WriteConcernError == nil {
+		for _, writeErr := range bulkErr.WriteErrors {
+			if mongo.IsDuplicateKeyError(writeErr) {
+				errs[writeErr.Index] = ErrDuplicate
+				continue
+			}
+			errs[writeErr.Index] = writeErr
+		}
+	} else if err != nil {
+		return nil, err
+	}
+	for i, usr := range docs {
+		if errs[i] != nil {
+			continue
+		}
+		if err = m.recordHistory(ctx, usr.User); err != nil {
+			return nil, err
+		}
+	}
+	return errs, nil
+}
+
+func (m MongoHandler) ExistingUserIDs(ctx context.Context, ids []string) ([]string, error) {
+	if len(ids) == 0 {
+		return nil, nil
+	}
+	cur, err := m.coll.Find(ctx, bson.M{"_id": bson.M{"$in": ids}}, options.Find().SetProjection(bson.M{"_id": 1}))
+	if err != nil {
+		return nil, err
+	}
+	var docs []struct {
+		ID string `bson:"_id"`
+	}
+	if err = cur.All(ctx, &docs); err != nil {
+		return nil, err
+	}
+	existing := make([]string, len(docs))
+	for i, doc := range docs {
+		existing[i] = doc.ID
+	}
+	return existing, nil
 }
 
 func (m MongoHandler) UpdateUser(ctx context.Context, usr models.User) error {
-	_, err := m.coll.UpdateOne(ctx, bson.M{"_id": usr.Email}, bson.M{"$set": usr})
+	_, err := m.writeUser(ctx, versionFilter(usr.Email, usr.Version), bson.M{"$set": bson.M{
+		"username":   usr.Username,
+		"searchText": userSearchText(usr),
+		"age":        usr.Age,
+		"files":      usr.Files,
+		"role":       usr.Role,
+		"status":     usr.Status,
+	}})
+	return m.versionedResult(ctx, usr.Email, err)
+}
+
+func (m MongoHandler) DeleteUser(ctx context.Context, id string, version int64) error {
+	_, err := m.writeUser(ctx, versionFilter(id, version), bson.M{"$set": bson.M{"deletedAt": time.Now()}})
+	return m.versionedResult(ctx, id, err)
+}
+
+func (m MongoHandler) RestoreUser(ctx context.Context, id string) error {
+	_, err := m.writeUser(ctx, bson.M{"_id": id, "deletedAt": bson.M{"$ne": nil}}, bson.M{"$unset": bson.M{"deletedAt": ""}})
+	return err
+}
+
+func (m MongoHandler) PurgeDeletedUsers(ctx context.Context, deletedBefore time.Time) ([]models.User, error) {
+	cursor, err := m.coll.Find(ctx, bson.M{"deletedAt": bson.M{"$lte": deletedBefore}},
+		options.Find().SetProjection(bson.M{"_id": 1, "files": 1}))
+	if err != nil {
+		return nil, err
+	}
+	var candidates []models.User
+	if err = cursor.All(ctx, &candidates); err != nil {
+		return nil, err
+	}
+	users := []models.User{}
+	for _, user := range candidates {
+		res, err := m.coll.DeleteOne(ctx, bson.M{"_id": user.Email, "deletedAt": bson.M{"$lte": deletedBefore}})
+		if err != nil {
+			return users, err
+		}
+		if res.DeletedCount == 0 {
+			continue
+		}
+		users = append(users, user)
+		if _, err = m.tokens.DeleteMany(ctx, bson.M{"userID": user.Email}); err != nil {
+			return users, err
+		}
+		if _, err = m.history.DeleteMany(ctx, bson.M{"userID": user.Email}); err != nil {
+			return users, err
+		}
+	}
+	return users, nil
+}
+
+func (m MongoHandler) EraseUser(ctx context.Context, id string) error {
+	res, err := m.coll.DeleteOne(ctx, bson.M{"_id": id})
+	if err != nil {
+		return err
+	}
+	if res.DeletedCount == 0 {
+		return ErrNotFound
+	}
+	_, err = m.tokens.DeleteMany(ctx, bson.M{"userID": id})
+	if err != nil {
+		return err
+	}
+	_, err = m.history.DeleteMany(ctx, bson.M{"userID": id})
 	if err != nil {
 		return err
 	}
 	return nil
 }
 
-func (m MongoHandler) DeleteUser(ctx context.Context, id string) error {
-	_, err := m.coll.DeleteOne(ctx, bson.M{"_id": id})
+func (m MongoHandler) AddFileToUser(ctx context.Context, id string, file models.File, version int64) error {
+	user, err := m.GetUser(ctx, id)
 	if err != nil {
 		return err
 	}
-	return nil
+	if version != AnyVersion && version != user.Version {
+		return ErrVersionConflict
+	}
+	_, err = m.writeUser(ctx, versionFilter(id, user.Version), bson.M{"$set": bson.M{"files": append(user.Files, file)}})
+	return m.versionedResult(ctx, id, err)
 }
 
-func (m MongoHandler) AddFileToUser(ctx context.Context, id string, file models.File) error {
-	var user models.User
-	if err := m.coll.FindOne(ctx, bson.M{"_id": id}).Decode(&user); err != nil {
+func (m MongoHandler) DeleteFilesFromUser(ctx context.Context, id string, version int64) error {
+	_, err := m.writeUser(ctx, versionFilter(id, version), bson.M{"$set": bson.M{"files": []models.File{}}})
+	return m.versionedResult(ctx, id, err)
+}
+
+func versionFilter(id string, version int64) bson.M {
+	filter := bson.M{"_id": id, "deletedAt": nil}
+	switch version {
+	case AnyVersion:
+	case 0:
+		filter["version"] = bson.M{"$in": bson.A{int64(0), nil}}
+	default:
+		filter["version"] = version
+	}
+	return filter
+}
+
+func (m MongoHandler) versionedResult(ctx context.Context, id string, err error) error {
+	if !errors.Is(err, ErrNotFound) {
 		return err
 	}
-	user.Files = append(user.Files, file)
-	_, err := m.coll.UpdateOne(ctx, bson.M{"_id": id}, bson.M{"$set": user})
+	count, err := m.coll.CountDocuments(ctx, bson.M{"_id": id, "deletedAt": nil})
 	if err != nil {
 		return err
 	}
-	return nil
+	if count == 0 {
+		return ErrNotFound
+	}
+	return ErrVersionConflict
+}
+
+func (m MongoHandler) GetUserFiles(ctx context.Context, id string) ([]models.File, error) {
+	user, err := m.GetUser(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	return user.Files, nil
 }
 
-func (m MongoHandler) DeleteFilesFromUser(ctx context.Context, id string) error {
+func (m MongoHandler) SetLockout(ctx context.Context, id string, lockout models.Lockout) error {
+	return m.updateCredentials(ctx, bson.M{"_id": id}, bson.M{"$set": bson.M{"lockout": lockout}})
+}
+
+func (m MongoHandler) RecordFailedLogin(ctx context.Context, id string) (models.Lockout, error) {
 	var user models.User
-	if err := m.coll.FindOne(ctx, bson.M{"_id": id}).Decode(&user); err != nil {
-		return err
+	err := m.coll.FindOneAndUpdate(ctx, bson.M{"_id": id}, bson.M{"$inc": bson.M{"lockout.failedAttempts": 1}},
+		options.FindOneAndUpdate().SetReturnDocument(options.After).SetProjection(bson.M{"lockout": 1})).Decode(&user)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return models.Lockout{}, ErrNotFound
+	}
+	if err != nil {
+		return models.Lockout{}, err
+	}
+	return user.Lockout, nil
+}
+
+func (m MongoHandler) LockAccount(ctx context.Context, id string, threshold int, lockout models.Lockout) (bool, error) {
+	res, err := m.coll.UpdateOne(ctx, bson.M{"_id": id, "lockout.failedAttempts": bson.M{"$gte": threshold}},
+		bson.M{"$set": bson.M{"lockout": lockout}})
+	if err != nil {
+		return false, err
 	}
-	user.Files = []models.File{}
-	_, err := m.coll.UpdateOne(ctx, bson.M{"_id": id}, bson.M{"$set": user})
+	return res.MatchedCount > 0, nil
+}
+
+func (m MongoHandler) UpdatePassword(ctx context.Context, id string, password string) error {
+	return m.updateCredentials(ctx, bson.M{"_id": id, "deletedAt": nil}, bson.M{"$set": bson.M{"password": password}})
+}
+
+func (m MongoHandler) SetTOTP(ctx context.Context, id string, totp models.TOTP) error {
+	return m.updateCredentials(ctx, bson.M{"_id": id}, bson.M{"$set": bson.M{"totp": totp}})
+}
+
+func (m MongoHandler) UseTOTPStep(ctx context.Context, id string, step int64) error {
+	res, err := m.coll.UpdateOne(ctx, bson.M{
+		"_id":           id,
+		"deletedAt":     nil,
+		"totp.lastStep": bson.M{"$not": bson.M{"$gte": step}},
+	}, bson.M{"$set": bson.M{"totp.lastStep": step}})
 	if err != nil {
 		return err
 	}
+	if res.MatchedCount == 0 {
+		return ErrNotFound
+	}
 	return nil
 }
 
-func (m MongoHandler) GetUserFiles(ctx context.Context, id string) ([]models.File, error) {
+func (m MongoHandler) GetUserByIdentity(ctx context.Context, identity models.Identity) (models.User, error) {
 	var user models.User
-	if err := m.coll.FindOne(ctx, bson.M{"_id": id}).Decode(&user); err != nil {
-		return nil, err
+	err := m.coll.FindOne(ctx, bson.M{"identities": bson.M{"$elemMatch": bson.M{
+		"issuer":  identity.Issuer,
+		"subject": identity.Subject,
+	}}, "deletedAt": nil}).Decode(&user)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return models.User{}, ErrNotFound
 	}
-	return user.Files, nil
+	if err != nil {
+		return models.User{}, err
+	}
+	return user, nil
+}
+
+func (m MongoHandler) AddIdentity(ctx context.Context, id string, identity models.Identity) error {
+	return m.updateCredentials(ctx, bson.M{"_id": id}, bson.M{"$addToSet": bson.M{"identities": identity}})
+}
+
+func (m MongoHandler) SaveToken(ctx context.Context, token models.Token) error {
+	_, err := m.tokens.InsertOne(ctx, token)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (m MongoHandler) ConsumeToken(ctx context.Context, id string, kind string) (models.Token, error) {
+	var token models.Token
+	err := m.tokens.FindOneAndDelete(ctx, bson.M{"_id": id, "kind": kind}).Decode(&token)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return models.Token{}, ErrNotFound
+	}
+	if err != nil {
+		return models.Token{}, err
+	}
+	return token, nil
+}
+
+func (m MongoHandler) DeleteTokens(ctx context.Context, userID string, kind string) error {
+	_, err := m.tokens.DeleteMany(ctx, bson.M{"userID": userID, "kind": kind})
+	if err != nil {
+		return err
+	}
+	return nil
 }
@@ -0,0 +1,110 @@
+package dbhandler
+
+import (
+	"UserStorage/models"
+	"encoding/base64"
+	"encoding/json"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"regexp"
+)
+
+type pageCursor struct {
+	Sort     string `json:"s"`
+	Desc     bool   `json:"d,omitempty"`
+	Username string `json:"u,omitempty"`
+	Age      int    `json:"a,omitempty"`
+	ID       string `json:"id"`
+}
+
+var sortFields = map[string]string{
+	models.SortEmail:    "_id",
+	models.SortUsername: "username",
+	models.SortAge:      "age",
+}
+
+func encodeCursor(q models.UserQuery, last models.User) string {
+	cur := pageCursor{Sort: q.Sort, Desc: q.Desc, ID: last.Email}
+	switch q.Sort {
+	case models.SortUsername:
+		cur.Username = last.Username
+	case models.SortAge:
+		cur.Age = last.Age
+	}
+	raw, _ := json.Marshal(cur)
+	return base64.RawURLEncoding.EncodeToString(raw)
+}
+
+func decodeCursor(q models.UserQuery) (pageCursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(q.Cursor)
+	if err != nil {
+		return pageCursor{}, ErrInvalidCursor
+	}
+	var cur pageCursor
+	if err = json.Unmarshal(raw, &cur); err != nil {
+		return pageCursor{}, ErrInvalidCursor
+	}
+	if cur.Sort != q.Sort || cur.Desc != q.Desc || cur.ID == "" {
+		return pageCursor{}, ErrInvalidCursor
+	}
+	return cur, nil
+}
+
+func userFilter(q models.UserQuery) bson.M {
+	filter := bson.M{}
+	if !q.IncludeDeleted {
+		filter["deletedAt"] = nil
+	}
+	if q.UsernamePrefix != "" {
+		filter["username"] = bson.M{"$regex": "^" + regexp.QuoteMeta(q.UsernamePrefix)}
+	}
+	age := bson.M{}
+	if q.MinAge != nil {
+		age["$gte"] = *q.MinAge
+	}
+	if q.MaxAge != nil {
+		age["$lte"] = *q.MaxAge
+	}
+	if len(age) > 0 {
+		filter["age"] = age
+	}
+	if q.HasFiles != nil {
+		filter["files.0"] = bson.M{"$exists": *q.HasFiles}
+	}
+	return filter
+}
+
+func pageQuery(q models.UserQuery) (bson.M, bson.D, error) {
+	field := sortFields[q.Sort]
+	dir, op := 1, "$gt"
+	if q.Desc {
+		dir, op = -1, "$lt"
+	}
+	sort := bson.D{{Key: field, Value: dir}}
+	if field != "_id" {
+		sort = append(sort, bson.E{Key: "_id", Value: dir})
+	}
+	filter := userFilter(q)
+	if q.Cursor == "" {
+		return filter, sort, nil
+	}
+	cur, err := decodeCursor(q)
+	if err != nil {
+		return nil, nil, err
+	}
+	var after bson.M
+	switch q.Sort {
+	case models.SortUsername:
+		after = bson.M{"$or": bson.A{
+			bson.M{"username": bson.M{op: cur.Username}},
+			bson.M{"username": cur.Username, "_id": bson.M{op: cur.ID}},
+		}}
+	case models.SortAge:
+		after = bson.M{"$or": bson.A{
+			bson.M{"age": bson.M{op: cur.Age}},
+			bson.M{"age": cur.Age, "_id": bson.M{op: cur.ID}},
+		}}
+	default:
+		after = bson.M{"_id": bson.M{op: cur.ID}}
+	}
+	return bson.M{"$and": bson.A{filter, after}}, sort, nil
+}
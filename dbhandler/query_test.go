@@ -0,0 +1,113 @@
+package dbhandler
+
+import (
+	"UserStorage/models"
+	"encoding/base64"
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"testing"
+)
+
+func TestCursorRoundTrip(t *testing.T) {
+	last := models.User{Email: "b@test.pl", Username: "bob", Age: 30}
+	for _, q := range []models.UserQuery{
+		{Sort: models.SortEmail},
+		{Sort: models.SortUsername},
+		{Sort: models.SortAge, Desc: true},
+	} {
+		q.Cursor = encodeCursor(q, last)
+		cur, err := decodeCursor(q)
+		assert.NoError(t, err)
+		assert.Equal(t, cur.ID, last.Email)
+		assert.Equal(t, cur.Sort, q.Sort)
+		assert.Equal(t, cur.Desc, q.Desc)
+	}
+}
+
+func TestDecodeCursorInvalid(t *testing.T) {
+	last := models.User{Email: "b@test.pl", Username: "bob", Age: 30}
+	username := models.UserQuery{Sort: models.SortUsername}
+	for name, q := range map[string]models.UserQuery{
+		"not base64":    {Sort: models.SortEmail, Cursor: "%%%"},
+		"not json":      {Sort: models.SortEmail, Cursor: base64.RawURLEncoding.EncodeToString([]byte("{"))},
+		"missing id":    {Sort: models.SortEmail, Cursor: base64.RawURLEncoding.EncodeToString([]byte(`{"s":"email"}`))},
+		"truncated":     {Sort: models.SortUsername, Cursor: encodeCursor(username, last)[:10]},
+		"other sort":    {Sort: models.SortAge, Cursor: encodeCursor(username, last)},
+		"other order":   {Sort: models.SortUsername, Desc: true, Cursor: encodeCursor(username, last)},
+		"padded base64": {Sort: models.SortUsername, Cursor: encodeCursor(username, last) + "="},
+	} {
+		_, err := decodeCursor(q)
+		assert.ErrorIs(t, err, ErrInvalidCursor, name)
+		_, _, err = pageQuery(q)
+		assert.ErrorIs(t, err, ErrInvalidCursor, name)
+	}
+}
+
+func TestPageQuery(t *testing.T) {
+	last := models.User{Email: "b@test.pl", Username: "bob", Age: 30}
+	notDeleted := bson.M{"deletedAt": nil}
+	for _, tc := range []struct {
+		name   string
+		query  models.UserQuery
+		filter bson.M
+		sort   bson.D
+	}{
+		{
+			name:   "first page by email",
+			query:  models.UserQuery{Sort: models.SortEmail},
+			filter: notDeleted,
+			sort:   bson.D{{Key: "_id", Value: 1}},
+		},
+		{
+			name:   "first page by username descending",
+			query:  models.UserQuery{Sort: models.SortUsername, Desc: true},
+			filter: notDeleted,
+			sort:   bson.D{{Key: "username", Value: -1}, {Key: "_id", Value: -1}},
+		},
+		{
+			name:  "next page by email",
+			query: models.UserQuery{Sort: models.SortEmail},
+			filter: bson.M{"$and": bson.A{notDeleted, bson.M{
+				"_id": bson.M{"$gt": "b@test.pl"},
+			}}},
+			sort: bson.D{{Key: "_id", Value: 1}},
+		},
+		{
+			name:  "next page by email descending",
+			query: models.UserQuery{Sort: models.SortEmail, Desc: true},
+			filter: bson.M{"$and": bson.A{notDeleted, bson.M{
+				"_id": bson.M{"$lt": "b@test.pl"},
+			}}},
+			sort: bson.D{{Key: "_id", Value: -1}},
+		},
+		{
+			name:  "next page by username breaks ties on id",
+			query: models.UserQuery{Sort: models.SortUsername},
+			filter: bson.M{"$and": bson.A{notDeleted, bson.M{"$or": bson.A{
+				bson.M{"username": bson.M{"$gt": "bob"}},
+				bson.M{"username": "bob", "_id": bson.M{"$gt": "b@test.pl"}},
+			}}}},
+			sort: bson.D{{Key: "username", Value: 1}, {Key: "_id", Value: 1}},
+		},
+		{
+			name:  "next page by age descending breaks ties on id",
+			query: models.UserQuery{Sort: models.SortAge, Desc: true},
+			filter: bson.M{"$and": bson.A{notDeleted, bson.M{"$or": bson.A{
+				bson.M{"age": bson.M{"$lt": 30}},
+				bson.M{"age": 30, "_id": bson.M{"$lt": "b@test.pl"}},
+			}}}},
+			sort: bson.D{{Key: "age", Value: -1}, {Key: "_id", Value: -1}},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			q := tc.query
+			if _, ok := tc.filter["$and"]; ok {
+				q.Cursor = encodeCursor(q, last)
+			}
+			filter, sort, err := pageQuery(q)
+			assert.NoError(t, err)
+			assert.Equal(t, tc.filter, filter)
+			assert.Equal(t, tc.sort, sort)
+		})
+	}
+}
@@ -0,0 +1,108 @@
+package dbhandler
+
+import (
+	"context"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+	"sync"
+	"time"
+)
+
+type RevocationStore interface {
+	RevokeToken(ctx context.Context, jti string, expiresAt time.Time) error
+	RevokeUser(ctx context.Context, userID string, before time.Time, expiresAt time.Time) error
+	IsRevoked(ctx context.Context, jti string, userID string, issuedAt time.Time) (bool, error)
+}
+
+type MongoRevocationStore struct {
+	coll *mongo.Collection
+}
+
+func NewMongoRevocationStore(db *mongo.Database) *MongoRevocationStore {
+	col := db.Collection("revocations")
+	_, err := col.Indexes().CreateOne(context.Background(), mongo.IndexModel{
+		Keys:    bson.D{{Key: "expiresAt", Value: 1}},
+		Options: options.Index().SetExpireAfterSeconds(0),
+	})
+	if err != nil {
+		panic(err)
+	}
+	return &MongoRevocationStore{col}
+}
+
+func (m MongoRevocationStore) RevokeToken(ctx context.Context, jti string, expiresAt time.Time) error {
+	_, err := m.coll.UpdateOne(ctx, bson.M{"_id": "jti:" + jti},
+		bson.M{"$set": bson.M{"expiresAt": expiresAt}}, options.UpdateOne().SetUpsert(true))
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (m MongoRevocationStore) RevokeUser(ctx context.Context, userID string, before time.Time, expiresAt time.Time) error {
+	_, err := m.coll.UpdateOne(ctx, bson.M{"_id": "user:" + userID},
+		bson.M{"$set": bson.M{"before": before.Truncate(time.Second), "expiresAt": expiresAt}}, options.UpdateOne().SetUpsert(true))
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (m MongoRevocationStore) IsRevoked(ctx context.Context, jti string, userID string, issuedAt time.Time) (bool, error) {
+	n, err := m.coll.CountDocuments(ctx, bson.M{"$or": bson.A{
+		bson.M{"_id": "jti:" + jti},
+		bson.M{"_id": "user:" + userID, "before": bson.M{"$gt": issuedAt.Truncate(time.Second)}},
+	}})
+	if err != nil {
+		return false, err
+	}
+	return n > 0, nil
+}
+
+type memoryRevocation struct {
+	before    time.Time
+	expiresAt time.Time
+}
+
+type MemoryRevocationStore struct {
+	mu     sync.Mutex
+	tokens map[string]time.Time
+	users  map[string]memoryRevocation
+}
+
+func NewMemoryRevocationStore() *MemoryRevocationStore {
+	return &MemoryRevocationStore{tokens: map[string]time.Time{}, users: map[string]memoryRevocation{}}
+}
+
+func (m *MemoryRevocationStore) RevokeToken(_ context.Context, jti string, expiresAt time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.tokens[jti] = expiresAt
+	return nil
+}
+
+func (m *MemoryRevocationStore) RevokeUser(_ context.Context, userID string, before time.Time, expiresAt time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.users[userID] = memoryRevocation{before: before.Truncate(time.Second), expiresAt: expiresAt}
+	return nil
+}
+
+func (m *MemoryRevocationStore) IsRevoked(_ context.Context, jti string, userID string, issuedAt time.Time) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := time.Now()
+	if expiresAt, ok := m.tokens[jti]; ok {
+		if expiresAt.After(now) {
+			return true, nil
+		}
+		delete(m.tokens, jti)
+	}
+	rev, ok := m.users[userID]
+	if ok && !rev.expiresAt.After(now) {
+		delete(m.users, userID)
+		return false, nil
+	}
+	return ok && issuedAt.Truncate(time.Second).Before(rev.before), nil
+}
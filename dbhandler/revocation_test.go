@@ -0,0 +1,51 @@
+package dbhandler
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestMemoryRevocationStoreExpiry(t *testing.T) {
+	store := NewMemoryRevocationStore()
+	ctx := context.Background()
+	now := time.Now()
+	assert.NoError(t, store.RevokeToken(ctx, "live", now.Add(time.Hour)))
+	assert.NoError(t, store.RevokeToken(ctx, "expired", now.Add(-time.Second)))
+	assert.NoError(t, store.RevokeUser(ctx, "live@test.pl", now, now.Add(time.Hour)))
+	assert.NoError(t, store.RevokeUser(ctx, "expired@test.pl", now, now.Add(-time.Second)))
+
+	revoked, err := store.IsRevoked(ctx, "live", "", now)
+	assert.NoError(t, err)
+	assert.True(t, revoked)
+	revoked, err = store.IsRevoked(ctx, "expired", "", now)
+	assert.NoError(t, err)
+	assert.False(t, revoked)
+	revoked, err = store.IsRevoked(ctx, "", "live@test.pl", now.Add(-time.Minute))
+	assert.NoError(t, err)
+	assert.True(t, revoked)
+	revoked, err = store.IsRevoked(ctx, "", "live@test.pl", now.Add(time.Minute))
+	assert.NoError(t, err)
+	assert.False(t, revoked)
+	revoked, err = store.IsRevoked(ctx, "", "expired@test.pl", now.Add(-time.Minute))
+	assert.NoError(t, err)
+	assert.False(t, revoked)
+}
+
+func TestMemoryRevocationStoreSameSecond(t *testing.T) {
+	store := NewMemoryRevocationStore()
+	ctx := context.Background()
+	second := time.Now().Truncate(time.Second)
+	assert.NoError(t, store.RevokeUser(ctx, "test@test.pl", second.Add(700*time.Millisecond), second.Add(time.Hour)))
+
+	revoked, err := store.IsRevoked(ctx, "", "test@test.pl", second)
+	assert.NoError(t, err)
+	assert.False(t, revoked)
+	revoked, err = store.IsRevoked(ctx, "", "test@test.pl", second.Add(900*time.Millisecond))
+	assert.NoError(t, err)
+	assert.False(t, revoked)
+	revoked, err = store.IsRevoked(ctx, "", "test@test.pl", second.Add(-time.Second))
+	assert.NoError(t, err)
+	assert.True(t, revoked)
+}
@@ -0,0 +1,89 @@
+package dbhandler
+
+import (
+	"UserStorage/models"
+	"context"
+	"errors"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+	"regexp"
+	"slices"
+)
+
+const (
+	searchIndexName = "search_text"
+
+	errCodeIndexNotFound = 27
+)
+
+type userDocument struct {
+	models.User `bson:",inline"`
+	SearchText  string `bson:"searchText"`
+}
+
+func newUserDocument(usr models.User) userDocument {
+	return userDocument{User: usr, SearchText: userSearchText(usr)}
+}
+
+func userSearchText(usr models.User) string {
+	return usr.Username + " " + usr.Email
+}
+
+func (m MongoHandler) SearchUsers(ctx context.Context, q string, limit int) ([]models.SearchHit, error) {
+	textOpts := options.Find().
+		SetProjection(bson.M{"password": 0, "searchText": 0, "score": bson.M{"$meta": "textScore"}}).
+		SetSort(bson.D{{Key: "score", Value: bson.M{"$meta": "textScore"}}}).
+		SetLimit(int64(limit))
+	hits, err := m.findHits(ctx, bson.M{"$text": bson.M{"$search": q}, "deletedAt": nil}, textOpts)
+	if err != nil {
+		return nil, err
+	}
+
+	prefix := bson.M{"$regex": "^" + regexp.QuoteMeta(q), "$options": "i"}
+	prefixOpts := options.Find().SetProjection(bson.M{"password": 0, "searchText": 0}).SetLimit(int64(limit))
+	prefixed, err := m.findHits(ctx, bson.M{"$or": bson.A{bson.M{"_id": prefix}, bson.M{"username": prefix}}, "deletedAt": nil}, prefixOpts)
+	if err != nil {
+		return nil, err
+	}
+	for _, hit := range prefixed {
+		if !slices.ContainsFunc(hits, func(h models.SearchHit) bool { return h.User.Email == hit.User.Email }) {
+			hits = append(hits, hit)
+		}
+	}
+	return hits, nil
+}
+
+func (m MongoHandler) findHits(ctx context.Context, filter bson.M, opts *options.FindOptionsBuilder) ([]models.SearchHit, error) {
+	cursor, err := m.coll.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	var hits []models.SearchHit
+	if err = cursor.All(ctx, &hits); err != nil {
+		return nil, err
+	}
+	return hits, nil
+}
+
+func (m MongoHandler) migrateSearch(ctx context.Context) error {
+	for _, name := range []string{searchIndexName, "searchGrams_1"} {
+		err := m.coll.Indexes().DropOne(ctx, name)
+		var cmdErr mongo.CommandError
+		if err != nil && !(errors.As(err, &cmdErr) && cmdErr.Code == errCodeIndexNotFound) {
+			return err
+		}
+	}
+	_, err := m.coll.UpdateMany(ctx, bson.M{"searchText": bson.M{"$exists": false}}, mongo.Pipeline{
+		{{Key: "$set", Value: bson.M{"searchText": bson.M{"$concat": bson.A{bson.M{"$ifNull": bson.A{"$username", ""}}, " ", "$_id"}}}}},
+		{{Key: "$unset", Value: "searchGrams"}},
+	})
+	if err != nil {
+		return err
+	}
+	_, err = m.coll.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "searchText", Value: "text"}},
+		Options: options.Index().SetName(searchIndexName).SetDefaultLanguage("none"),
+	})
+	return err
+}
@@ -0,0 +1,13 @@
+package dbhandler
+
+import (
+	"UserStorage/models"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestUserSearchText(t *testing.T) {
+	assert.Equal(t, userSearchText(models.User{Email: "john.doe@test.pl", Username: "jd"}), "jd john.doe@test.pl")
+	doc := newUserDocument(models.User{Email: "a@test.pl", Username: "Ann"})
+	assert.Equal(t, doc.SearchText, "Ann a@test.pl")
+}
@@ -0,0 +1,186 @@
+package dbhandler
+
+import (
+	"UserStorage/models"
+	"context"
+	"errors"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+	"sync"
+	"time"
+)
+
+type SessionStore interface {
+	CreateSession(ctx context.Context, session models.Session) error
+	ListSessions(ctx context.Context, userID string) ([]models.Session, error)
+	TouchSession(ctx context.Context, id string, seen time.Time) error
+	RotateSession(ctx context.Context, id string, refreshTokenID string, expiresAt time.Time) error
+	DeleteSession(ctx context.Context, userID string, id string) (models.Session, error)
+	DeleteUserSessions(ctx context.Context, userID string) error
+}
+
+type MongoSessionStore struct {
+	coll *mongo.Collection
+}
+
+func NewMongoSessionStore(db *mongo.Database) *MongoSessionStore {
+	col := db.Collection("sessions")
+	_, err := col.Indexes().CreateMany(context.Background(), []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "expiresAt", Value: 1}},
+			Options: options.Index().SetExpireAfterSeconds(0),
+		},
+		{
+			Keys: bson.D{{Key: "userID", Value: 1}},
+		},
+	})
+	if err != nil {
+		panic(err)
+	}
+	return &MongoSessionStore{col}
+}
+
+func (m MongoSessionStore) CreateSession(ctx context.Context, session models.Session) error {
+	_, err := m.coll.InsertOne(ctx, session)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (m MongoSessionStore) ListSessions(ctx context.Context, userID string) ([]models.Session, error) {
+	cur, err := m.coll.Find(ctx, bson.M{"userID": userID}, options.Find().SetSort(bson.D{{Key: "lastSeen", Value: -1}}))
+	if err != nil {
+		return nil, err
+	}
+	sessions := []models.Session{}
+	if err = cur.All(ctx, &sessions); err != nil {
+		return nil, err
+	}
+	return sessions, nil
+}
+
+func (m MongoSessionStore) TouchSession(ctx context.Context, id string, seen time.Time) error {
+	res, err := m.coll.UpdateOne(ctx, bson.M{"_id": id}, bson.M{"$max": bson.M{"lastSeen": seen}})
+	if err != nil {
+		return err
+	}
+	if res.MatchedCount == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (m MongoSessionStore) RotateSession(ctx context.Context, id string, refreshTokenID string, expiresAt time.Time) error {
+	res, err := m.coll.UpdateOne(ctx, bson.M{"_id": id}, bson.M{"$set": bson.M{
+		"refreshTokenID": refreshTokenID,
+		"expiresAt":      expiresAt,
+		"lastSeen":       time.Now(),
+	}})
+	if err != nil {
+		return err
+	}
+	if res.MatchedCount == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (m MongoSessionStore) DeleteSession(ctx context.Context, userID string, id string) (models.Session, error) {
+	var session models.Session
+	err := m.coll.FindOneAndDelete(ctx, bson.M{"_id": id, "userID": userID}).Decode(&session)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return models.Session{}, ErrNotFound
+	}
+	if err != nil {
+		return models.Session{}, err
+	}
+	return session, nil
+}
+
+func (m MongoSessionStore) DeleteUserSessions(ctx context.Context, userID string) error {
+	_, err := m.coll.DeleteMany(ctx, bson.M{"userID": userID})
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+type MemorySessionStore struct {
+	mu       sync.Mutex
+	sessions map[string]models.Session
+}
+
+func NewMemorySessionStore() *MemorySessionStore {
+	return &MemorySessionStore{sessions: map[string]models.Session{}}
+}
+
+func (m *MemorySessionStore) CreateSession(_ context.Context, session models.Session) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.sessions[session.ID] = session
+	return nil
+}
+
+func (m *MemorySessionStore) ListSessions(_ context.Context, userID string) ([]models.Session, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	sessions := []models.Session{}
+	for _, session := range m.sessions {
+		if session.UserID == userID {
+			sessions = append(sessions, session)
+		}
+	}
+	return sessions, nil
+}
+
+func (m *MemorySessionStore) TouchSession(_ context.Context, id string, seen time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	session, ok := m.sessions[id]
+	if !ok {
+		return ErrNotFound
+	}
+	if seen.After(session.LastSeen) {
+		session.LastSeen = seen
+	}
+	m.sessions[id] = session
+	return nil
+}
+
+func (m *MemorySessionStore) RotateSession(_ context.Context, id string, refreshTokenID string, expiresAt time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	session, ok := m.sessions[id]
+	if !ok {
+		return ErrNotFound
+	}
+	session.RefreshTokenID = refreshTokenID
+	session.ExpiresAt = expiresAt
+	session.LastSeen = time.Now()
+	m.sessions[id] = session
+	return nil
+}
+
+func (m *MemorySessionStore) DeleteSession(_ context.Context, userID string, id string) (models.Session, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	session, ok := m.sessions[id]
+	if !ok || session.UserID != userID {
+		return models.Session{}, ErrNotFound
+	}
+	delete(m.sessions, id)
+	return session, nil
+}
+
+func (m *MemorySessionStore) DeleteUserSessions(_ context.Context, userID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for id, session := range m.sessions {
+		if session.UserID == userID {
+			delete(m.sessions, id)
+		}
+	}
+	return nil
+}
@@ -2,13 +2,21 @@ package main
 
 import (
 	"UserStorage/dbhandler"
+	"UserStorage/models"
+	"UserStorage/oidc"
 	"UserStorage/queueHandler"
 	"UserStorage/secutiry"
+	"UserStorage/storage"
 	"UserStorage/user"
+	"context"
 	"flag"
 	"github.com/gin-gonic/gin"
 	"github.com/sirupsen/logrus"
+	"golang.org/x/crypto/bcrypt"
 	"os"
+	"slices"
+	"strings"
+	"time"
 )
 
 func main() {
@@ -16,6 +24,35 @@ func main() {
 	mongoURI := flag.String("mongo-uri", "", "mongo uri")
 	rabbitURI := flag.String("rabbit-uri", "", "rabbit uri")
 	secret := flag.String("secret", "", "secret for jwt")
+	signingKeys := flag.String("signing-keys", "", "comma separated PEM key files for jwt, key id is the file name")
+	signingKid := flag.String("signing-kid", "", "id of the key used to sign new tokens")
+	jwtIssuer := flag.String("jwt-issuer", "UserStorage", "issuer set on and required from tokens")
+	jwtAudience := flag.String("jwt-audience", "UserStorage", "comma separated audience set on and accepted from tokens")
+	jwtLeeway := flag.Duration("jwt-leeway", 30*time.Second, "allowed clock skew when validating tokens")
+	lockoutThreshold := flag.Int("lockout-threshold", 5, "failed logins before an account is locked")
+	ipLockoutThreshold := flag.Int("ip-lockout-threshold", 20, "failed logins before a client ip is locked")
+	lockoutBase := flag.Duration("lockout-base", time.Minute, "first lockout duration, doubled on every further lockout")
+	lockoutMax := flag.Duration("lockout-max", time.Hour, "maximum lockout duration")
+	passwordMinLength := flag.Int("password-min-length", 8, "minimum password length")
+	passwordClasses := flag.String("password-require", "", "comma separated character classes required in passwords: upper,lower,digit,symbol")
+	passwordDenyList := flag.String("password-denylist", "", "file with common passwords that are rejected, one per line")
+	passwordHash := flag.String("password-hash", secutiry.AlgorithmBcrypt, "password hashing algorithm: bcrypt or argon2id")
+	bcryptCost := flag.Int("bcrypt-cost", bcrypt.DefaultCost, "bcrypt cost for new password hashes")
+	argon2Memory := flag.Uint("argon2-memory", uint(secutiry.DefaultArgon2Params.Memory), "argon2id memory in KiB")
+	argon2Iterations := flag.Uint("argon2-iterations", uint(secutiry.DefaultArgon2Params.Iterations), "argon2id iterations")
+	argon2Parallelism := flag.Uint("argon2-parallelism", uint(secutiry.DefaultArgon2Params.Parallelism), "argon2id parallelism")
+	oidcIssuer := flag.String("oidc-issuer", "", "issuer url of the OpenID Connect provider, enables /auth/oidc")
+	oidcClientID := flag.String("oidc-client-id", "", "OpenID Connect client id")
+	oidcClientSecret := flag.String("oidc-client-secret", "", "OpenID Connect client secret")
+	oidcRedirectURL := flag.String("oidc-redirect-url", "", "public url of /auth/oidc/callback registered with the provider")
+	trashRetention := flag.Duration("trash-retention", 30*24*time.Hour, "how long deleted users can be restored before they are purged")
+	purgeInterval := flag.Duration("purge-interval", time.Hour, "how often deleted users past -trash-retention are purged, 0 disables the purger")
+	blobStore := flag.String("blob-store", "gridfs", "where uploaded file content is stored: gridfs or local")
+	blobDir := flag.String("blob-dir", "blobs", "directory for -blob-store local")
+	maxUploadSize := flag.Int64("max-upload-size", user.DefaultMaxUploadSize, "maximum size of an uploaded file in bytes")
+	adminEmail := flag.String("admin-email", "", "user promoted to admin at startup, created when missing")
+	adminPassword := flag.String("admin-password", "", "password of the -admin-email user when it has to be created")
+	adminAge := flag.Int("admin-age", 0, "age of the -admin-email user when it has to be created")
 	flag.Parse()
 	if *mongoURI == "" {
 		logger.Error("mongo-uri is not set")
@@ -27,28 +64,201 @@ func main() {
 		return
 	}
 
+	if *secret == "" && *signingKeys == "" {
+		logger.Error("secret or signing-keys must be set")
+		return
+	}
+
 	logger.SetOutput(os.Stdout)
 	logger.SetFormatter(&logrus.JSONFormatter{})
 
+	var keys []secutiry.SigningKey
+	for _, path := range strings.Split(*signingKeys, ",") {
+		if path == "" {
+			continue
+		}
+		key, err := secutiry.LoadPEMKey(path)
+		if err != nil {
+			logger.Error(err)
+			return
+		}
+		keys = append(keys, key)
+	}
+	if *signingKid != "" && !slices.ContainsFunc(keys, func(k secutiry.SigningKey) bool {
+		return k.ID == *signingKid && k.Private != nil
+	}) {
+		logger.Error("signing-kid does not name a private key from signing-keys")
+		return
+	}
+
+	if *passwordHash != secutiry.AlgorithmBcrypt && *passwordHash != secutiry.AlgorithmArgon2id {
+		logger.Error("password-hash must be bcrypt or argon2id")
+		return
+	}
+	if *bcryptCost < bcrypt.MinCost || *bcryptCost > bcrypt.MaxCost {
+		logger.Errorf("bcrypt-cost must be between %d and %d", bcrypt.MinCost, bcrypt.MaxCost)
+		return
+	}
+	classes := strings.Split(*passwordClasses, ",")
+	passwordPolicy := secutiry.PasswordPolicy{
+		MinLength:     *passwordMinLength,
+		RequireUpper:  slices.Contains(classes, "upper"),
+		RequireLower:  slices.Contains(classes, "lower"),
+		RequireDigit:  slices.Contains(classes, "digit"),
+		RequireSymbol: slices.Contains(classes, "symbol"),
+	}
+	if *passwordDenyList != "" {
+		denyList, err := secutiry.LoadDenyList(*passwordDenyList)
+		if err != nil {
+			logger.Error(err)
+			return
+		}
+		passwordPolicy.DenyList = denyList
+	}
+	argon2Params := secutiry.DefaultArgon2Params
+	argon2Params.Memory = uint32(*argon2Memory)
+	argon2Params.Iterations = uint32(*argon2Iterations)
+	argon2Params.Parallelism = uint8(*argon2Parallelism)
+
+	if *trashRetention < 0 {
+		logger.Error("trash-retention must not be negative")
+		return
+	}
+	if *purgeInterval < 0 {
+		logger.Error("purge-interval must not be negative")
+		return
+	}
+
+	if *blobStore != "gridfs" && *blobStore != "local" {
+		logger.Error("blob-store must be gridfs or local")
+		return
+	}
+
 	rabbitHandl := queueHandler.NewRabbitHandler(*rabbitURI, logger)
-	auth := secutiry.NewAuthObj([]byte(*secret))
+	dbHan := dbhandler.NewMongoHandler(*mongoURI)
+	auth := secutiry.NewAuthObj([]byte(*secret),
+		secutiry.WithSigningKeys(*signingKid, keys...),
+		secutiry.WithIssuer(*jwtIssuer),
+		secutiry.WithAudience(strings.Split(*jwtAudience, ",")...),
+		secutiry.WithLeeway(*jwtLeeway),
+		secutiry.WithRevocationStore(dbhandler.NewMongoRevocationStore(dbHan.Database())),
+		secutiry.WithAPIKeyStore(dbhandler.NewMongoAPIKeyStore(dbHan.Database())),
+		secutiry.WithSessionStore(dbhandler.NewMongoSessionStore(dbHan.Database())),
+		secutiry.WithUserLookup(dbHan),
+	)
 
 	r := gin.Default()
-	dbHan := dbhandler.NewMongoHandler(*mongoURI)
-	usrHandler := user.NewUserHandler(logger, dbHan, rabbitHandl, auth)
+	r.GET("/.well-known/jwks.json", auth.JWKS)
+	opts := []user.Option{
+		user.WithLockoutPolicy(
+			secutiry.LockoutPolicy{Threshold: *lockoutThreshold, BaseDelay: *lockoutBase, MaxDelay: *lockoutMax},
+			secutiry.LockoutPolicy{Threshold: *ipLockoutThreshold, BaseDelay: *lockoutBase, MaxDelay: *lockoutMax},
+		),
+		user.WithPasswordPolicy(passwordPolicy),
+		user.WithPasswordHasher(secutiry.PasswordHasher{Algorithm: *passwordHash, BcryptCost: *bcryptCost, Argon2: argon2Params}),
+		user.WithAuditLog(dbhandler.NewMongoAuditStore(dbHan.Database())),
+	}
+	if *blobStore == "local" {
+		blobs, err := storage.NewLocalBlobStore(*blobDir)
+		if err != nil {
+			logger.Error(err)
+			return
+		}
+		opts = append(opts, user.WithBlobStore(blobs, *maxUploadSize))
+	} else {
+		opts = append(opts, user.WithBlobStore(storage.NewGridFSBlobStore(dbHan.Database()), *maxUploadSize))
+	}
+	if *oidcIssuer != "" {
+		oidcClient, err := oidc.NewClient(context.Background(), oidc.Config{
+			Issuer:       *oidcIssuer,
+			ClientID:     *oidcClientID,
+			ClientSecret: *oidcClientSecret,
+			RedirectURL:  *oidcRedirectURL,
+		})
+		if err != nil {
+			logger.Error(err)
+			return
+		}
+		opts = append(opts, user.WithOIDC(oidcClient))
+	}
+	usrHandler := user.NewUserHandler(logger, dbHan, rabbitHandl, auth, opts...)
+	if *adminEmail != "" {
+		err := usrHandler.BootstrapAdmin(context.Background(), models.NewUserRequest{
+			Email:    *adminEmail,
+			Username: *adminEmail,
+			Age:      *adminAge,
+			Password: *adminPassword,
+		})
+		if err != nil {
+			logger.Error(err)
+			return
+		}
+	}
+	if *purgeInterval > 0 {
+		go usrHandler.RunPurger(context.Background(), *trashRetention, *purgeInterval)
+	}
+
+	authGroup := r.Group("/auth")
+	{
+		authGroup.POST("/signup", usrHandler.Signup)
+		authGroup.POST("/signup/resend", usrHandler.ResendVerification)
+		authGroup.POST("/confirm", usrHandler.Confirm)
+		authGroup.POST("/login", usrHandler.Login)
+		authGroup.POST("/login/2fa", usrHandler.LoginTOTP)
+		authGroup.POST("/login/2fa/enroll", usrHandler.LoginTOTPEnroll)
+		authGroup.POST("/refresh", usrHandler.Refresh)
+		authGroup.POST("/logout", usrHandler.Logout)
+		authGroup.POST("/password/forgot", usrHandler.ForgotPassword)
+		authGroup.POST("/password/reset", usrHandler.ResetPassword)
+		authGroup.GET("/oidc/login", usrHandler.OIDCLogin)
+		authGroup.GET("/oidc/callback", usrHandler.OIDCCallback)
+		authGroup.POST("/token/downscope", auth.Auth(), usrHandler.Downscope)
+	}
 
 	usersGroup := r.Group("/users")
 	usersGroup.Use(auth.Auth())
 	{
-		usersGroup.GET("", usrHandler.GetAllUsers)
-		usersGroup.POST("", usrHandler.CreateUser)
-		usersGroup.GET("/:id", usrHandler.GetUser)
-		usersGroup.PUT("/:id", usrHandler.UpdateUser)
-		usersGroup.DELETE("/:id", usrHandler.DeleteUser)
-
-		usersGroup.GET("/:id/files", usrHandler.GetUserFiles)
-		usersGroup.POST("/:id/files", usrHandler.AddFileToUser)
-		usersGroup.DELETE("/:id/files", usrHandler.DeleteFilesFromUser)
+		usersGroup.GET("", secutiry.RequireRole(models.RoleAdmin), auth.RequireScopes(secutiry.ScopeUsersRead), usrHandler.GetAllUsers)
+		usersGroup.POST("", secutiry.RequireRole(models.RoleAdmin), auth.RequireScopes(secutiry.ScopeUsersWrite), usrHandler.CreateUser)
+		usersGroup.GET("/export", secutiry.RequireRole(models.RoleAdmin), auth.RequireScopes(secutiry.ScopeUsersRead), usrHandler.ExportUsers)
+		usersGroup.POST("/import", secutiry.RequireRole(models.RoleAdmin), auth.RequireScopes(secutiry.ScopeUsersWrite), usrHandler.ImportUsers)
+		usersGroup.GET("/search", secutiry.RequireRole(models.RoleAdmin), auth.RequireScopes(secutiry.ScopeUsersRead), usrHandler.SearchUsers)
+
+		userGroup := usersGroup.Group("/:id")
+		userGroup.Use(secutiry.SelfOrAdmin("id"))
+		userGroup.GET("", auth.RequireScopes(secutiry.ScopeUsersRead), usrHandler.GetUser)
+		userGroup.PUT("", auth.RequireScopes(secutiry.ScopeUsersWrite), usrHandler.UpdateUser)
+		userGroup.PATCH("", auth.RequireScopes(secutiry.ScopeUsersWrite), usrHandler.PatchUser)
+		userGroup.DELETE("", auth.RequireScopes(secutiry.ScopeUsersWrite), usrHandler.DeleteUser)
+		userGroup.GET("/data-export", auth.RequireScopes(secutiry.ScopeUsersRead), usrHandler.ExportUserData)
+		userGroup.POST("/erasure", auth.RequireScopes(secutiry.ScopeUsersWrite), usrHandler.EraseUser)
+		userGroup.GET("/history", auth.RequireScopes(secutiry.ScopeUsersRead), usrHandler.GetUserHistory)
+		userGroup.POST("/password", auth.RequireScopes(secutiry.ScopeUsersWrite), usrHandler.ChangePassword)
+		userGroup.POST("/2fa/enroll", auth.RequireScopes(secutiry.ScopeUsersWrite), usrHandler.EnrollTOTP)
+		userGroup.POST("/2fa/confirm", auth.RequireScopes(secutiry.ScopeUsersWrite), usrHandler.ConfirmTOTP)
+		userGroup.DELETE("/2fa", auth.RequireScopes(secutiry.ScopeUsersWrite), usrHandler.DisableTOTP)
+		userGroup.GET("/apikeys", auth.RequireScopes(secutiry.ScopeUsersRead), usrHandler.GetAPIKeys)
+		userGroup.POST("/apikeys", auth.RequireScopes(secutiry.ScopeUsersWrite), usrHandler.CreateAPIKey)
+		userGroup.DELETE("/apikeys/:keyId", auth.RequireScopes(secutiry.ScopeUsersWrite), usrHandler.DeleteAPIKey)
+		userGroup.GET("/sessions", auth.RequireScopes(secutiry.ScopeUsersRead), usrHandler.GetSessions)
+		userGroup.DELETE("/sessions/:sid", auth.RequireScopes(secutiry.ScopeUsersWrite), usrHandler.DeleteSession)
+
+		userGroup.GET("/files", auth.RequireScopes(secutiry.ScopeFilesRead), usrHandler.GetUserFiles)
+		userGroup.POST("/files", auth.RequireScopes(secutiry.ScopeFilesWrite), usrHandler.AddFileToUser)
+		userGroup.GET("/files/:fileId", auth.RequireScopes(secutiry.ScopeFilesRead), usrHandler.DownloadFile)
+		userGroup.DELETE("/files", auth.RequireScopes(secutiry.ScopeFilesWrite), usrHandler.DeleteFilesFromUser)
+	}
+
+	adminGroup := r.Group("/admin")
+	adminGroup.Use(auth.Auth(), secutiry.RequireRole(models.RoleAdmin), auth.RequireScopes(secutiry.ScopeUsersWrite))
+	{
+		adminGroup.POST("/revocations", usrHandler.Revoke)
+		adminGroup.POST("/service-accounts", usrHandler.CreateServiceAccount)
+		adminGroup.POST("/users/:id/unlock", usrHandler.Unlock)
+		adminGroup.POST("/users/:id/restore", usrHandler.RestoreUser)
+		adminGroup.POST("/users/:id/revert", usrHandler.RevertUser)
+		adminGroup.GET("/audit", usrHandler.GetAuditLog)
+		adminGroup.GET("/audit/verify", usrHandler.VerifyAuditLog)
 	}
 
 	err := r.Run(":8080")
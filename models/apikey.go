@@ -0,0 +1,30 @@
+package models
+
+import "time"
+
+type APIKey struct {
+	ID        string    `json:"id" bson:"_id"`
+	Hash      string    `json:"-" bson:"hash"`
+	UserID    string    `json:"userID" bson:"userID"`
+	Name      string    `json:"name" bson:"name"`
+	Role      string    `json:"role" bson:"role"`
+	Scopes    []string  `json:"scopes" bson:"scopes"`
+	CreatedAt time.Time `json:"createdAt" bson:"createdAt"`
+	ExpiresAt time.Time `json:"expiresAt" bson:"expiresAt"`
+}
+
+type APIKeyRequest struct {
+	Name      string   `json:"name" binding:"required"`
+	Scopes    []string `json:"scopes" binding:"required"`
+	ExpiresIn int      `json:"expiresIn"`
+}
+
+type NewAPIKeyResponse struct {
+	APIKey
+	Key string `json:"key"`
+}
+
+type ServiceAccountRequest struct {
+	Name string `json:"name" binding:"required"`
+	Role string `json:"role"`
+}
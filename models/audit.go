@@ -0,0 +1,53 @@
+package models
+
+import "time"
+
+const (
+	AuditUserCreated  = "user.created"
+	AuditUserUpdated  = "user.updated"
+	AuditUserDeleted  = "user.deleted"
+	AuditUserRestored = "user.restored"
+	AuditUserErased   = "user.erased"
+	AuditFileAdded    = "user.file.added"
+	AuditFilesDeleted = "user.files.deleted"
+
+	DefaultAuditPageSize = 100
+	MaxAuditPageSize     = 1000
+)
+
+type AuditEntry struct {
+	Seq       int64         `json:"seq" bson:"_id"`
+	Timestamp time.Time     `json:"timestamp" bson:"timestamp"`
+	Action    string        `json:"action" bson:"action"`
+	Actor     string        `json:"actor" bson:"actor"`
+	Target    string        `json:"target" bson:"target"`
+	IP        string        `json:"ip" bson:"ip"`
+	RequestID string        `json:"requestID" bson:"requestID"`
+	Changes   []FieldChange `json:"changes" bson:"changes"`
+	PrevHash  string        `json:"prevHash" bson:"prevHash"`
+	Hash      string        `json:"hash" bson:"hash"`
+}
+
+type FieldChange struct {
+	Field    string `json:"field" bson:"field"`
+	Before   any    `json:"before,omitempty" bson:"before,omitempty"`
+	After    any    `json:"after,omitempty" bson:"after,omitempty"`
+	Redacted bool   `json:"redacted,omitempty" bson:"redacted,omitempty"`
+	Sealed   string `json:"sealed,omitempty" bson:"sealed,omitempty"`
+}
+
+type AuditQuery struct {
+	Actor    string
+	Target   string
+	Action   string
+	From     time.Time
+	To       time.Time
+	AfterSeq int64
+	Limit    int
+}
+
+type AuditVerification struct {
+	Valid    bool  `json:"valid"`
+	Checked  int64 `json:"checked"`
+	BrokenAt int64 `json:"brokenAt,omitempty"`
+}
@@ -1,8 +1,24 @@
 package models
 
+import "time"
+
 type Event struct {
 	EventType string `json:"eventType"`
 	UserID    string `json:"userID"`
 	Age       int    `json:"age"`
 	NoFiles   int    `json:"noFiles"`
 }
+
+type TokenEvent struct {
+	EventType string    `json:"eventType"`
+	UserID    string    `json:"userID"`
+	Token     string    `json:"token"`
+	ExpiresAt time.Time `json:"expiresAt"`
+}
+
+type LockoutEvent struct {
+	EventType   string    `json:"eventType"`
+	UserID      string    `json:"userID"`
+	IP          string    `json:"ip"`
+	LockedUntil time.Time `json:"lockedUntil"`
+}
@@ -0,0 +1,14 @@
+package models
+
+import "time"
+
+type UserRevision struct {
+	UserID    string    `json:"userID" bson:"userID"`
+	Version   int64     `json:"version" bson:"version"`
+	ChangedAt time.Time `json:"changedAt" bson:"changedAt"`
+	User      User      `json:"user" bson:"user"`
+}
+
+type RevertRequest struct {
+	Version int64 `json:"version" binding:"required"`
+}
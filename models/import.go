@@ -0,0 +1,21 @@
+package models
+
+const (
+	ImportCreated = "created"
+	ImportValid   = "valid"
+	ImportFailed  = "error"
+)
+
+type ImportResult struct {
+	Row    int    `json:"row"`
+	Email  string `json:"email,omitempty"`
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+type ImportReport struct {
+	DryRun    bool           `json:"dryRun"`
+	Succeeded int            `json:"succeeded"`
+	Failed    int            `json:"failed"`
+	Results   []ImportResult `json:"results"`
+}
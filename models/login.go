@@ -4,3 +4,53 @@ type LoginRequest struct {
 	Username string `json:"username" binding:"required"`
 	Password string `json:"password" binding:"required"`
 }
+
+type RefreshRequest struct {
+	RefreshToken string `json:"refreshToken" binding:"required"`
+}
+
+type ConfirmRequest struct {
+	Token string `json:"token" binding:"required"`
+}
+
+type ResendVerificationRequest struct {
+	Email string `json:"email" binding:"required"`
+}
+
+type RevokeRequest struct {
+	JTI    string `json:"jti"`
+	UserID string `json:"userID"`
+}
+
+type ChangePasswordRequest struct {
+	OldPassword string `json:"oldPassword" binding:"required"`
+	NewPassword string `json:"newPassword" binding:"required"`
+}
+
+type ForgotPasswordRequest struct {
+	Email string `json:"email" binding:"required"`
+}
+
+type ResetPasswordRequest struct {
+	Token       string `json:"token" binding:"required"`
+	NewPassword string `json:"newPassword" binding:"required"`
+}
+
+type LoginTOTPRequest struct {
+	Challenge    string `json:"challenge" binding:"required"`
+	Code         string `json:"code"`
+	RecoveryCode string `json:"recoveryCode"`
+}
+
+type LoginTOTPEnrollRequest struct {
+	Challenge string `json:"challenge" binding:"required"`
+	Code      string `json:"code" binding:"required"`
+}
+
+type TOTPCodeRequest struct {
+	Code string `json:"code" binding:"required"`
+}
+
+type DownscopeRequest struct {
+	Scopes []string `json:"scopes" binding:"required"`
+}
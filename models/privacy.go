@@ -0,0 +1,13 @@
+package models
+
+import "time"
+
+type DataExport struct {
+	ExportedAt time.Time      `json:"exportedAt"`
+	User       User           `json:"user"`
+	Files      []File         `json:"files"`
+	Sessions   []Session      `json:"sessions"`
+	APIKeys    []APIKey       `json:"apiKeys"`
+	History    []UserRevision `json:"history"`
+	Audit      []AuditEntry   `json:"audit"`
+}
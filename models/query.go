@@ -0,0 +1,31 @@
+package models
+
+const (
+	SortEmail    = "email"
+	SortUsername = "username"
+	SortAge      = "age"
+
+	DefaultPageSize = 50
+	MaxPageSize     = 500
+)
+
+type UserQuery struct {
+	Limit          int
+	Cursor         string
+	Sort           string
+	Desc           bool
+	UsernamePrefix string
+	MinAge         *int
+	MaxAge         *int
+	HasFiles       *bool
+	IncludeDeleted bool
+}
+
+type UserPage struct {
+	Users      []User
+	NextCursor string
+}
+
+func ValidSort(sort string) bool {
+	return sort == SortEmail || sort == SortUsername || sort == SortAge
+}
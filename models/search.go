@@ -0,0 +1,12 @@
+package models
+
+type Highlight struct {
+	Field    string `json:"field"`
+	Fragment string `json:"fragment"`
+}
+
+type SearchHit struct {
+	User      User       `json:"user" bson:",inline"`
+	Score     float64    `json:"score" bson:"score"`
+	Highlight *Highlight `json:"highlight,omitempty" bson:"-"`
+}
@@ -0,0 +1,15 @@
+package models
+
+import "time"
+
+type Session struct {
+	ID             string    `json:"id" bson:"_id"`
+	UserID         string    `json:"userID" bson:"userID"`
+	IP             string    `json:"ip" bson:"ip"`
+	UserAgent      string    `json:"userAgent" bson:"userAgent"`
+	CreatedAt      time.Time `json:"createdAt" bson:"createdAt"`
+	LastSeen       time.Time `json:"lastSeen" bson:"lastSeen"`
+	RefreshTokenID string    `json:"-" bson:"refreshTokenID"`
+	ExpiresAt      time.Time `json:"expiresAt" bson:"expiresAt"`
+	Current        bool      `json:"current" bson:"-"`
+}
@@ -0,0 +1,21 @@
+package models
+
+import "time"
+
+const (
+	TokenRefresh       = "refresh"
+	TokenVerification  = "verification"
+	TokenPasswordReset = "passwordReset"
+	TokenMFAChallenge  = "mfaChallenge"
+	TokenOIDCState     = "oidcState"
+	TokenMFAEnrollment = "mfaEnrollment"
+)
+
+type Token struct {
+	ID        string    `bson:"_id"`
+	Kind      string    `bson:"kind"`
+	UserID    string    `bson:"userID"`
+	ExpiresAt time.Time `bson:"expiresAt"`
+
+	Data map[string]string `bson:"data,omitempty"`
+}
@@ -1,13 +1,83 @@
 package models
 
+import "time"
+
+const (
+	UserStatusPending = "pending"
+	UserStatusActive  = "active"
+
+	RoleAdmin = "admin"
+	RoleUser  = "user"
+
+	UserKindService      = "service"
+	ServiceAccountPrefix = "svc:"
+)
+
 type User struct {
-	Email    string `json:"email" bson:"_id"`
-	Username string `json:"username" bson:"username"`
-	Age      int    `json:"age" bson:"age"`
-	Password string `json:"-" bson:"password"`
-	Files    []File `json:"files" bson:"files"`
+	Email    string  `json:"email" bson:"_id"`
+	Username string  `json:"username" bson:"username"`
+	Age      int     `json:"age" bson:"age"`
+	Password string  `json:"-" bson:"password"`
+	Files    []File  `json:"files" bson:"files"`
+	Status   string  `json:"status" bson:"status"`
+	Role     string  `json:"role" bson:"role"`
+	Lockout  Lockout `json:"lockout" bson:"lockout"`
+	TOTP     TOTP    `json:"totp" bson:"totp"`
+	Version  int64   `json:"version" bson:"version"`
+	Kind     string  `json:"kind,omitempty" bson:"kind,omitempty"`
+
+	DeletedAt *time.Time `json:"deletedAt,omitempty" bson:"deletedAt,omitempty"`
+
+	Identities []Identity `json:"identities,omitempty" bson:"identities,omitempty"`
+}
+
+type NewUserRequest struct {
+	Email    string `json:"email"`
+	Username string `json:"username"`
+	Age      int    `json:"age"`
+	Role     string `json:"role"`
+	Password string `json:"password"`
+}
+
+type Lockout struct {
+	FailedAttempts int       `json:"failedAttempts" bson:"failedAttempts"`
+	Count          int       `json:"count" bson:"count"`
+	LockedUntil    time.Time `json:"lockedUntil" bson:"lockedUntil"`
+}
+
+type TOTP struct {
+	Secret        string   `json:"-" bson:"secret"`
+	Enabled       bool     `json:"enabled" bson:"enabled"`
+	RecoveryCodes []string `json:"-" bson:"recoveryCodes"`
+	LastStep      int64    `json:"-" bson:"lastStep"`
+}
+
+func ValidRole(role string) bool {
+	return role == RoleAdmin || role == RoleUser
+}
+
+func EffectiveRole(role string) string {
+	if role == "" {
+		return RoleUser
+	}
+	return role
+}
+
+type Identity struct {
+	Issuer  string `json:"issuer" bson:"issuer"`
+	Subject string `json:"subject" bson:"subject"`
 }
 
 type File struct {
-	Name string `json:"name" bson:"name"`
+	ID          string     `json:"id,omitempty" bson:"id,omitempty"`
+	Name        string     `json:"name" bson:"name"`
+	ContentType string     `json:"contentType,omitempty" bson:"contentType,omitempty"`
+	Size        int64      `json:"size,omitempty" bson:"size,omitempty"`
+	UploadedAt  *time.Time `json:"uploadedAt,omitempty" bson:"uploadedAt,omitempty"`
+}
+
+type UserUpdate struct {
+	Username string `json:"username"`
+	Age      int    `json:"age"`
+	Role     string `json:"role"`
 }
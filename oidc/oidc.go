@@ -0,0 +1,231 @@
+package oidc
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/golang-jwt/jwt/v5"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+type Config struct {
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	RedirectURL  string
+	Scopes       []string
+	HTTPClient   *http.Client
+
+	JWKSRefreshInterval time.Duration
+}
+
+type Discovery struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+type IDTokenClaims struct {
+	Email         string `json:"email"`
+	EmailVerified bool   `json:"email_verified"`
+	Name          string `json:"name"`
+	Birthdate     string `json:"birthdate,omitempty"`
+	Nonce         string `json:"nonce"`
+	jwt.RegisteredClaims
+}
+
+func (c IDTokenClaims) Age(now time.Time) (int, bool) {
+	born, err := time.Parse(time.DateOnly, c.Birthdate)
+	if err != nil || born.Year() == 0 || born.After(now) {
+		return 0, false
+	}
+	age := now.Year() - born.Year()
+	if now.Month() < born.Month() || (now.Month() == born.Month() && now.Day() < born.Day()) {
+		age--
+	}
+	return age, true
+}
+
+type Client struct {
+	cfg       Config
+	discovery Discovery
+	http      *http.Client
+
+	mu      sync.Mutex
+	keys    map[string]any
+	fetched time.Time
+}
+
+func NewClient(ctx context.Context, cfg Config) (*Client, error) {
+	if cfg.HTTPClient == nil {
+		cfg.HTTPClient = &http.Client{Timeout: 10 * time.Second}
+	}
+	if cfg.JWKSRefreshInterval == 0 {
+		cfg.JWKSRefreshInterval = time.Minute
+	}
+	if len(cfg.Scopes) == 0 {
+		cfg.Scopes = []string{"openid", "email", "profile"}
+	}
+	c := &Client{cfg: cfg, http: cfg.HTTPClient, keys: map[string]any{}}
+	wellKnown := strings.TrimSuffix(cfg.Issuer, "/") + "/.well-known/openid-configuration"
+	if err := c.getJSON(ctx, wellKnown, &c.discovery); err != nil {
+		return nil, fmt.Errorf("oidc discovery: %w", err)
+	}
+	if c.discovery.Issuer != cfg.Issuer {
+		return nil, fmt.Errorf("oidc discovery: issuer %q does not match %q", c.discovery.Issuer, cfg.Issuer)
+	}
+	return c, nil
+}
+
+func (c *Client) Issuer() string {
+	return c.discovery.Issuer
+}
+
+func NewPKCE() (verifier string, challenge string, err error) {
+	buf := make([]byte, 32)
+	if _, err = rand.Read(buf); err != nil {
+		return "", "", err
+	}
+	verifier = base64.RawURLEncoding.EncodeToString(buf)
+	return verifier, S256Challenge(verifier), nil
+}
+
+func S256Challenge(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+func (c *Client) AuthCodeURL(state string, nonce string, challenge string) string {
+	v := url.Values{}
+	v.Set("response_type", "code")
+	v.Set("client_id", c.cfg.ClientID)
+	v.Set("redirect_uri", c.cfg.RedirectURL)
+	v.Set("scope", strings.Join(c.cfg.Scopes, " "))
+	v.Set("state", state)
+	v.Set("nonce", nonce)
+	v.Set("code_challenge", challenge)
+	v.Set("code_challenge_method", "S256")
+	sep := "?"
+	if strings.Contains(c.discovery.AuthorizationEndpoint, "?") {
+		sep = "&"
+	}
+	return c.discovery.AuthorizationEndpoint + sep + v.Encode()
+}
+
+func (c *Client) Exchange(ctx context.Context, code string, verifier string) (string, error) {
+	form := url.Values{}
+	form.Set("grant_type", "authorization_code")
+	form.Set("code", code)
+	form.Set("redirect_uri", c.cfg.RedirectURL)
+	form.Set("client_id", c.cfg.ClientID)
+	form.Set("code_verifier", verifier)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.discovery.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	if c.cfg.ClientSecret != "" {
+		req.SetBasicAuth(url.QueryEscape(c.cfg.ClientID), url.QueryEscape(c.cfg.ClientSecret))
+	}
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	var out struct {
+		IDToken          string `json:"id_token"`
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description"`
+	}
+	if err = json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		return "", fmt.Errorf("oidc token response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("oidc token request failed: %s %s", out.Error, out.ErrorDescription)
+	}
+	if out.IDToken == "" {
+		return "", errors.New("oidc token response has no id_token")
+	}
+	return out.IDToken, nil
+}
+
+func (c *Client) Verify(ctx context.Context, rawIDToken string, nonce string) (*IDTokenClaims, error) {
+	claims := &IDTokenClaims{}
+	_, err := jwt.ParseWithClaims(rawIDToken, claims, func(token *jwt.Token) (interface{}, error) {
+		kid, _ := token.Header["kid"].(string)
+		return c.key(ctx, kid)
+	},
+		jwt.WithValidMethods([]string{"RS256", "ES256"}),
+		jwt.WithIssuer(c.discovery.Issuer),
+		jwt.WithAudience(c.cfg.ClientID),
+		jwt.WithExpirationRequired(),
+		jwt.WithIssuedAt(),
+	)
+	if err != nil {
+		return nil, err
+	}
+	if claims.Subject == "" {
+		return nil, errors.New("id token has no subject")
+	}
+	if claims.Nonce != nonce {
+		return nil, errors.New("id token nonce mismatch")
+	}
+	return claims, nil
+}
+
+func (c *Client) key(ctx context.Context, kid string) (any, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if key, ok := c.keys[kid]; ok {
+		return key, nil
+	}
+	if !c.fetched.IsZero() && time.Since(c.fetched) < c.cfg.JWKSRefreshInterval {
+		return nil, fmt.Errorf("unknown key id: %q", kid)
+	}
+	c.fetched = time.Now()
+	var set struct {
+		Keys []JWK `json:"keys"`
+	}
+	if err := c.getJSON(ctx, c.discovery.JWKSURI, &set); err != nil {
+		return nil, fmt.Errorf("oidc jwks: %w", err)
+	}
+	keys := map[string]any{}
+	for _, jwk := range set.Keys {
+		key, err := jwk.PublicKey()
+		if err != nil {
+			continue
+		}
+		keys[jwk.Kid] = key
+	}
+	c.keys = keys
+	key, ok := keys[kid]
+	if !ok {
+		return nil, fmt.Errorf("unknown key id: %q", kid)
+	}
+	return key, nil
+}
+
+func (c *Client) getJSON(ctx context.Context, url string, out any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("GET %s: %s", url, resp.Status)
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
@@ -0,0 +1,130 @@
+package oidc_test
+
+import (
+	"UserStorage/oidc"
+	"UserStorage/oidc/oidctest"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+)
+
+func newClient(t *testing.T, provider *oidctest.Provider) *oidc.Client {
+	client, err := oidc.NewClient(context.Background(), oidc.Config{
+		Issuer:       provider.Issuer(),
+		ClientID:     provider.ClientID,
+		ClientSecret: provider.ClientSecret,
+		RedirectURL:  "http://localhost/auth/oidc/callback",
+		HTTPClient:   provider.Client(),
+	})
+	assert.NoError(t, err)
+	return client
+}
+
+func authorize(t *testing.T, provider *oidctest.Provider, authURL string) url.Values {
+	httpClient := provider.Client()
+	httpClient.CheckRedirect = func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }
+	resp, err := httpClient.Get(authURL)
+	assert.NoError(t, err)
+	defer resp.Body.Close()
+	assert.Equal(t, resp.StatusCode, http.StatusFound)
+	location, err := url.Parse(resp.Header.Get("Location"))
+	assert.NoError(t, err)
+	return location.Query()
+}
+
+func TestAuthorizationCodeFlow(t *testing.T) {
+	provider := oidctest.NewProvider("userstorage", "secret")
+	defer provider.Close()
+	provider.SetUser(oidctest.User{Subject: "ext-1", Email: "test@test.pl", EmailVerified: true})
+	client := newClient(t, provider)
+
+	verifier, challenge, err := oidc.NewPKCE()
+	assert.NoError(t, err)
+	query := authorize(t, provider, client.AuthCodeURL("state", "nonce", challenge))
+	assert.Equal(t, query.Get("state"), "state")
+
+	idToken, err := client.Exchange(context.Background(), query.Get("code"), verifier)
+	assert.NoError(t, err)
+	claims, err := client.Verify(context.Background(), idToken, "nonce")
+	assert.NoError(t, err)
+	assert.Equal(t, claims.Subject, "ext-1")
+	assert.Equal(t, claims.Email, "test@test.pl")
+	assert.True(t, claims.EmailVerified)
+
+	_, err = client.Verify(context.Background(), idToken, "other")
+	assert.Error(t, err)
+}
+
+func TestExchangeRejectsWrongVerifier(t *testing.T) {
+	provider := oidctest.NewProvider("userstorage", "secret")
+	defer provider.Close()
+	client := newClient(t, provider)
+
+	_, challenge, err := oidc.NewPKCE()
+	assert.NoError(t, err)
+	query := authorize(t, provider, client.AuthCodeURL("state", "nonce", challenge))
+	other, _, err := oidc.NewPKCE()
+	assert.NoError(t, err)
+	_, err = client.Exchange(context.Background(), query.Get("code"), other)
+	assert.Error(t, err)
+}
+
+func TestVerifyRejectsOtherIssuer(t *testing.T) {
+	provider := oidctest.NewProvider("userstorage", "secret")
+	defer provider.Close()
+	other := oidctest.NewProvider("userstorage", "secret")
+	defer other.Close()
+	client := newClient(t, provider)
+	otherClient := newClient(t, other)
+
+	verifier, challenge, err := oidc.NewPKCE()
+	assert.NoError(t, err)
+	query := authorize(t, other, otherClient.AuthCodeURL("state", "nonce", challenge))
+	idToken, err := otherClient.Exchange(context.Background(), query.Get("code"), verifier)
+	assert.NoError(t, err)
+	_, err = client.Verify(context.Background(), idToken, "nonce")
+	assert.Error(t, err)
+}
+
+func TestUnknownKeyIDRefetchIsRateLimited(t *testing.T) {
+	provider := oidctest.NewProvider("userstorage", "secret")
+	defer provider.Close()
+	client := newClient(t, provider)
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(t, err)
+	token := jwt.NewWithClaims(jwt.SigningMethodES256, oidc.IDTokenClaims{RegisteredClaims: jwt.RegisteredClaims{
+		Issuer:    provider.Issuer(),
+		Subject:   "ext-1",
+		Audience:  jwt.ClaimStrings{provider.ClientID},
+		ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Minute)),
+	}})
+	token.Header["kid"] = "unknown"
+	forged, err := token.SignedString(key)
+	assert.NoError(t, err)
+
+	for range 5 {
+		_, err = client.Verify(context.Background(), forged, "")
+		assert.Error(t, err)
+	}
+	assert.Equal(t, provider.JWKSRequests(), 1)
+}
+
+func TestIDTokenAge(t *testing.T) {
+	now := time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+	for birthdate, want := range map[string]int{"2008-10-18": 18, "2008-10-19": 17, "1990-01-01": 36} {
+		age, ok := oidc.IDTokenClaims{Birthdate: birthdate}.Age(now)
+		assert.True(t, ok)
+		assert.Equal(t, age, want)
+	}
+	for _, birthdate := range []string{"", "0000-05-01", "2030-01-01", "1990"} {
+		_, ok := oidc.IDTokenClaims{Birthdate: birthdate}.Age(now)
+		assert.False(t, ok)
+	}
+}
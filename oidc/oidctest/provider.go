@@ -0,0 +1,199 @@
+package oidctest
+
+import (
+	"UserStorage/oidc"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"github.com/golang-jwt/jwt/v5"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"time"
+)
+
+const keyID = "oidctest"
+
+type User struct {
+	Subject       string
+	Email         string
+	EmailVerified bool
+	Name          string
+	Birthdate     string
+}
+
+type Provider struct {
+	ClientID     string
+	ClientSecret string
+
+	server *httptest.Server
+	key    *rsa.PrivateKey
+
+	mu           sync.Mutex
+	user         User
+	codes        map[string]authRequest
+	jwksRequests int
+}
+
+type authRequest struct {
+	redirectURI string
+	nonce       string
+	challenge   string
+	user        User
+}
+
+func NewProvider(clientID string, clientSecret string) *Provider {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		panic(err)
+	}
+	p := &Provider{ClientID: clientID, ClientSecret: clientSecret, key: key, codes: map[string]authRequest{}}
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /.well-known/openid-configuration", p.discovery)
+	mux.HandleFunc("GET /authorize", p.authorize)
+	mux.HandleFunc("POST /token", p.token)
+	mux.HandleFunc("GET /jwks", p.jwks)
+	p.server = httptest.NewServer(mux)
+	return p
+}
+
+func (p *Provider) Issuer() string {
+	return p.server.URL
+}
+
+func (p *Provider) Client() *http.Client {
+	return p.server.Client()
+}
+
+func (p *Provider) Close() {
+	p.server.Close()
+}
+
+func (p *Provider) SetUser(user User) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.user = user
+}
+
+func (p *Provider) JWKSRequests() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.jwksRequests
+}
+
+func (p *Provider) discovery(w http.ResponseWriter, _ *http.Request) {
+	writeJSON(w, http.StatusOK, oidc.Discovery{
+		Issuer:                p.Issuer(),
+		AuthorizationEndpoint: p.Issuer() + "/authorize",
+		TokenEndpoint:         p.Issuer() + "/token",
+		JWKSURI:               p.Issuer() + "/jwks",
+	})
+}
+
+func (p *Provider) authorize(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	if q.Get("response_type") != "code" || q.Get("client_id") != p.ClientID {
+		http.Error(w, "invalid request", http.StatusBadRequest)
+		return
+	}
+	if q.Get("code_challenge") == "" || q.Get("code_challenge_method") != "S256" {
+		http.Error(w, "pkce required", http.StatusBadRequest)
+		return
+	}
+	redirect, err := url.Parse(q.Get("redirect_uri"))
+	if err != nil || q.Get("redirect_uri") == "" {
+		http.Error(w, "invalid redirect_uri", http.StatusBadRequest)
+		return
+	}
+	code := rand.Text()
+	p.mu.Lock()
+	p.codes[code] = authRequest{
+		redirectURI: q.Get("redirect_uri"),
+		nonce:       q.Get("nonce"),
+		challenge:   q.Get("code_challenge"),
+		user:        p.user,
+	}
+	p.mu.Unlock()
+	v := redirect.Query()
+	v.Set("code", code)
+	v.Set("state", q.Get("state"))
+	redirect.RawQuery = v.Encode()
+	http.Redirect(w, r, redirect.String(), http.StatusFound)
+}
+
+func (p *Provider) token(w http.ResponseWriter, r *http.Request) {
+	id, secret, ok := r.BasicAuth()
+	if !ok {
+		id, secret = r.PostFormValue("client_id"), r.PostFormValue("client_secret")
+	}
+	id, _ = url.QueryUnescape(id)
+	secret, _ = url.QueryUnescape(secret)
+	if id != p.ClientID || secret != p.ClientSecret {
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "invalid_client"})
+		return
+	}
+	code := r.PostFormValue("code")
+	p.mu.Lock()
+	req, found := p.codes[code]
+	delete(p.codes, code)
+	p.mu.Unlock()
+	if !found || r.PostFormValue("grant_type") != "authorization_code" || r.PostFormValue("redirect_uri") != req.redirectURI {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_grant"})
+		return
+	}
+	if oidc.S256Challenge(r.PostFormValue("code_verifier")) != req.challenge {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_grant", "error_description": "pkce verification failed"})
+		return
+	}
+	now := time.Now()
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, oidc.IDTokenClaims{
+		Email:         req.user.Email,
+		EmailVerified: req.user.EmailVerified,
+		Name:          req.user.Name,
+		Birthdate:     req.user.Birthdate,
+		Nonce:         req.nonce,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    p.Issuer(),
+			Subject:   req.user.Subject,
+			Audience:  jwt.ClaimStrings{p.ClientID},
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(5 * time.Minute)),
+		},
+	})
+	token.Header["kid"] = keyID
+	idToken, err := token.SignedString(p.key)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "server_error"})
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{
+		"access_token": rand.Text(),
+		"token_type":   "Bearer",
+		"expires_in":   300,
+		"id_token":     idToken,
+	})
+}
+
+func (p *Provider) jwks(w http.ResponseWriter, _ *http.Request) {
+	p.mu.Lock()
+	p.jwksRequests++
+	p.mu.Unlock()
+	pub := p.key.PublicKey
+	writeJSON(w, http.StatusOK, map[string][]oidc.JWK{"keys": {{
+		Kty: "RSA",
+		Kid: keyID,
+		Use: "sig",
+		Alg: "RS256",
+		N:   base64.RawURLEncoding.EncodeToString(pub.N.Bytes()),
+		E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes()),
+	}}})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,269 @@
+package patch
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+var (
+	ErrInvalidPatch = errors.New("invalid patch")
+	ErrTestFailed   = errors.New("patch test operation failed")
+)
+
+type Operation struct {
+	Op    string          `json:"op"`
+	Path  string          `json:"path"`
+	From  string          `json:"from,omitempty"`
+	Value json.RawMessage `json:"value,omitempty"`
+}
+
+func invalid(format string, args ...any) error {
+	return fmt.Errorf("%w: %s", ErrInvalidPatch, fmt.Sprintf(format, args...))
+}
+
+func ApplyJSONPatch(doc []byte, ops []Operation) ([]byte, error) {
+	var root any
+	if err := json.Unmarshal(doc, &root); err != nil {
+		return nil, err
+	}
+	for i, op := range ops {
+		var err error
+		root, err = applyOperation(root, op)
+		if err != nil {
+			return nil, fmt.Errorf("operation %d (%s %s): %w", i, op.Op, op.Path, err)
+		}
+	}
+	return json.Marshal(root)
+}
+
+func applyOperation(root any, op Operation) (any, error) {
+	path, err := parsePointer(op.Path)
+	if err != nil {
+		return nil, err
+	}
+	switch op.Op {
+	case "add", "replace", "test":
+		value, err := op.value()
+		if err != nil {
+			return nil, err
+		}
+		switch op.Op {
+		case "add":
+			return add(root, path, value)
+		case "replace":
+			return replace(root, path, value)
+		}
+		current, err := get(root, path)
+		if err != nil {
+			return nil, err
+		}
+		if !reflect.DeepEqual(current, value) {
+			return nil, ErrTestFailed
+		}
+		return root, nil
+	case "remove":
+		return remove(root, path)
+	case "move", "copy":
+		from, err := parsePointer(op.From)
+		if err != nil {
+			return nil, err
+		}
+		value, err := get(root, from)
+		if err != nil {
+			return nil, err
+		}
+		if op.Op == "copy" {
+			return add(root, path, deepCopy(value))
+		}
+		if len(path) > len(from) && slices.Equal(path[:len(from)], from) {
+			return nil, invalid("cannot move a value into one of its children")
+		}
+		if root, err = remove(root, from); err != nil {
+			return nil, err
+		}
+		return add(root, path, value)
+	}
+	return nil, invalid("unknown op %q", op.Op)
+}
+
+func (op Operation) value() (any, error) {
+	if len(op.Value) == 0 {
+		return nil, invalid("missing value")
+	}
+	var value any
+	if err := json.Unmarshal(op.Value, &value); err != nil {
+		return nil, invalid("value is not valid json: %v", err)
+	}
+	return value, nil
+}
+
+func parsePointer(pointer string) ([]string, error) {
+	if pointer == "" {
+		return nil, nil
+	}
+	if !strings.HasPrefix(pointer, "/") {
+		return nil, invalid("path %q must start with /", pointer)
+	}
+	tokens := strings.Split(pointer[1:], "/")
+	for i, token := range tokens {
+		tokens[i] = strings.ReplaceAll(strings.ReplaceAll(token, "~1", "/"), "~0", "~")
+	}
+	return tokens, nil
+}
+
+func arrayIndex(token string, length int, allowEnd bool) (int, error) {
+	if allowEnd && token == "-" {
+		return length, nil
+	}
+	i, err := strconv.Atoi(token)
+	if err != nil || i < 0 || (token != "0" && strings.HasPrefix(token, "0")) {
+		return 0, invalid("invalid array index %q", token)
+	}
+	if i > length || (!allowEnd && i == length) {
+		return 0, invalid("array index %d out of range", i)
+	}
+	return i, nil
+}
+
+func get(node any, path []string) (any, error) {
+	for _, token := range path {
+		switch n := node.(type) {
+		case map[string]any:
+			child, ok := n[token]
+			if !ok {
+				return nil, invalid("path member %q not found", token)
+			}
+			node = child
+		case []any:
+			i, err := arrayIndex(token, len(n), false)
+			if err != nil {
+				return nil, err
+			}
+			node = n[i]
+		default:
+			return nil, invalid("path member %q not found", token)
+		}
+	}
+	return node, nil
+}
+
+func update(node any, path []string, fn func(container any, key string) (any, error)) (any, error) {
+	if len(path) == 1 {
+		return fn(node, path[0])
+	}
+	switch n := node.(type) {
+	case map[string]any:
+		child, ok := n[path[0]]
+		if !ok {
+			return nil, invalid("path member %q not found", path[0])
+		}
+		updated, err := update(child, path[1:], fn)
+		if err != nil {
+			return nil, err
+		}
+		n[path[0]] = updated
+		return n, nil
+	case []any:
+		i, err := arrayIndex(path[0], len(n), false)
+		if err != nil {
+			return nil, err
+		}
+		updated, err := update(n[i], path[1:], fn)
+		if err != nil {
+			return nil, err
+		}
+		n[i] = updated
+		return n, nil
+	}
+	return nil, invalid("path member %q not found", path[0])
+}
+
+func add(root any, path []string, value any) (any, error) {
+	if len(path) == 0 {
+		return value, nil
+	}
+	return update(root, path, func(container any, key string) (any, error) {
+		switch n := container.(type) {
+		case map[string]any:
+			n[key] = value
+			return n, nil
+		case []any:
+			i, err := arrayIndex(key, len(n), true)
+			if err != nil {
+				return nil, err
+			}
+			return slices.Insert(n, i, value), nil
+		}
+		return nil, invalid("cannot add member %q to a scalar", key)
+	})
+}
+
+func remove(root any, path []string) (any, error) {
+	if len(path) == 0 {
+		return nil, invalid("cannot remove the whole document")
+	}
+	return update(root, path, func(container any, key string) (any, error) {
+		switch n := container.(type) {
+		case map[string]any:
+			if _, ok := n[key]; !ok {
+				return nil, invalid("path member %q not found", key)
+			}
+			delete(n, key)
+			return n, nil
+		case []any:
+			i, err := arrayIndex(key, len(n), false)
+			if err != nil {
+				return nil, err
+			}
+			return slices.Delete(n, i, i+1), nil
+		}
+		return nil, invalid("path member %q not found", key)
+	})
+}
+
+func replace(root any, path []string, value any) (any, error) {
+	if len(path) == 0 {
+		return value, nil
+	}
+	return update(root, path, func(container any, key string) (any, error) {
+		switch n := container.(type) {
+		case map[string]any:
+			if _, ok := n[key]; !ok {
+				return nil, invalid("path member %q not found", key)
+			}
+			n[key] = value
+			return n, nil
+		case []any:
+			i, err := arrayIndex(key, len(n), false)
+			if err != nil {
+				return nil, err
+			}
+			n[i] = value
+			return n, nil
+		}
+		return nil, invalid("path member %q not found", key)
+	})
+}
+
+func deepCopy(value any) any {
+	switch v := value.(type) {
+	case map[string]any:
+		out := make(map[string]any, len(v))
+		for key, child := range v {
+			out[key] = deepCopy(child)
+		}
+		return out
+	case []any:
+		out := make([]any, len(v))
+		for i, child := range v {
+			out[i] = deepCopy(child)
+		}
+		return out
+	}
+	return value
+}
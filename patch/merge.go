@@ -0,0 +1,33 @@
+package patch
+
+import "encoding/json"
+
+func MergePatch(doc []byte, patch []byte) ([]byte, error) {
+	var target, p any
+	if err := json.Unmarshal(doc, &target); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(patch, &p); err != nil {
+		return nil, invalid("merge patch is not valid json: %v", err)
+	}
+	return json.Marshal(merge(target, p))
+}
+
+func merge(target any, patch any) any {
+	p, ok := patch.(map[string]any)
+	if !ok {
+		return patch
+	}
+	t, ok := target.(map[string]any)
+	if !ok {
+		t = map[string]any{}
+	}
+	for key, value := range p {
+		if value == nil {
+			delete(t, key)
+			continue
+		}
+		t[key] = merge(t[key], value)
+	}
+	return t
+}
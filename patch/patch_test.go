@@ -0,0 +1,55 @@
+package patch
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestMergePatch(t *testing.T) {
+	out, err := MergePatch(
+		[]byte(`{"title":"Goodbye!","author":{"givenName":"John","familyName":"Doe"},"tags":["example","sample"],"content":"text"}`),
+		[]byte(`{"title":"Hello!","phoneNumber":"+01-123-456-7890","author":{"familyName":null},"tags":["example"]}`),
+	)
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"title":"Hello!","author":{"givenName":"John"},"tags":["example"],"content":"text","phoneNumber":"+01-123-456-7890"}`, string(out))
+}
+
+func TestMergePatchInvalid(t *testing.T) {
+	_, err := MergePatch([]byte(`{}`), []byte(`{`))
+	assert.ErrorIs(t, err, ErrInvalidPatch)
+}
+
+func TestApplyJSONPatch(t *testing.T) {
+	ops := []Operation{
+		{Op: "test", Path: "/a~1b", Value: []byte(`1`)},
+		{Op: "add", Path: "/list/-", Value: []byte(`"c"`)},
+		{Op: "add", Path: "/list/0", Value: []byte(`"z"`)},
+		{Op: "remove", Path: "/list/1"},
+		{Op: "replace", Path: "/obj/x", Value: []byte(`{"y":2}`)},
+		{Op: "copy", From: "/obj/x", Path: "/copy"},
+		{Op: "move", From: "/a~1b", Path: "/m~0n"},
+		{Op: "replace", Path: "/obj/x/y", Value: []byte(`3`)},
+	}
+	out, err := ApplyJSONPatch([]byte(`{"a/b":1,"list":["a","b"],"obj":{"x":null}}`), ops)
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"m~n":1,"list":["z","b","c"],"obj":{"x":{"y":3}},"copy":{"y":2}}`, string(out))
+}
+
+func TestApplyJSONPatchErrors(t *testing.T) {
+	doc := []byte(`{"a":{"b":[1]}}`)
+	cases := []Operation{
+		{Op: "replace", Path: "/missing", Value: []byte(`1`)},
+		{Op: "remove", Path: "/a/b/1"},
+		{Op: "add", Path: "/a/b/01", Value: []byte(`1`)},
+		{Op: "add", Path: "a", Value: []byte(`1`)},
+		{Op: "add", Path: "/a/c"},
+		{Op: "move", From: "/a", Path: "/a/c"},
+		{Op: "nope", Path: "/a"},
+	}
+	for _, op := range cases {
+		_, err := ApplyJSONPatch(doc, []Operation{op})
+		assert.ErrorIs(t, err, ErrInvalidPatch, op)
+	}
+	_, err := ApplyJSONPatch(doc, []Operation{{Op: "test", Path: "/a/b/0", Value: []byte(`2`)}})
+	assert.ErrorIs(t, err, ErrTestFailed)
+}
@@ -0,0 +1,109 @@
+package secutiry
+
+import (
+	"UserStorage/dbhandler"
+	"UserStorage/models"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"time"
+)
+
+const (
+	APIKeyPrefix = "usk_"
+
+	APIKeyTTL    = 90 * 24 * time.Hour
+	MaxAPIKeyTTL = 365 * 24 * time.Hour
+)
+
+var (
+	ErrAPIKeysDisabled     = errors.New("api keys are not configured")
+	ErrAPIKeyOwnerInactive = errors.New("api key owner is not active")
+)
+
+type UserLookup interface {
+	GetUser(ctx context.Context, id string) (models.User, error)
+}
+
+func (ao *AuthObj) IssueAPIKey(ctx context.Context, key models.APIKey) (string, models.APIKey, error) {
+	if ao.apiKeys == nil {
+		return "", models.APIKey{}, ErrAPIKeysDisabled
+	}
+	id := make([]byte, 8)
+	if _, err := rand.Read(id); err != nil {
+		return "", models.APIKey{}, err
+	}
+	secret, err := NewOpaqueToken()
+	if err != nil {
+		return "", models.APIKey{}, err
+	}
+	raw := APIKeyPrefix + secret
+	key.ID = hex.EncodeToString(id)
+	key.Hash = HashToken(raw)
+	key.CreatedAt = time.Now()
+	if err = ao.apiKeys.CreateAPIKey(ctx, key); err != nil {
+		return "", models.APIKey{}, err
+	}
+	return raw, key, nil
+}
+
+func (ao *AuthObj) APIKeys(ctx context.Context, userID string) ([]models.APIKey, error) {
+	if ao.apiKeys == nil {
+		return nil, ErrAPIKeysDisabled
+	}
+	return ao.apiKeys.ListAPIKeys(ctx, userID)
+}
+
+func (ao *AuthObj) RevokeAPIKey(ctx context.Context, userID string, id string) error {
+	if ao.apiKeys == nil {
+		return ErrAPIKeysDisabled
+	}
+	return ao.apiKeys.DeleteAPIKey(ctx, userID, id)
+}
+
+func (ao *AuthObj) ValidateAPIKey(ctx context.Context, raw string) (models.APIKey, error) {
+	if ao.apiKeys == nil {
+		return models.APIKey{}, ErrAPIKeysDisabled
+	}
+	key, err := ao.apiKeys.FindAPIKey(ctx, HashToken(raw))
+	if err != nil {
+		return models.APIKey{}, err
+	}
+	if !key.ExpiresAt.After(time.Now()) {
+		return models.APIKey{}, dbhandler.ErrNotFound
+	}
+	return key, nil
+}
+
+func (ao *AuthObj) checkParentKey(ctx context.Context, id string, subject string) error {
+	if ao.apiKeys == nil {
+		return ErrAPIKeysDisabled
+	}
+	key, err := ao.apiKeys.GetAPIKey(ctx, id)
+	if err != nil {
+		return err
+	}
+	if key.UserID != subject || !key.ExpiresAt.After(time.Now()) {
+		return dbhandler.ErrNotFound
+	}
+	_, err = ao.apiKeyRole(ctx, key)
+	return err
+}
+
+func (ao *AuthObj) apiKeyRole(ctx context.Context, key models.APIKey) (string, error) {
+	if ao.users == nil {
+		return key.Role, nil
+	}
+	owner, err := ao.users.GetUser(ctx, key.UserID)
+	if err != nil {
+		return "", err
+	}
+	if owner.Status == models.UserStatusPending || owner.Lockout.LockedUntil.After(time.Now()) {
+		return "", ErrAPIKeyOwnerInactive
+	}
+	if owner.Role == "" {
+		return models.RoleUser, nil
+	}
+	return owner.Role, nil
+}
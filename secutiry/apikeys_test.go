@@ -0,0 +1,173 @@
+package secutiry
+
+import (
+	"UserStorage/dbhandler"
+	"UserStorage/models"
+	"context"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func doAPIKeyRequest(r *gin.Engine, path string, key string) int {
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, path, nil)
+	req.Header.Set("Authorization", "ApiKey "+key)
+	r.ServeHTTP(w, req)
+	return w.Code
+}
+
+func TestAPIKeyAuth(t *testing.T) {
+	store := dbhandler.NewMemoryAPIKeyStore()
+	auth := NewAuthObj([]byte("test"), WithAPIKeyStore(store))
+	r := newTestRouter(auth)
+	raw, key, err := auth.IssueAPIKey(context.Background(), models.APIKey{
+		UserID:    "batch@test.pl",
+		Role:      models.RoleAdmin,
+		Scopes:    []string{ScopeUsersRead},
+		ExpiresAt: time.Now().Add(time.Hour),
+	})
+	assert.NoError(t, err)
+	assert.True(t, strings.HasPrefix(raw, APIKeyPrefix))
+	assert.Equal(t, key.Hash, HashToken(raw))
+
+	assert.Equal(t, doAPIKeyRequest(r, "/users", raw), http.StatusOK)
+	assert.Equal(t, doAPIKeyRequest(r, "/users", raw+"x"), http.StatusUnauthorized)
+
+	assert.NoError(t, auth.RevokeAPIKey(context.Background(), "batch@test.pl", key.ID))
+	assert.Equal(t, doAPIKeyRequest(r, "/users", raw), http.StatusUnauthorized)
+}
+
+func TestAPIKeyExpired(t *testing.T) {
+	auth := NewAuthObj([]byte("test"), WithAPIKeyStore(dbhandler.NewMemoryAPIKeyStore()))
+	r := newTestRouter(auth)
+	raw, _, err := auth.IssueAPIKey(context.Background(), models.APIKey{
+		UserID:    "test@test.pl",
+		Role:      models.RoleUser,
+		ExpiresAt: time.Now().Add(-time.Minute),
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, doAPIKeyRequest(r, "/users/test@test.pl", raw), http.StatusUnauthorized)
+}
+
+func TestAPIKeySetsIdentity(t *testing.T) {
+	auth := NewAuthObj([]byte("test"), WithAPIKeyStore(dbhandler.NewMemoryAPIKeyStore()))
+	raw, _, err := auth.IssueAPIKey(context.Background(), models.APIKey{
+		UserID:    "test@test.pl",
+		Role:      models.RoleUser,
+		Scopes:    []string{ScopeFilesRead},
+		ExpiresAt: time.Now().Add(time.Hour),
+	})
+	assert.NoError(t, err)
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.GET("/me", auth.Auth(), func(c *gin.Context) {
+		c.String(http.StatusOK, Subject(c)+" "+strings.Join(Scopes(c), ","))
+	})
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/me", nil)
+	req.Header.Set("Authorization", "ApiKey "+raw)
+	r.ServeHTTP(w, req)
+	assert.Equal(t, w.Body.String(), "test@test.pl files:read")
+}
+
+func TestAPIKeyRevokedUser(t *testing.T) {
+	keys := dbhandler.NewMemoryAPIKeyStore()
+	auth := NewAuthObj([]byte("test"),
+		WithAPIKeyStore(keys),
+		WithRevocationStore(dbhandler.NewMemoryRevocationStore()))
+	r := newTestRouter(auth)
+	raw := APIKeyPrefix + "old"
+	assert.NoError(t, keys.CreateAPIKey(context.Background(), models.APIKey{
+		ID:        "old",
+		UserID:    "test@test.pl",
+		Role:      models.RoleUser,
+		Hash:      HashToken(raw),
+		Scopes:    AllScopes,
+		CreatedAt: time.Now().Add(-time.Minute),
+		ExpiresAt: time.Now().Add(time.Hour),
+	}))
+	assert.Equal(t, doAPIKeyRequest(r, "/users/test@test.pl", raw), http.StatusOK)
+	assert.NoError(t, auth.RevokeUser(context.Background(), "test@test.pl"))
+	assert.Equal(t, doAPIKeyRequest(r, "/users/test@test.pl", raw), http.StatusUnauthorized)
+	listed, err := auth.APIKeys(context.Background(), "test@test.pl")
+	assert.NoError(t, err)
+	assert.Empty(t, listed)
+}
+
+func TestValidScopes(t *testing.T) {
+	assert.True(t, ValidScopes([]string{ScopeUsersRead, ScopeFilesWrite}))
+	assert.False(t, ValidScopes(nil))
+	assert.False(t, ValidScopes([]string{"users:admin"}))
+}
+
+type recordingRevocationStore struct {
+	dbhandler.RevocationStore
+	expiresAt []time.Time
+}
+
+func (r *recordingRevocationStore) RevokeToken(ctx context.Context, jti string, expiresAt time.Time) error {
+	r.expiresAt = append(r.expiresAt, expiresAt)
+	return r.RevocationStore.RevokeToken(ctx, jti, expiresAt)
+}
+
+func (r *recordingRevocationStore) RevokeUser(ctx context.Context, userID string, before time.Time, expiresAt time.Time) error {
+	r.expiresAt = append(r.expiresAt, expiresAt)
+	return r.RevocationStore.RevokeUser(ctx, userID, before, expiresAt)
+}
+
+func TestRevocationOutlivesAPIKeys(t *testing.T) {
+	store := &recordingRevocationStore{RevocationStore: dbhandler.NewMemoryRevocationStore()}
+	auth := NewAuthObj([]byte("test"), WithRevocationStore(store))
+	start := time.Now()
+	assert.NoError(t, auth.RevokeToken(context.Background(), "key-id"))
+	assert.NoError(t, auth.RevokeUser(context.Background(), "test@test.pl"))
+	assert.Len(t, store.expiresAt, 2)
+	for _, expiresAt := range store.expiresAt {
+		assert.False(t, expiresAt.Before(start.Add(MaxAPIKeyTTL)))
+	}
+}
+
+type userLookup map[string]models.User
+
+func (u userLookup) GetUser(_ context.Context, id string) (models.User, error) {
+	user, ok := u[id]
+	if !ok {
+		return models.User{}, dbhandler.ErrNotFound
+	}
+	return user, nil
+}
+
+func TestAPIKeyResolvesOwner(t *testing.T) {
+	users := userLookup{"batch@test.pl": {Email: "batch@test.pl", Role: models.RoleAdmin, Status: models.UserStatusActive}}
+	auth := NewAuthObj([]byte("test"), WithAPIKeyStore(dbhandler.NewMemoryAPIKeyStore()), WithUserLookup(users))
+	r := newTestRouter(auth)
+	raw, _, err := auth.IssueAPIKey(context.Background(), models.APIKey{
+		UserID:    "batch@test.pl",
+		Role:      models.RoleAdmin,
+		ExpiresAt: time.Now().Add(time.Hour),
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, doAPIKeyRequest(r, "/users", raw), http.StatusOK)
+
+	users["batch@test.pl"] = models.User{Email: "batch@test.pl", Role: models.RoleUser, Status: models.UserStatusActive}
+	assert.Equal(t, doAPIKeyRequest(r, "/users", raw), http.StatusForbidden)
+	assert.Equal(t, doAPIKeyRequest(r, "/users/batch@test.pl", raw), http.StatusOK)
+
+	users["batch@test.pl"] = models.User{
+		Email:   "batch@test.pl",
+		Status:  models.UserStatusActive,
+		Lockout: models.Lockout{LockedUntil: time.Now().Add(time.Minute)},
+	}
+	assert.Equal(t, doAPIKeyRequest(r, "/users/batch@test.pl", raw), http.StatusUnauthorized)
+
+	users["batch@test.pl"] = models.User{Email: "batch@test.pl", Status: models.UserStatusPending}
+	assert.Equal(t, doAPIKeyRequest(r, "/users/batch@test.pl", raw), http.StatusUnauthorized)
+
+	delete(users, "batch@test.pl")
+	assert.Equal(t, doAPIKeyRequest(r, "/users/batch@test.pl", raw), http.StatusUnauthorized)
+}
@@ -0,0 +1,18 @@
+package secutiry
+
+import "context"
+
+func (ao *AuthObj) ForgetUser(ctx context.Context, userID string) error {
+	if ao.sessions != nil {
+		if err := ao.sessions.DeleteUserSessions(ctx, userID); err != nil {
+			return err
+		}
+	}
+	if ao.revocations != nil {
+		return ao.RevokeUser(ctx, userID)
+	}
+	if ao.apiKeys != nil {
+		return ao.apiKeys.DeleteUserAPIKeys(ctx, userID)
+	}
+	return nil
+}
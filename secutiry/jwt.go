@@ -1,6 +1,9 @@
 package secutiry
 
 import (
+	"UserStorage/dbhandler"
+	"context"
+	"errors"
 	"fmt"
 	"github.com/gin-gonic/gin"
 	"github.com/golang-jwt/jwt/v5"
@@ -9,36 +12,136 @@ import (
 	"time"
 )
 
+const (
+	AccessTokenTTL  = 15 * time.Minute
+	RefreshTokenTTL = 30 * 24 * time.Hour
+
+	VerificationTokenTTL  = 24 * time.Hour
+	PasswordResetTokenTTL = time.Hour
+	MFAChallengeTTL       = 5 * time.Minute
+	OIDCStateTTL          = 10 * time.Minute
+
+	RevocationTTL = MaxAPIKeyTTL
+)
+
 type AuthObj struct {
-	secret []byte
+	keys        map[string]SigningKey
+	keyOrder    []string
+	active      string
+	issuer      string
+	audience    []string
+	leeway      time.Duration
+	revocations dbhandler.RevocationStore
+	apiKeys     dbhandler.APIKeyStore
+	sessions    dbhandler.SessionStore
+	users       UserLookup
+}
+
+type Claims struct {
+	Role    string   `json:"role"`
+	Scope   string   `json:"scope,omitempty"`
+	SID     string   `json:"sid,omitempty"`
+	Parents []string `json:"parents,omitempty"`
+	KeyID   string   `json:"akid,omitempty"`
+	jwt.RegisteredClaims
+}
+
+func (cl Claims) Scopes() []string {
+	return strings.Fields(cl.Scope)
+}
+
+func (ao *AuthObj) addKey(key SigningKey) {
+	if _, ok := ao.keys[key.ID]; !ok {
+		ao.keyOrder = append(ao.keyOrder, key.ID)
+	}
+	ao.keys[key.ID] = key
+	if ao.active == "" && key.Private != nil {
+		ao.active = key.ID
+	}
+}
+
+func (ao *AuthObj) CreateToken(subject string, role string, scopes ...string) (string, error) {
+	return ao.createToken(Claims{
+		Role:             role,
+		Scope:            strings.Join(scopes, " "),
+		RegisteredClaims: jwt.RegisteredClaims{Subject: subject},
+	}, time.Now().Add(AccessTokenTTL))
 }
 
-func (ao *AuthObj) CreateToken(username string) (string, error) {
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
-		"username": username,
-		"exp":      time.Now().Add(time.Hour * 24).Unix(),
-	})
-	tokenString, err := token.SignedString(ao.secret)
+func (ao *AuthObj) CreateSessionToken(sid string, subject string, role string) (string, error) {
+	return ao.createToken(Claims{
+		Role:             role,
+		Scope:            strings.Join(AllScopes, " "),
+		SID:              sid,
+		RegisteredClaims: jwt.RegisteredClaims{Subject: subject},
+	}, time.Now().Add(AccessTokenTTL))
+}
+
+func (ao *AuthObj) createToken(claims Claims, expiresAt time.Time) (string, error) {
+	if claims.Scope == "" {
+		return "", errors.New("token has no scopes")
+	}
+	key, ok := ao.keys[ao.active]
+	if !ok || key.Private == nil {
+		return "", errors.New("no signing key configured")
+	}
+	jti, err := NewOpaqueToken()
+	if err != nil {
+		return "", err
+	}
+	now := time.Now()
+	claims.RegisteredClaims = jwt.RegisteredClaims{
+		ID:        jti,
+		Subject:   claims.Subject,
+		Issuer:    ao.issuer,
+		Audience:  ao.audience,
+		IssuedAt:  jwt.NewNumericDate(now),
+		NotBefore: jwt.NewNumericDate(now),
+		ExpiresAt: jwt.NewNumericDate(expiresAt),
+	}
+	token := jwt.NewWithClaims(key.Method, claims)
+	token.Header["kid"] = key.ID
+	tokenString, err := token.SignedString(key.Private)
 	if err != nil {
 		return "", err
 	}
 	return tokenString, nil
 }
 
-func (ao *AuthObj) ValidateToken(tokenString string) error {
-	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+func (ao *AuthObj) ValidateToken(tokenString string) (*Claims, error) {
+	claims := &Claims{}
+	token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
+		kid, _ := token.Header["kid"].(string)
+		key, ok := ao.keys[kid]
+		if !ok {
+			return nil, fmt.Errorf("unknown key id: %q", kid)
+		}
+		if token.Method.Alg() != key.Method.Alg() {
 			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
 		}
-		return []byte(ao.secret), nil
-	})
+		return key.Public, nil
+	}, ao.parserOptions()...)
 	if err != nil {
-		return err
+		return nil, err
 	}
 	if !token.Valid {
-		return err
+		return nil, errors.New("invalid token")
+	}
+	if claims.Subject == "" {
+		return nil, errors.New("token has no subject")
+	}
+	return claims, nil
+}
+
+func (ao *AuthObj) parserOptions() []jwt.ParserOption {
+	opts := []jwt.ParserOption{jwt.WithExpirationRequired(), jwt.WithIssuedAt(), jwt.WithLeeway(ao.leeway)}
+	if ao.issuer != "" {
+		opts = append(opts, jwt.WithIssuer(ao.issuer))
+	}
+	if len(ao.audience) > 0 {
+		opts = append(opts, jwt.WithAudience(ao.audience...))
 	}
-	return nil
+	return opts
 }
 
 func (ao *AuthObj) Auth() gin.HandlerFunc {
@@ -50,21 +153,123 @@ func (ao *AuthObj) Auth() gin.HandlerFunc {
 		}
 
 		parts := strings.Split(header, " ")
-		if len(parts) != 2 || parts[0] != "Bearer" {
+		if len(parts) != 2 || (parts[0] != "Bearer" && parts[0] != "ApiKey") {
 			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "invalid token format"})
 			return
 		}
 
-		err := ao.ValidateToken(parts[1])
-		if err != nil {
-			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "invalid token"})
-			return
+		var (
+			jti, tokenID, subject, role, sid, keyID string
+			scopes, parents                         []string
+			issuedAt, expiresAt                     time.Time
+		)
+		if parts[0] == "ApiKey" {
+			key, err := ao.ValidateAPIKey(c.Request.Context(), parts[1])
+			if err != nil {
+				c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "invalid api key"})
+				return
+			}
+			role, err = ao.apiKeyRole(c.Request.Context(), key)
+			if errors.Is(err, dbhandler.ErrNotFound) || errors.Is(err, ErrAPIKeyOwnerInactive) {
+				c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "invalid api key"})
+				return
+			}
+			if err != nil {
+				c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+			jti, subject, scopes, keyID = key.ID, key.UserID, key.Scopes, key.ID
+			issuedAt, expiresAt = key.CreatedAt, key.ExpiresAt
+		} else {
+			claims, err := ao.ValidateToken(parts[1])
+			if err != nil {
+				c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "invalid token"})
+				return
+			}
+			jti, subject, role, sid, scopes = claims.ID, claims.Subject, claims.Role, claims.SID, claims.Scopes()
+			tokenID, parents, keyID = claims.ID, claims.Parents, claims.KeyID
+			if claims.IssuedAt != nil {
+				issuedAt = claims.IssuedAt.Time
+			}
+			expiresAt = claims.ExpiresAt.Time
+			if keyID != "" {
+				err = ao.checkParentKey(c.Request.Context(), keyID, subject)
+				if errors.Is(err, dbhandler.ErrNotFound) || errors.Is(err, ErrAPIKeyOwnerInactive) {
+					c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "token revoked"})
+					return
+				}
+				if err != nil {
+					c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+					return
+				}
+			}
+		}
+
+		if ao.revocations != nil {
+			for _, id := range append([]string{jti}, parents...) {
+				revoked, err := ao.revocations.IsRevoked(c.Request.Context(), id, HashToken(subject), issuedAt)
+				if err != nil {
+					c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+					return
+				}
+				if revoked {
+					c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "token revoked"})
+					return
+				}
+			}
+		}
+
+		if sid != "" && ao.sessions != nil {
+			err := ao.sessions.TouchSession(c.Request.Context(), sid, time.Now())
+			if errors.Is(err, dbhandler.ErrNotFound) {
+				c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "session terminated"})
+				return
+			}
+			if err != nil {
+				c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
 		}
 
+		c.Set(ContextSubject, subject)
+		c.Set(ContextSessionID, sid)
+		c.Set(ContextRole, role)
+		c.Set(ContextScopes, scopes)
+		c.Set(ContextExpiresAt, expiresAt)
+		c.Set(ContextParents, parents)
+		c.Set(ContextAPIKeyID, keyID)
+		c.Set(ContextTokenID, tokenID)
 		c.Next()
 	}
 }
 
-func NewAuthObj(bytes []byte) *AuthObj {
-	return &AuthObj{bytes}
+func (ao *AuthObj) RevokeToken(ctx context.Context, jti string) error {
+	if ao.revocations == nil {
+		return errors.New("token revocation is not configured")
+	}
+	return ao.revocations.RevokeToken(ctx, jti, time.Now().Add(RevocationTTL))
+}
+
+func (ao *AuthObj) RevokeUser(ctx context.Context, userID string) error {
+	if ao.revocations == nil {
+		return errors.New("token revocation is not configured")
+	}
+	if ao.apiKeys != nil {
+		if err := ao.apiKeys.DeleteUserAPIKeys(ctx, userID); err != nil {
+			return err
+		}
+	}
+	now := time.Now()
+	return ao.revocations.RevokeUser(ctx, HashToken(userID), now, now.Add(RevocationTTL))
+}
+
+func NewAuthObj(bytes []byte, opts ...Option) *AuthObj {
+	ao := &AuthObj{keys: map[string]SigningKey{}}
+	if len(bytes) > 0 {
+		ao.addKey(NewHMACKey("hs256", bytes))
+	}
+	for _, opt := range opts {
+		opt(ao)
+	}
+	return ao
 }
@@ -0,0 +1,195 @@
+package secutiry
+
+import (
+	"UserStorage/dbhandler"
+	"UserStorage/models"
+	"context"
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func newTestRouter(auth *AuthObj) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	ok := func(c *gin.Context) { c.Status(http.StatusOK) }
+	users := r.Group("/users")
+	users.Use(auth.Auth())
+	users.GET("", RequireRole(models.RoleAdmin), ok)
+	user := users.Group("/:id")
+	user.Use(SelfOrAdmin("id"))
+	user.GET("", ok)
+	user.GET("/files", ok)
+	return r
+}
+
+func doRequest(r *gin.Engine, path string, token string) int {
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, path, nil)
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	r.ServeHTTP(w, req)
+	return w.Code
+}
+
+func TestCreateAndValidateToken(t *testing.T) {
+	auth := NewAuthObj([]byte("test"))
+	token, err := auth.CreateToken("test@test.pl", models.RoleAdmin, AllScopes...)
+	assert.NoError(t, err)
+	claims, err := auth.ValidateToken(token)
+	assert.NoError(t, err)
+	assert.Equal(t, claims.Subject, "test@test.pl")
+	assert.Equal(t, claims.Role, models.RoleAdmin)
+
+	_, err = NewAuthObj([]byte("other")).ValidateToken(token)
+	assert.Error(t, err)
+}
+
+func TestAuthMissingToken(t *testing.T) {
+	r := newTestRouter(NewAuthObj([]byte("test")))
+	assert.Equal(t, doRequest(r, "/users/test@test.pl", ""), http.StatusUnauthorized)
+}
+
+func TestSelfOrAdmin(t *testing.T) {
+	auth := NewAuthObj([]byte("test"))
+	r := newTestRouter(auth)
+	userToken, err := auth.CreateToken("test@test.pl", models.RoleUser, AllScopes...)
+	assert.NoError(t, err)
+	adminToken, err := auth.CreateToken("admin@test.pl", models.RoleAdmin, AllScopes...)
+	assert.NoError(t, err)
+
+	assert.Equal(t, doRequest(r, "/users/test@test.pl", userToken), http.StatusOK)
+	assert.Equal(t, doRequest(r, "/users/test@test.pl/files", userToken), http.StatusOK)
+	assert.Equal(t, doRequest(r, "/users/other@test.pl", userToken), http.StatusForbidden)
+	assert.Equal(t, doRequest(r, "/users/other@test.pl/files", userToken), http.StatusForbidden)
+	assert.Equal(t, doRequest(r, "/users/other@test.pl", adminToken), http.StatusOK)
+}
+
+func TestRequireRole(t *testing.T) {
+	auth := NewAuthObj([]byte("test"))
+	r := newTestRouter(auth)
+	userToken, err := auth.CreateToken("test@test.pl", models.RoleUser, AllScopes...)
+	assert.NoError(t, err)
+	adminToken, err := auth.CreateToken("admin@test.pl", models.RoleAdmin, AllScopes...)
+	assert.NoError(t, err)
+
+	assert.Equal(t, doRequest(r, "/users", userToken), http.StatusForbidden)
+	assert.Equal(t, doRequest(r, "/users", adminToken), http.StatusOK)
+}
+
+func TestRevokedToken(t *testing.T) {
+	auth := NewAuthObj([]byte("test"), WithRevocationStore(dbhandler.NewMemoryRevocationStore()))
+	r := newTestRouter(auth)
+	token, err := auth.CreateToken("test@test.pl", models.RoleUser, AllScopes...)
+	assert.NoError(t, err)
+	other, err := auth.CreateToken("test@test.pl", models.RoleUser, AllScopes...)
+	assert.NoError(t, err)
+	claims, err := auth.ValidateToken(token)
+	assert.NoError(t, err)
+	assert.NotEmpty(t, claims.ID)
+
+	assert.NoError(t, auth.RevokeToken(context.Background(), claims.ID))
+	assert.Equal(t, doRequest(r, "/users/test@test.pl", token), http.StatusUnauthorized)
+	assert.Equal(t, doRequest(r, "/users/test@test.pl", other), http.StatusOK)
+}
+
+func TestRevokedUser(t *testing.T) {
+	auth := NewAuthObj([]byte("test"), WithRevocationStore(dbhandler.NewMemoryRevocationStore()))
+	r := newTestRouter(auth)
+	issued := time.Now().Add(-time.Second)
+	token := signClaims(t, Claims{Role: models.RoleUser, Scope: ScopeUsersRead, RegisteredClaims: jwt.RegisteredClaims{
+		Subject:   "test@test.pl",
+		IssuedAt:  jwt.NewNumericDate(issued),
+		ExpiresAt: jwt.NewNumericDate(issued.Add(time.Hour)),
+	}})
+	adminToken, err := auth.CreateToken("admin@test.pl", models.RoleAdmin, AllScopes...)
+	assert.NoError(t, err)
+
+	assert.NoError(t, auth.RevokeUser(context.Background(), "test@test.pl"))
+	assert.Equal(t, doRequest(r, "/users/test@test.pl", token), http.StatusUnauthorized)
+	assert.Equal(t, doRequest(r, "/users", adminToken), http.StatusOK)
+	fresh, err := auth.CreateToken("test@test.pl", models.RoleUser, AllScopes...)
+	assert.NoError(t, err)
+	assert.Equal(t, doRequest(r, "/users/test@test.pl", fresh), http.StatusOK)
+}
+
+func signClaims(t *testing.T, claims Claims) string {
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	token.Header["kid"] = "hs256"
+	tokenString, err := token.SignedString([]byte("test"))
+	assert.NoError(t, err)
+	return tokenString
+}
+
+func TestIssuerAndAudience(t *testing.T) {
+	auth := NewAuthObj([]byte("test"), WithIssuer("UserStorage"), WithAudience("UserStorage", "files"))
+	token, err := auth.CreateToken("test@test.pl", models.RoleUser, AllScopes...)
+	assert.NoError(t, err)
+	claims, err := auth.ValidateToken(token)
+	assert.NoError(t, err)
+	assert.Equal(t, claims.Issuer, "UserStorage")
+
+	other, err := NewAuthObj([]byte("test"), WithIssuer("other")).CreateToken("test@test.pl", models.RoleUser, AllScopes...)
+	assert.NoError(t, err)
+	_, err = auth.ValidateToken(other)
+	assert.ErrorIs(t, err, jwt.ErrTokenInvalidIssuer)
+
+	now := time.Now()
+	wrongAud := signClaims(t, Claims{RegisteredClaims: jwt.RegisteredClaims{
+		Subject:   "test@test.pl",
+		Issuer:    "UserStorage",
+		Audience:  jwt.ClaimStrings{"billing"},
+		IssuedAt:  jwt.NewNumericDate(now),
+		ExpiresAt: jwt.NewNumericDate(now.Add(time.Minute)),
+	}})
+	_, err = auth.ValidateToken(wrongAud)
+	assert.ErrorIs(t, err, jwt.ErrTokenInvalidAudience)
+}
+
+func TestNotBeforeAndLeeway(t *testing.T) {
+	now := time.Now()
+	future := signClaims(t, Claims{RegisteredClaims: jwt.RegisteredClaims{
+		Subject:   "test@test.pl",
+		IssuedAt:  jwt.NewNumericDate(now),
+		NotBefore: jwt.NewNumericDate(now.Add(10 * time.Second)),
+		ExpiresAt: jwt.NewNumericDate(now.Add(time.Minute)),
+	}})
+	_, err := NewAuthObj([]byte("test")).ValidateToken(future)
+	assert.ErrorIs(t, err, jwt.ErrTokenNotValidYet)
+	_, err = NewAuthObj([]byte("test"), WithLeeway(time.Minute)).ValidateToken(future)
+	assert.NoError(t, err)
+
+	expired := signClaims(t, Claims{RegisteredClaims: jwt.RegisteredClaims{
+		Subject:   "test@test.pl",
+		ExpiresAt: jwt.NewNumericDate(now.Add(-10 * time.Second)),
+	}})
+	_, err = NewAuthObj([]byte("test")).ValidateToken(expired)
+	assert.ErrorIs(t, err, jwt.ErrTokenExpired)
+	_, err = NewAuthObj([]byte("test"), WithLeeway(time.Minute)).ValidateToken(expired)
+	assert.NoError(t, err)
+
+	noExp := signClaims(t, Claims{RegisteredClaims: jwt.RegisteredClaims{Subject: "test@test.pl"}})
+	_, err = NewAuthObj([]byte("test")).ValidateToken(noExp)
+	assert.Error(t, err)
+}
+
+func TestAuthSetsSubject(t *testing.T) {
+	auth := NewAuthObj([]byte("test"))
+	token, err := auth.CreateToken("test@test.pl", models.RoleAdmin, AllScopes...)
+	assert.NoError(t, err)
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.GET("/me", auth.Auth(), func(c *gin.Context) {
+		c.String(http.StatusOK, Subject(c))
+	})
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/me", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	r.ServeHTTP(w, req)
+	assert.Equal(t, w.Body.String(), "test@test.pl")
+}
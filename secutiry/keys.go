@@ -0,0 +1,149 @@
+package secutiry
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"math/big"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+type SigningKey struct {
+	ID      string
+	Method  jwt.SigningMethod
+	Private any
+	Public  any
+}
+
+func NewHMACKey(id string, secret []byte) SigningKey {
+	return SigningKey{ID: id, Method: jwt.SigningMethodHS256, Private: secret, Public: secret}
+}
+
+func LoadPEMKey(path string) (SigningKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return SigningKey{}, err
+	}
+	id := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	return ParsePEMKey(id, data)
+}
+
+func ParsePEMKey(id string, data []byte) (SigningKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return SigningKey{}, fmt.Errorf("key %s: no PEM data", id)
+	}
+	var key any
+	var err error
+	switch block.Type {
+	case "PRIVATE KEY":
+		key, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	case "RSA PRIVATE KEY":
+		key, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "EC PRIVATE KEY":
+		key, err = x509.ParseECPrivateKey(block.Bytes)
+	case "PUBLIC KEY":
+		key, err = x509.ParsePKIXPublicKey(block.Bytes)
+	default:
+		return SigningKey{}, fmt.Errorf("key %s: unsupported PEM block %q", id, block.Type)
+	}
+	if err != nil {
+		return SigningKey{}, fmt.Errorf("key %s: %w", id, err)
+	}
+
+	switch k := key.(type) {
+	case *rsa.PrivateKey:
+		return SigningKey{ID: id, Method: jwt.SigningMethodRS256, Private: k, Public: &k.PublicKey}, nil
+	case *rsa.PublicKey:
+		return SigningKey{ID: id, Method: jwt.SigningMethodRS256, Public: k}, nil
+	case *ecdsa.PrivateKey:
+		method, err := ecdsaMethod(k.Curve)
+		if err != nil {
+			return SigningKey{}, fmt.Errorf("key %s: %w", id, err)
+		}
+		return SigningKey{ID: id, Method: method, Private: k, Public: &k.PublicKey}, nil
+	case *ecdsa.PublicKey:
+		method, err := ecdsaMethod(k.Curve)
+		if err != nil {
+			return SigningKey{}, fmt.Errorf("key %s: %w", id, err)
+		}
+		return SigningKey{ID: id, Method: method, Public: k}, nil
+	}
+	return SigningKey{}, fmt.Errorf("key %s: unsupported key type %T", id, key)
+}
+
+func ecdsaMethod(curve elliptic.Curve) (jwt.SigningMethod, error) {
+	switch curve {
+	case elliptic.P256():
+		return jwt.SigningMethodES256, nil
+	case elliptic.P384():
+		return jwt.SigningMethodES384, nil
+	case elliptic.P521():
+		return jwt.SigningMethodES512, nil
+	}
+	return nil, errors.New("unsupported elliptic curve")
+}
+
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+	Y   string `json:"y,omitempty"`
+}
+
+func (k SigningKey) JWK() (JWK, bool) {
+	enc := base64.RawURLEncoding
+	switch pub := k.Public.(type) {
+	case *rsa.PublicKey:
+		return JWK{
+			Kty: "RSA",
+			Kid: k.ID,
+			Use: "sig",
+			Alg: k.Method.Alg(),
+			N:   enc.EncodeToString(pub.N.Bytes()),
+			E:   enc.EncodeToString(big.NewInt(int64(pub.E)).Bytes()),
+		}, true
+	case *ecdsa.PublicKey:
+		ecdhKey, err := pub.ECDH()
+		if err != nil {
+			return JWK{}, false
+		}
+		point := ecdhKey.Bytes()
+		size := (len(point) - 1) / 2
+		return JWK{
+			Kty: "EC",
+			Kid: k.ID,
+			Use: "sig",
+			Alg: k.Method.Alg(),
+			Crv: pub.Curve.Params().Name,
+			X:   enc.EncodeToString(point[1 : 1+size]),
+			Y:   enc.EncodeToString(point[1+size:]),
+		}, true
+	}
+	return JWK{}, false
+}
+
+func (ao *AuthObj) JWKS(c *gin.Context) {
+	keys := []JWK{}
+	for _, id := range ao.keyOrder {
+		if jwk, ok := ao.keys[id].JWK(); ok {
+			keys = append(keys, jwk)
+		}
+	}
+	c.JSON(http.StatusOK, gin.H{"keys": keys})
+}
@@ -0,0 +1,125 @@
+package secutiry
+
+import (
+	"UserStorage/models"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func rsaPEM(t *testing.T) []byte {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.NoError(t, err)
+	return pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})
+}
+
+func ecPEM(t *testing.T) []byte {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(t, err)
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	assert.NoError(t, err)
+	return pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})
+}
+
+func TestParsePEMKey(t *testing.T) {
+	rsaKey, err := ParsePEMKey("rsa", rsaPEM(t))
+	assert.NoError(t, err)
+	assert.Equal(t, rsaKey.Method.Alg(), "RS256")
+	ecKey, err := ParsePEMKey("ec", ecPEM(t))
+	assert.NoError(t, err)
+	assert.Equal(t, ecKey.Method.Alg(), "ES256")
+	_, err = ParsePEMKey("bad", []byte("not a key"))
+	assert.Error(t, err)
+}
+
+func TestAsymmetricSigning(t *testing.T) {
+	for _, data := range [][]byte{rsaPEM(t), ecPEM(t)} {
+		key, err := ParsePEMKey("key", data)
+		assert.NoError(t, err)
+		auth := NewAuthObj(nil, WithSigningKeys("", key))
+		token, err := auth.CreateToken("test@test.pl", models.RoleUser, AllScopes...)
+		assert.NoError(t, err)
+		claims, err := auth.ValidateToken(token)
+		assert.NoError(t, err)
+		assert.Equal(t, claims.Subject, "test@test.pl")
+	}
+}
+
+func TestKeyRotation(t *testing.T) {
+	oldKey, err := ParsePEMKey("old", ecPEM(t))
+	assert.NoError(t, err)
+	newKey, err := ParsePEMKey("new", ecPEM(t))
+	assert.NoError(t, err)
+	token, err := NewAuthObj(nil, WithSigningKeys("old", oldKey)).CreateToken("test@test.pl", models.RoleUser, AllScopes...)
+	assert.NoError(t, err)
+
+	rotated := NewAuthObj(nil, WithSigningKeys("new", oldKey, newKey))
+	_, err = rotated.ValidateToken(token)
+	assert.NoError(t, err)
+	newToken, err := rotated.CreateToken("test@test.pl", models.RoleUser, AllScopes...)
+	assert.NoError(t, err)
+	parsed, _, err := jwt.NewParser().ParseUnverified(newToken, &Claims{})
+	assert.NoError(t, err)
+	assert.Equal(t, parsed.Header["kid"], "new")
+
+	_, err = NewAuthObj(nil, WithSigningKeys("new", newKey)).ValidateToken(token)
+	assert.Error(t, err)
+}
+
+func TestRejectAlgorithmMismatch(t *testing.T) {
+	key, err := ParsePEMKey("rsa", rsaPEM(t))
+	assert.NoError(t, err)
+	auth := NewAuthObj(nil, WithSigningKeys("", key))
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, Claims{
+		Role: models.RoleAdmin,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Subject:   "test@test.pl",
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Minute)),
+		},
+	})
+	token.Header["kid"] = "rsa"
+	tokenString, err := token.SignedString([]byte("guess"))
+	assert.NoError(t, err)
+	_, err = auth.ValidateToken(tokenString)
+	assert.Error(t, err)
+}
+
+func TestNoSigningKey(t *testing.T) {
+	_, err := NewAuthObj(nil).CreateToken("test@test.pl", models.RoleUser, AllScopes...)
+	assert.Error(t, err)
+}
+
+func TestJWKS(t *testing.T) {
+	rsaKey, err := ParsePEMKey("rsa", rsaPEM(t))
+	assert.NoError(t, err)
+	ecKey, err := ParsePEMKey("ec", ecPEM(t))
+	assert.NoError(t, err)
+	auth := NewAuthObj([]byte("secret"), WithSigningKeys("", rsaKey, ecKey))
+
+	gin.SetMode(gin.TestMode)
+	w := httptest.NewRecorder()
+	ctx, _ := gin.CreateTestContext(w)
+	auth.JWKS(ctx)
+	assert.Equal(t, w.Code, http.StatusOK)
+	var out struct {
+		Keys []JWK `json:"keys"`
+	}
+	err = json.NewDecoder(w.Body).Decode(&out)
+	assert.NoError(t, err)
+	assert.Equal(t, len(out.Keys), 2)
+	assert.Equal(t, out.Keys[0].Kid, "rsa")
+	assert.Equal(t, out.Keys[0].Kty, "RSA")
+	assert.Equal(t, out.Keys[1].Crv, "P-256")
+	assert.Equal(t, len(out.Keys[1].X), 43)
+}
@@ -0,0 +1,86 @@
+package secutiry
+
+import (
+	"UserStorage/models"
+	"sync"
+	"time"
+)
+
+type LockoutPolicy struct {
+	Threshold int
+	BaseDelay time.Duration
+	MaxDelay  time.Duration
+}
+
+func (p LockoutPolicy) Delay(lockouts int) time.Duration {
+	delay := p.BaseDelay
+	for i := 1; i < lockouts && delay < p.MaxDelay; i++ {
+		delay *= 2
+	}
+	return min(delay, p.MaxDelay)
+}
+
+func (p LockoutPolicy) Fail(l models.Lockout, now time.Time) (models.Lockout, bool) {
+	l.FailedAttempts++
+	return p.Lock(l, now)
+}
+
+func (p LockoutPolicy) Lock(l models.Lockout, now time.Time) (models.Lockout, bool) {
+	if p.Threshold <= 0 || l.FailedAttempts < p.Threshold {
+		return l, false
+	}
+	l.FailedAttempts = 0
+	l.Count++
+	l.LockedUntil = now.Add(p.Delay(l.Count))
+	return l, true
+}
+
+type attempt struct {
+	lockout models.Lockout
+	last    time.Time
+}
+
+type AttemptTracker struct {
+	mu       sync.Mutex
+	policy   LockoutPolicy
+	attempts map[string]attempt
+}
+
+func NewAttemptTracker(policy LockoutPolicy) *AttemptTracker {
+	return &AttemptTracker{policy: policy, attempts: map[string]attempt{}}
+}
+
+func (t *AttemptTracker) LockedUntil(key string, now time.Time) (time.Time, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	a, ok := t.attempts[key]
+	if !ok || !a.lockout.LockedUntil.After(now) {
+		return time.Time{}, false
+	}
+	return a.lockout.LockedUntil, true
+}
+
+func (t *AttemptTracker) Fail(key string, now time.Time) (models.Lockout, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	a := t.attempts[key]
+	if now.Sub(a.last) > t.policy.MaxDelay && !a.lockout.LockedUntil.After(now) {
+		a = attempt{}
+	}
+	var locked bool
+	a.lockout, locked = t.policy.Fail(a.lockout, now)
+	a.last = now
+	t.attempts[key] = a
+	if len(t.attempts) > 10000 {
+		t.prune(now)
+	}
+	return a.lockout, locked
+}
+
+func (t *AttemptTracker) prune(now time.Time) {
+	for key, a := range t.attempts {
+		if now.Sub(a.last) > t.policy.MaxDelay && !a.lockout.LockedUntil.After(now) {
+			delete(t.attempts, key)
+		}
+	}
+}
@@ -0,0 +1,56 @@
+package secutiry
+
+import (
+	"UserStorage/models"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestLockoutDelay(t *testing.T) {
+	policy := LockoutPolicy{Threshold: 3, BaseDelay: time.Minute, MaxDelay: 10 * time.Minute}
+	assert.Equal(t, policy.Delay(1), time.Minute)
+	assert.Equal(t, policy.Delay(2), 2*time.Minute)
+	assert.Equal(t, policy.Delay(3), 4*time.Minute)
+	assert.Equal(t, policy.Delay(5), 10*time.Minute)
+	assert.Equal(t, policy.Delay(100), 10*time.Minute)
+}
+
+func TestLockoutFail(t *testing.T) {
+	policy := LockoutPolicy{Threshold: 2, BaseDelay: time.Minute, MaxDelay: time.Hour}
+	now := time.Now()
+	l, locked := policy.Fail(models.Lockout{}, now)
+	assert.False(t, locked)
+	assert.Equal(t, l.FailedAttempts, 1)
+	l, locked = policy.Fail(l, now)
+	assert.True(t, locked)
+	assert.Equal(t, l.LockedUntil, now.Add(time.Minute))
+	l, _ = policy.Fail(l, now)
+	l, locked = policy.Fail(l, now)
+	assert.True(t, locked)
+	assert.Equal(t, l.Count, 2)
+	assert.Equal(t, l.LockedUntil, now.Add(2*time.Minute))
+}
+
+func TestAttemptTracker(t *testing.T) {
+	tracker := NewAttemptTracker(LockoutPolicy{Threshold: 2, BaseDelay: time.Minute, MaxDelay: time.Hour})
+	now := time.Now()
+	_, locked := tracker.Fail("10.0.0.1", now)
+	assert.False(t, locked)
+	_, locked = tracker.LockedUntil("10.0.0.1", now)
+	assert.False(t, locked)
+	_, locked = tracker.Fail("10.0.0.1", now)
+	assert.True(t, locked)
+	until, locked := tracker.LockedUntil("10.0.0.1", now)
+	assert.True(t, locked)
+	assert.Equal(t, until, now.Add(time.Minute))
+	_, locked = tracker.LockedUntil("10.0.0.2", now)
+	assert.False(t, locked)
+	_, locked = tracker.LockedUntil("10.0.0.1", now.Add(2*time.Minute))
+	assert.False(t, locked)
+
+	_, locked = tracker.Fail("10.0.0.3", now)
+	assert.False(t, locked)
+	_, locked = tracker.Fail("10.0.0.3", now.Add(2*time.Hour))
+	assert.False(t, locked)
+}
@@ -0,0 +1,64 @@
+package secutiry
+
+import (
+	"UserStorage/dbhandler"
+	"time"
+)
+
+type Option func(*AuthObj)
+
+func WithSigningKeys(active string, keys ...SigningKey) Option {
+	return func(ao *AuthObj) {
+		for _, key := range keys {
+			ao.addKey(key)
+			if active == "" && key.Private != nil {
+				active = key.ID
+			}
+		}
+		if active != "" {
+			ao.active = active
+		}
+	}
+}
+
+func WithIssuer(issuer string) Option {
+	return func(ao *AuthObj) {
+		ao.issuer = issuer
+	}
+}
+
+func WithAudience(audience ...string) Option {
+	return func(ao *AuthObj) {
+		ao.audience = audience
+	}
+}
+
+func WithLeeway(leeway time.Duration) Option {
+	return func(ao *AuthObj) {
+		ao.leeway = leeway
+	}
+}
+
+func WithRevocationStore(store dbhandler.RevocationStore) Option {
+	return func(ao *AuthObj) {
+		ao.revocations = store
+	}
+}
+
+func WithAPIKeyStore(store dbhandler.APIKeyStore) Option {
+	return func(ao *AuthObj) {
+		ao.apiKeys = store
+	}
+}
+
+func WithSessionStore(store dbhandler.SessionStore) Option {
+	return func(ao *AuthObj) {
+		ao.sessions = store
+	}
+}
+
+func WithUserLookup(users UserLookup) Option {
+	return func(ao *AuthObj) {
+		ao.users = users
+	}
+}
@@ -0,0 +1,182 @@
+package secutiry
+
+import (
+	"bufio"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+	"os"
+	"strings"
+	"unicode"
+)
+
+const (
+	AlgorithmBcrypt   = "bcrypt"
+	AlgorithmArgon2id = "argon2id"
+
+	maxPasswordBytes = 72
+)
+
+type PasswordPolicy struct {
+	MinLength     int
+	RequireUpper  bool
+	RequireLower  bool
+	RequireDigit  bool
+	RequireSymbol bool
+	DenyList      map[string]struct{}
+}
+
+func LoadDenyList(path string) (map[string]struct{}, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	denyList := map[string]struct{}{}
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		denyList[strings.ToLower(line)] = struct{}{}
+	}
+	if err = scanner.Err(); err != nil {
+		return nil, err
+	}
+	return denyList, nil
+}
+
+func (p PasswordPolicy) Validate(password string) error {
+	if len([]rune(password)) < p.MinLength {
+		return fmt.Errorf("password must be at least %d characters long", p.MinLength)
+	}
+	var upper, lower, digit, symbol bool
+	for _, r := range password {
+		switch {
+		case unicode.IsUpper(r):
+			upper = true
+		case unicode.IsLower(r):
+			lower = true
+		case unicode.IsDigit(r):
+			digit = true
+		case unicode.IsPunct(r) || unicode.IsSymbol(r):
+			symbol = true
+		}
+	}
+	if p.RequireUpper && !upper {
+		return errors.New("password must contain an upper case letter")
+	}
+	if p.RequireLower && !lower {
+		return errors.New("password must contain a lower case letter")
+	}
+	if p.RequireDigit && !digit {
+		return errors.New("password must contain a digit")
+	}
+	if p.RequireSymbol && !symbol {
+		return errors.New("password must contain a symbol")
+	}
+	if _, ok := p.DenyList[strings.ToLower(password)]; ok {
+		return errors.New("password is too common")
+	}
+	return nil
+}
+
+type Argon2Params struct {
+	Memory      uint32
+	Iterations  uint32
+	Parallelism uint8
+	SaltLength  uint32
+	KeyLength   uint32
+}
+
+var DefaultArgon2Params = Argon2Params{Memory: 64 * 1024, Iterations: 3, Parallelism: 2, SaltLength: 16, KeyLength: 32}
+
+type PasswordHasher struct {
+	Algorithm  string
+	BcryptCost int
+	Argon2     Argon2Params
+}
+
+func (h PasswordHasher) Validate(password string) error {
+	if h.Algorithm != AlgorithmArgon2id && len(password) > maxPasswordBytes {
+		return fmt.Errorf("password must be at most %d bytes long", maxPasswordBytes)
+	}
+	return nil
+}
+
+func (h PasswordHasher) Hash(password string) (string, error) {
+	if h.Algorithm == AlgorithmArgon2id {
+		salt := make([]byte, h.Argon2.SaltLength)
+		if _, err := rand.Read(salt); err != nil {
+			return "", err
+		}
+		key := argon2.IDKey([]byte(password), salt, h.Argon2.Iterations, h.Argon2.Memory, h.Argon2.Parallelism, h.Argon2.KeyLength)
+		return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s", argon2.Version,
+			h.Argon2.Memory, h.Argon2.Iterations, h.Argon2.Parallelism,
+			base64.RawStdEncoding.EncodeToString(salt), base64.RawStdEncoding.EncodeToString(key)), nil
+	}
+	hashed, err := bcrypt.GenerateFromPassword([]byte(password), h.BcryptCost)
+	if err != nil {
+		return "", err
+	}
+	return string(hashed), nil
+}
+
+// Verify reports whether password matches hash and whether the hash should be
+// replaced because it was made with another algorithm or weaker parameters.
+func (h PasswordHasher) Verify(hash string, password string) (bool, bool, error) {
+	if strings.HasPrefix(hash, "$argon2id$") {
+		params, salt, key, err := decodeArgon2(hash)
+		if err != nil {
+			return false, false, err
+		}
+		other := argon2.IDKey([]byte(password), salt, params.Iterations, params.Memory, params.Parallelism, uint32(len(key)))
+		if subtle.ConstantTimeCompare(key, other) != 1 {
+			return false, false, nil
+		}
+		return true, h.Algorithm != AlgorithmArgon2id || params != h.Argon2, nil
+	}
+	err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
+	if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+		return false, false, nil
+	}
+	if err != nil {
+		return false, false, err
+	}
+	cost, err := bcrypt.Cost([]byte(hash))
+	if err != nil {
+		return false, false, err
+	}
+	return true, h.Algorithm == AlgorithmArgon2id || cost < h.BcryptCost, nil
+}
+
+func decodeArgon2(hash string) (Argon2Params, []byte, []byte, error) {
+	parts := strings.Split(hash, "$")
+	if len(parts) != 6 {
+		return Argon2Params{}, nil, nil, errors.New("malformed argon2id hash")
+	}
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return Argon2Params{}, nil, nil, errors.New("unsupported argon2id version")
+	}
+	var params Argon2Params
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &params.Memory, &params.Iterations, &params.Parallelism); err != nil {
+		return Argon2Params{}, nil, nil, fmt.Errorf("malformed argon2id parameters: %w", err)
+	}
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return Argon2Params{}, nil, nil, err
+	}
+	key, err := base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil {
+		return Argon2Params{}, nil, nil, err
+	}
+	params.SaltLength = uint32(len(salt))
+	params.KeyLength = uint32(len(key))
+	return params, salt, key, nil
+}
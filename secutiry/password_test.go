@@ -0,0 +1,89 @@
+package secutiry
+
+import (
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/bcrypt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var testArgon2Params = Argon2Params{Memory: 1024, Iterations: 1, Parallelism: 1, SaltLength: 16, KeyLength: 32}
+
+func TestPasswordPolicy(t *testing.T) {
+	policy := PasswordPolicy{MinLength: 8, RequireUpper: true, RequireLower: true, RequireDigit: true, RequireSymbol: true}
+	assert.Error(t, policy.Validate("Ab1!"))
+	assert.Error(t, policy.Validate("abcdefg1!"))
+	assert.Error(t, policy.Validate("ABCDEFG1!"))
+	assert.Error(t, policy.Validate("Abcdefgh!"))
+	assert.Error(t, policy.Validate("Abcdefgh1"))
+	assert.NoError(t, policy.Validate("Abcdefg1!"))
+	assert.NoError(t, PasswordPolicy{}.Validate("x"))
+}
+
+func TestPasswordHasherMaxLength(t *testing.T) {
+	hasher := PasswordHasher{Algorithm: AlgorithmBcrypt}
+	assert.NoError(t, hasher.Validate(strings.Repeat("a", 72)))
+	assert.EqualError(t, hasher.Validate(strings.Repeat("a", 73)), "password must be at most 72 bytes long")
+	assert.Error(t, hasher.Validate(strings.Repeat("ü", 37)))
+	assert.NoError(t, PasswordHasher{Algorithm: AlgorithmArgon2id}.Validate(strings.Repeat("a", 200)))
+	assert.NoError(t, PasswordPolicy{}.Validate(strings.Repeat("a", 200)))
+}
+
+func TestLoadDenyList(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "common.txt")
+	assert.NoError(t, os.WriteFile(path, []byte("# common passwords\nPassword1\n\nqwerty123\n"), 0o600))
+	denyList, err := LoadDenyList(path)
+	assert.NoError(t, err)
+	assert.Len(t, denyList, 2)
+	policy := PasswordPolicy{MinLength: 8, DenyList: denyList}
+	assert.EqualError(t, policy.Validate("PASSWORD1"), "password is too common")
+	assert.NoError(t, policy.Validate("correct horse battery"))
+}
+
+func TestBcryptHasher(t *testing.T) {
+	hasher := PasswordHasher{Algorithm: AlgorithmBcrypt, BcryptCost: bcrypt.MinCost + 1}
+	hash, err := hasher.Hash("secret")
+	assert.NoError(t, err)
+	ok, rehash, err := hasher.Verify(hash, "secret")
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	assert.False(t, rehash)
+	ok, _, err = hasher.Verify(hash, "wrong")
+	assert.NoError(t, err)
+	assert.False(t, ok)
+
+	weak, err := bcrypt.GenerateFromPassword([]byte("secret"), bcrypt.MinCost)
+	assert.NoError(t, err)
+	ok, rehash, err = hasher.Verify(string(weak), "secret")
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	assert.True(t, rehash)
+}
+
+func TestArgon2Hasher(t *testing.T) {
+	hasher := PasswordHasher{Algorithm: AlgorithmArgon2id, Argon2: testArgon2Params}
+	hash, err := hasher.Hash("secret")
+	assert.NoError(t, err)
+	assert.Contains(t, hash, "$argon2id$v=19$m=1024,t=1,p=1$")
+	ok, rehash, err := hasher.Verify(hash, "secret")
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	assert.False(t, rehash)
+	ok, _, err = hasher.Verify(hash, "wrong")
+	assert.NoError(t, err)
+	assert.False(t, ok)
+
+	stronger := testArgon2Params
+	stronger.Iterations = 2
+	_, rehash, err = PasswordHasher{Algorithm: AlgorithmArgon2id, Argon2: stronger}.Verify(hash, "secret")
+	assert.NoError(t, err)
+	assert.True(t, rehash)
+	_, rehash, err = PasswordHasher{Algorithm: AlgorithmBcrypt, BcryptCost: bcrypt.MinCost}.Verify(hash, "secret")
+	assert.NoError(t, err)
+	assert.True(t, rehash)
+
+	_, _, err = hasher.Verify("$argon2id$broken", "secret")
+	assert.Error(t, err)
+}
@@ -0,0 +1,41 @@
+package secutiry
+
+import (
+	"UserStorage/models"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"slices"
+)
+
+const (
+	ContextSubject = "subject"
+	ContextRole    = "role"
+)
+
+func Subject(c *gin.Context) string {
+	return c.GetString(ContextSubject)
+}
+
+func IsAdmin(c *gin.Context) bool {
+	return c.GetString(ContextRole) == models.RoleAdmin
+}
+
+func RequireRole(roles ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !slices.Contains(roles, c.GetString(ContextRole)) {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "forbidden"})
+			return
+		}
+		c.Next()
+	}
+}
+
+func SelfOrAdmin(param string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !IsAdmin(c) && Subject(c) != c.Param(param) {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "forbidden"})
+			return
+		}
+		c.Next()
+	}
+}
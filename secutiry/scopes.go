@@ -0,0 +1,90 @@
+package secutiry
+
+import (
+	"errors"
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+)
+
+const (
+	ContextScopes    = "scopes"
+	ContextExpiresAt = "expiresAt"
+	ContextTokenID   = "jti"
+	ContextParents   = "parents"
+	ContextAPIKeyID  = "apiKeyID"
+
+	ScopeUsersRead  = "users:read"
+	ScopeUsersWrite = "users:write"
+	ScopeFilesRead  = "files:read"
+	ScopeFilesWrite = "files:write"
+)
+
+var AllScopes = []string{ScopeUsersRead, ScopeUsersWrite, ScopeFilesRead, ScopeFilesWrite}
+
+var ErrScopeNotGranted = errors.New("requested scope is not granted to the current token")
+
+func ValidScopes(scopes []string) bool {
+	if len(scopes) == 0 {
+		return false
+	}
+	for _, scope := range scopes {
+		if !slices.Contains(AllScopes, scope) {
+			return false
+		}
+	}
+	return true
+}
+
+func Scopes(c *gin.Context) []string {
+	return c.GetStringSlice(ContextScopes)
+}
+
+func HasScopes(c *gin.Context, scopes ...string) bool {
+	granted := Scopes(c)
+	for _, scope := range scopes {
+		if !slices.Contains(granted, scope) {
+			return false
+		}
+	}
+	return true
+}
+
+func (ao *AuthObj) RequireScopes(scopes ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !HasScopes(c, scopes...) {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "insufficient scope"})
+			return
+		}
+		c.Next()
+	}
+}
+
+func (ao *AuthObj) Downscope(c *gin.Context, scopes []string) (string, time.Time, error) {
+	if !HasScopes(c, scopes...) {
+		return "", time.Time{}, ErrScopeNotGranted
+	}
+	expiresAt := time.Now().Add(AccessTokenTTL)
+	if parent := c.GetTime(ContextExpiresAt); !parent.IsZero() && parent.Before(expiresAt) {
+		expiresAt = parent
+	}
+	parents := slices.Clone(c.GetStringSlice(ContextParents))
+	if jti := c.GetString(ContextTokenID); jti != "" {
+		parents = append(parents, jti)
+	}
+	token, err := ao.createToken(Claims{
+		Role:             c.GetString(ContextRole),
+		Scope:            strings.Join(scopes, " "),
+		SID:              SessionID(c),
+		Parents:          parents,
+		KeyID:            c.GetString(ContextAPIKeyID),
+		RegisteredClaims: jwt.RegisteredClaims{Subject: Subject(c)},
+	}, expiresAt)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	return token, expiresAt, nil
+}
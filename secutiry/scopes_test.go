@@ -0,0 +1,139 @@
+package secutiry
+
+import (
+	"UserStorage/dbhandler"
+	"UserStorage/models"
+	"context"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestCreateTokenScopes(t *testing.T) {
+	auth := NewAuthObj([]byte("test"))
+	token, err := auth.CreateToken("test@test.pl", models.RoleUser, AllScopes...)
+	assert.NoError(t, err)
+	claims, err := auth.ValidateToken(token)
+	assert.NoError(t, err)
+	assert.Equal(t, claims.Scopes(), AllScopes)
+
+	token, err = auth.CreateToken("test@test.pl", models.RoleUser, ScopeFilesRead)
+	assert.NoError(t, err)
+	claims, err = auth.ValidateToken(token)
+	assert.NoError(t, err)
+	assert.Equal(t, claims.Scope, ScopeFilesRead)
+}
+
+func TestRequireScopes(t *testing.T) {
+	auth := NewAuthObj([]byte("test"), WithAPIKeyStore(dbhandler.NewMemoryAPIKeyStore()))
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.DELETE("/users/:id", auth.Auth(), auth.RequireScopes(ScopeUsersWrite), func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+	do := func(header string) int {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodDelete, "/users/test@test.pl", nil)
+		req.Header.Set("Authorization", header)
+		r.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	readOnly, err := auth.CreateToken("test@test.pl", models.RoleUser, ScopeUsersRead)
+	assert.NoError(t, err)
+	assert.Equal(t, do("Bearer "+readOnly), http.StatusForbidden)
+	full, err := auth.CreateToken("test@test.pl", models.RoleUser, AllScopes...)
+	assert.NoError(t, err)
+	assert.Equal(t, do("Bearer "+full), http.StatusOK)
+
+	key, _, err := auth.IssueAPIKey(context.Background(), models.APIKey{
+		UserID:    "test@test.pl",
+		Role:      models.RoleUser,
+		Scopes:    []string{ScopeFilesRead},
+		ExpiresAt: time.Now().Add(time.Hour),
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, do("ApiKey "+key), http.StatusForbidden)
+}
+
+func TestDownscope(t *testing.T) {
+	auth := NewAuthObj([]byte("test"))
+	gin.SetMode(gin.TestMode)
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	parentExp := time.Now().Add(time.Minute).Truncate(time.Second)
+	c.Set(ContextSubject, "test@test.pl")
+	c.Set(ContextRole, models.RoleUser)
+	c.Set(ContextScopes, []string{ScopeUsersRead, ScopeFilesRead})
+	c.Set(ContextExpiresAt, parentExp)
+
+	token, expiresAt, err := auth.Downscope(c, []string{ScopeFilesRead})
+	assert.NoError(t, err)
+	assert.Equal(t, expiresAt, parentExp)
+	claims, err := auth.ValidateToken(token)
+	assert.NoError(t, err)
+	assert.Equal(t, claims.Subject, "test@test.pl")
+	assert.Equal(t, claims.Scopes(), []string{ScopeFilesRead})
+	assert.Equal(t, claims.ExpiresAt.Time, parentExp)
+
+	_, _, err = auth.Downscope(c, []string{ScopeFilesWrite})
+	assert.ErrorIs(t, err, ErrScopeNotGranted)
+}
+
+func TestTokenWithoutScopeGrantsNothing(t *testing.T) {
+	assert.Empty(t, Claims{}.Scopes())
+	_, err := NewAuthObj([]byte("test")).CreateToken("test@test.pl", models.RoleUser)
+	assert.Error(t, err)
+}
+
+func TestDownscopedTokenDiesWithParent(t *testing.T) {
+	keys := dbhandler.NewMemoryAPIKeyStore()
+	auth := NewAuthObj([]byte("test"), WithAPIKeyStore(keys), WithRevocationStore(dbhandler.NewMemoryRevocationStore()))
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.POST("/downscope", auth.Auth(), func(c *gin.Context) {
+		token, _, err := auth.Downscope(c, []string{ScopeFilesRead})
+		assert.NoError(t, err)
+		c.String(http.StatusOK, token)
+	})
+	r.GET("/files", auth.Auth(), auth.RequireScopes(ScopeFilesRead), func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+	do := func(method string, path string, header string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(method, path, nil)
+		req.Header.Set("Authorization", header)
+		r.ServeHTTP(w, req)
+		return w
+	}
+	downscope := func(header string) string {
+		w := do(http.MethodPost, "/downscope", header)
+		assert.Equal(t, w.Code, http.StatusOK)
+		return "Bearer " + w.Body.String()
+	}
+
+	parent, err := auth.CreateToken("test@test.pl", models.RoleUser, AllScopes...)
+	assert.NoError(t, err)
+	child := downscope("Bearer " + parent)
+	grandchild := downscope(child)
+	assert.Equal(t, do(http.MethodGet, "/files", grandchild).Code, http.StatusOK)
+	claims, err := auth.ValidateToken(parent)
+	assert.NoError(t, err)
+	assert.NoError(t, auth.RevokeToken(context.Background(), claims.ID))
+	assert.Equal(t, do(http.MethodGet, "/files", child).Code, http.StatusUnauthorized)
+	assert.Equal(t, do(http.MethodGet, "/files", grandchild).Code, http.StatusUnauthorized)
+
+	raw, key, err := auth.IssueAPIKey(context.Background(), models.APIKey{
+		UserID:    "test@test.pl",
+		Role:      models.RoleUser,
+		Scopes:    AllScopes,
+		ExpiresAt: time.Now().Add(time.Hour),
+	})
+	assert.NoError(t, err)
+	child = downscope("ApiKey " + raw)
+	assert.Equal(t, do(http.MethodGet, "/files", child).Code, http.StatusOK)
+	assert.NoError(t, auth.RevokeAPIKey(context.Background(), "test@test.pl", key.ID))
+	assert.Equal(t, do(http.MethodGet, "/files", child).Code, http.StatusUnauthorized)
+}
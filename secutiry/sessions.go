@@ -0,0 +1,58 @@
+package secutiry
+
+import (
+	"UserStorage/models"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"github.com/gin-gonic/gin"
+	"time"
+)
+
+const ContextSessionID = "sid"
+
+var ErrSessionsDisabled = errors.New("sessions are not configured")
+
+func SessionID(c *gin.Context) string {
+	return c.GetString(ContextSessionID)
+}
+
+func (ao *AuthObj) StartSession(ctx context.Context, session models.Session) (models.Session, error) {
+	if ao.sessions == nil {
+		return models.Session{}, nil
+	}
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return models.Session{}, err
+	}
+	now := time.Now()
+	session.ID = hex.EncodeToString(id)
+	session.CreatedAt = now
+	session.LastSeen = now
+	if err := ao.sessions.CreateSession(ctx, session); err != nil {
+		return models.Session{}, err
+	}
+	return session, nil
+}
+
+func (ao *AuthObj) RotateSession(ctx context.Context, id string, refreshTokenID string, expiresAt time.Time) error {
+	if ao.sessions == nil || id == "" {
+		return nil
+	}
+	return ao.sessions.RotateSession(ctx, id, refreshTokenID, expiresAt)
+}
+
+func (ao *AuthObj) Sessions(ctx context.Context, userID string) ([]models.Session, error) {
+	if ao.sessions == nil {
+		return nil, ErrSessionsDisabled
+	}
+	return ao.sessions.ListSessions(ctx, userID)
+}
+
+func (ao *AuthObj) EndSession(ctx context.Context, userID string, id string) (models.Session, error) {
+	if ao.sessions == nil {
+		return models.Session{}, ErrSessionsDisabled
+	}
+	return ao.sessions.DeleteSession(ctx, userID, id)
+}
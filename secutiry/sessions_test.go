@@ -0,0 +1,40 @@
+package secutiry
+
+import (
+	"UserStorage/dbhandler"
+	"UserStorage/models"
+	"context"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestSessionTerminated(t *testing.T) {
+	auth := NewAuthObj([]byte("test"), WithSessionStore(dbhandler.NewMemorySessionStore()))
+	r := newTestRouter(auth)
+	session, err := auth.StartSession(context.Background(), models.Session{UserID: "test@test.pl", ExpiresAt: time.Now().Add(time.Hour)})
+	assert.NoError(t, err)
+	assert.NotEmpty(t, session.ID)
+	token, err := auth.CreateSessionToken(session.ID, "test@test.pl", models.RoleUser)
+	assert.NoError(t, err)
+	claims, err := auth.ValidateToken(token)
+	assert.NoError(t, err)
+	assert.Equal(t, claims.SID, session.ID)
+	assert.Equal(t, doRequest(r, "/users/test@test.pl", token), http.StatusOK)
+
+	_, err = auth.EndSession(context.Background(), "other@test.pl", session.ID)
+	assert.ErrorIs(t, err, dbhandler.ErrNotFound)
+	_, err = auth.EndSession(context.Background(), "test@test.pl", session.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, doRequest(r, "/users/test@test.pl", token), http.StatusUnauthorized)
+}
+
+func TestSessionsDisabled(t *testing.T) {
+	auth := NewAuthObj([]byte("test"))
+	session, err := auth.StartSession(context.Background(), models.Session{UserID: "test@test.pl"})
+	assert.NoError(t, err)
+	assert.Empty(t, session.ID)
+	_, err = auth.Sessions(context.Background(), "test@test.pl")
+	assert.ErrorIs(t, err, ErrSessionsDisabled)
+}
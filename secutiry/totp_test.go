@@ -0,0 +1,68 @@
+package secutiry
+
+import (
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTOTPCodeRFC6238(t *testing.T) {
+	secret := totpEncoding.EncodeToString([]byte("12345678901234567890"))
+	for unix, want := range map[int64]string{
+		59:         "287082",
+		1111111109: "081804",
+		1234567890: "005924",
+		2000000000: "279037",
+	} {
+		code, err := TOTPCode(secret, time.Unix(unix, 0))
+		assert.NoError(t, err)
+		assert.Equal(t, code, want)
+	}
+}
+
+func TestValidateTOTP(t *testing.T) {
+	secret, err := NewTOTPSecret()
+	assert.NoError(t, err)
+	now := time.Now()
+	code, err := TOTPCode(secret, now)
+	assert.NoError(t, err)
+	step, ok := ValidateTOTP(secret, code, now, 0)
+	assert.True(t, ok)
+	assert.Equal(t, step, now.Unix()/totpPeriod)
+	_, ok = ValidateTOTP(secret, code, now.Add(30*time.Second), 0)
+	assert.True(t, ok)
+	_, ok = ValidateTOTP(secret, code, now.Add(5*time.Minute), 0)
+	assert.False(t, ok)
+	_, ok = ValidateTOTP(secret, "12345", now, 0)
+	assert.False(t, ok)
+}
+
+func TestValidateTOTPRejectsReplay(t *testing.T) {
+	secret, err := NewTOTPSecret()
+	assert.NoError(t, err)
+	now := time.Now()
+	code, err := TOTPCode(secret, now)
+	assert.NoError(t, err)
+	step, ok := ValidateTOTP(secret, code, now, 0)
+	assert.True(t, ok)
+	_, ok = ValidateTOTP(secret, code, now, step)
+	assert.False(t, ok)
+	_, ok = ValidateTOTP(secret, code, now.Add(30*time.Second), step)
+	assert.False(t, ok)
+}
+
+func TestTOTPURI(t *testing.T) {
+	uri := TOTPURI("UserStorage", "test@test.pl", "ABC")
+	assert.True(t, strings.HasPrefix(uri, "otpauth://totp/UserStorage:test@test.pl?"))
+	assert.Contains(t, uri, "secret=ABC")
+	assert.Contains(t, uri, "issuer=UserStorage")
+}
+
+func TestNewRecoveryCodes(t *testing.T) {
+	codes, err := NewRecoveryCodes(10)
+	assert.NoError(t, err)
+	assert.Len(t, codes, 10)
+	assert.Len(t, codes[0], 9)
+	assert.NotEqual(t, codes[0], codes[1])
+}
@@ -0,0 +1,30 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+	"regexp"
+)
+
+var (
+	ErrNotFound   = errors.New("blob not found")
+	ErrInvalidKey = errors.New("invalid blob key")
+)
+
+var keyPattern = regexp.MustCompile(`^[A-Za-z0-9_-]{2,128}$`)
+
+type Blob interface {
+	io.ReadSeekCloser
+	Size() int64
+}
+
+type BlobStore interface {
+	Put(ctx context.Context, key string, r io.Reader) (int64, error)
+	Open(ctx context.Context, key string) (Blob, error)
+	Delete(ctx context.Context, key string) error
+}
+
+func validKey(key string) bool {
+	return keyPattern.MatchString(key)
+}
@@ -0,0 +1,117 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+	"io"
+)
+
+type GridFSBlobStore struct {
+	bucket *mongo.GridFSBucket
+}
+
+func NewGridFSBlobStore(db *mongo.Database) *GridFSBlobStore {
+	return &GridFSBlobStore{db.GridFSBucket(options.GridFSBucket().SetName("blobs"))}
+}
+
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
+
+func (g GridFSBlobStore) Put(ctx context.Context, key string, r io.Reader) (int64, error) {
+	if !validKey(key) {
+		return 0, ErrInvalidKey
+	}
+	src := &countingReader{r: r}
+	if err := g.bucket.UploadFromStreamWithID(ctx, key, key, src); err != nil {
+		return 0, err
+	}
+	return src.n, nil
+}
+
+func (g GridFSBlobStore) Open(ctx context.Context, key string) (Blob, error) {
+	stream, err := g.bucket.OpenDownloadStream(ctx, key)
+	if errors.Is(err, mongo.ErrFileNotFound) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &gridFSBlob{ctx: ctx, bucket: g.bucket, key: key, size: stream.GetFile().Length, stream: stream}, nil
+}
+
+func (g GridFSBlobStore) Delete(ctx context.Context, key string) error {
+	err := g.bucket.Delete(ctx, key)
+	if errors.Is(err, mongo.ErrFileNotFound) {
+		return ErrNotFound
+	}
+	return err
+}
+
+type gridFSBlob struct {
+	ctx    context.Context
+	bucket *mongo.GridFSBucket
+	key    string
+	size   int64
+	pos    int64
+	stream *mongo.GridFSDownloadStream
+}
+
+func (b *gridFSBlob) Size() int64 {
+	return b.size
+}
+
+func (b *gridFSBlob) Read(p []byte) (int, error) {
+	if b.pos >= b.size {
+		return 0, io.EOF
+	}
+	if b.stream == nil {
+		stream, err := b.bucket.OpenDownloadStream(b.ctx, b.key)
+		if err != nil {
+			return 0, err
+		}
+		if _, err = stream.Skip(b.pos); err != nil {
+			stream.Close()
+			return 0, err
+		}
+		b.stream = stream
+	}
+	n, err := b.stream.Read(p)
+	b.pos += int64(n)
+	return n, err
+}
+
+func (b *gridFSBlob) Seek(offset int64, whence int) (int64, error) {
+	pos := offset
+	switch whence {
+	case io.SeekCurrent:
+		pos += b.pos
+	case io.SeekEnd:
+		pos += b.size
+	}
+	if pos < 0 {
+		return 0, errors.New("seek before start of blob")
+	}
+	if pos != b.pos && b.stream != nil {
+		b.stream.Close()
+		b.stream = nil
+	}
+	b.pos = pos
+	return pos, nil
+}
+
+func (b *gridFSBlob) Close() error {
+	if b.stream == nil {
+		return nil
+	}
+	return b.stream.Close()
+}
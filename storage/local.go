@@ -0,0 +1,96 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+type LocalBlobStore struct {
+	root string
+}
+
+type localBlob struct {
+	*os.File
+	size int64
+}
+
+func (b localBlob) Size() int64 {
+	return b.size
+}
+
+func NewLocalBlobStore(root string) (*LocalBlobStore, error) {
+	if err := os.MkdirAll(root, 0o750); err != nil {
+		return nil, err
+	}
+	return &LocalBlobStore{root}, nil
+}
+
+func (l LocalBlobStore) path(key string) (string, error) {
+	if !validKey(key) {
+		return "", ErrInvalidKey
+	}
+	return filepath.Join(l.root, key[:2], key), nil
+}
+
+func (l LocalBlobStore) Put(_ context.Context, key string, r io.Reader) (int64, error) {
+	path, err := l.path(key)
+	if err != nil {
+		return 0, err
+	}
+	if err = os.MkdirAll(filepath.Dir(path), 0o750); err != nil {
+		return 0, err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), key+".tmp*")
+	if err != nil {
+		return 0, err
+	}
+	defer os.Remove(tmp.Name())
+	size, err := io.Copy(tmp, r)
+	if err != nil {
+		tmp.Close()
+		return 0, err
+	}
+	if err = tmp.Close(); err != nil {
+		return 0, err
+	}
+	if err = os.Rename(tmp.Name(), path); err != nil {
+		return 0, err
+	}
+	return size, nil
+}
+
+func (l LocalBlobStore) Open(_ context.Context, key string) (Blob, error) {
+	path, err := l.path(key)
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	return localBlob{f, info.Size()}, nil
+}
+
+func (l LocalBlobStore) Delete(_ context.Context, key string) error {
+	path, err := l.path(key)
+	if err != nil {
+		return err
+	}
+	err = os.Remove(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return ErrNotFound
+	}
+	return err
+}
@@ -0,0 +1,59 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"sync"
+)
+
+type MemoryBlobStore struct {
+	mu    sync.Mutex
+	blobs map[string][]byte
+}
+
+type memoryBlob struct {
+	*bytes.Reader
+}
+
+func (b memoryBlob) Close() error {
+	return nil
+}
+
+func NewMemoryBlobStore() *MemoryBlobStore {
+	return &MemoryBlobStore{blobs: map[string][]byte{}}
+}
+
+func (m *MemoryBlobStore) Put(_ context.Context, key string, r io.Reader) (int64, error) {
+	if !validKey(key) {
+		return 0, ErrInvalidKey
+	}
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return 0, err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.blobs[key] = data
+	return int64(len(data)), nil
+}
+
+func (m *MemoryBlobStore) Open(_ context.Context, key string) (Blob, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	data, ok := m.blobs[key]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return memoryBlob{bytes.NewReader(data)}, nil
+}
+
+func (m *MemoryBlobStore) Delete(_ context.Context, key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.blobs[key]; !ok {
+		return ErrNotFound
+	}
+	delete(m.blobs, key)
+	return nil
+}
@@ -0,0 +1,44 @@
+package storage
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"strings"
+	"testing"
+)
+
+func testBlobStore(t *testing.T, store BlobStore) {
+	ctx := context.Background()
+	size, err := store.Put(ctx, "abc123", strings.NewReader("hello world"))
+	assert.NoError(t, err)
+	assert.Equal(t, size, int64(11))
+
+	blob, err := store.Open(ctx, "abc123")
+	assert.NoError(t, err)
+	assert.Equal(t, blob.Size(), int64(11))
+	_, err = blob.Seek(6, io.SeekStart)
+	assert.NoError(t, err)
+	rest, err := io.ReadAll(blob)
+	assert.NoError(t, err)
+	assert.Equal(t, string(rest), "world")
+	assert.NoError(t, blob.Close())
+
+	assert.NoError(t, store.Delete(ctx, "abc123"))
+	_, err = store.Open(ctx, "abc123")
+	assert.ErrorIs(t, err, ErrNotFound)
+	assert.ErrorIs(t, store.Delete(ctx, "abc123"), ErrNotFound)
+
+	_, err = store.Put(ctx, "../escape", strings.NewReader("x"))
+	assert.ErrorIs(t, err, ErrInvalidKey)
+}
+
+func TestLocalBlobStore(t *testing.T) {
+	store, err := NewLocalBlobStore(t.TempDir())
+	assert.NoError(t, err)
+	testBlobStore(t, store)
+}
+
+func TestMemoryBlobStore(t *testing.T) {
+	testBlobStore(t, NewMemoryBlobStore())
+}
@@ -0,0 +1,121 @@
+package user
+
+import (
+	"UserStorage/dbhandler"
+	"UserStorage/models"
+	"context"
+	"errors"
+	"github.com/gin-gonic/gin"
+	"net/http"
+)
+
+func (uh *UserHandler) Revoke(c *gin.Context) {
+	var req models.RevokeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if (req.JTI == "") == (req.UserID == "") {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "exactly one of jti or userID is required"})
+		return
+	}
+	if req.JTI != "" {
+		if err := uh.auth.RevokeToken(c.Request.Context(), req.JTI); err != nil {
+			uh.logger.Error(err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"message": "token revoked"})
+		return
+	}
+	if err := uh.auth.RevokeUser(c.Request.Context(), req.UserID); err != nil {
+		uh.logger.Error(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if err := uh.dbHan.DeleteTokens(c.Request.Context(), req.UserID, models.TokenRefresh); err != nil {
+		uh.logger.Error(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "user tokens revoked"})
+}
+
+func (uh *UserHandler) Unlock(c *gin.Context) {
+	id := c.Param("id")
+	err := uh.dbHan.SetLockout(c.Request.Context(), id, models.Lockout{})
+	if errors.Is(err, dbhandler.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		uh.logger.Error(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	uh.rabbit.Publish(models.LockoutEvent{EventType: "LoginUnlocked", UserID: id})
+	c.JSON(http.StatusOK, gin.H{"message": "account unlocked"})
+}
+
+func (uh *UserHandler) RestoreUser(c *gin.Context) {
+	id := c.Param("id")
+	before := uh.snapshot(c, id)
+	err := uh.dbHan.RestoreUser(c.Request.Context(), id)
+	if errors.Is(err, dbhandler.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "no deleted user with this id"})
+		return
+	}
+	if err != nil {
+		uh.logger.Error(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	uh.rabbit.Publish(models.Event{EventType: "UserRestored", UserID: id})
+	uh.audit(c, models.AuditUserRestored, id, before, uh.snapshot(c, id))
+	c.JSON(http.StatusOK, gin.H{"message": "user restored"})
+}
+
+func (uh *UserHandler) BootstrapAdmin(ctx context.Context, req models.NewUserRequest) error {
+	existing, err := uh.dbHan.GetUser(ctx, req.Email)
+	if err == nil {
+		if existing.Kind == models.UserKindService {
+			return errors.New("admin email belongs to a service account")
+		}
+		if existing.Role == models.RoleAdmin && existing.Status == models.UserStatusActive {
+			return nil
+		}
+		promoted := existing
+		promoted.Role = models.RoleAdmin
+		promoted.Status = models.UserStatusActive
+		if err = uh.dbHan.UpdateUser(ctx, promoted); err != nil {
+			return err
+		}
+		promoted.Version++
+		uh.revokeAccess(ctx, promoted.Email)
+		uh.rabbit.Publish(models.Event{EventType: "UserUpdated", UserID: promoted.Email, Age: promoted.Age, NoFiles: len(promoted.Files)})
+		uh.appendAudit(ctx, models.AuditEntry{Action: models.AuditUserUpdated, Target: promoted.Email}, &existing, &promoted)
+		uh.logger.Infof("promoted %s to admin", promoted.Email)
+		return nil
+	}
+	if !errors.Is(err, dbhandler.ErrNotFound) {
+		return err
+	}
+	if req.Password == "" {
+		return errors.New("admin-password is required to create the admin")
+	}
+	req.Role = models.RoleAdmin
+	input, err := uh.validateNewUser(req, false)
+	if err != nil {
+		return err
+	}
+	if input.Password, err = uh.hasher.Hash(req.Password); err != nil {
+		return err
+	}
+	if err = uh.dbHan.CreateUser(ctx, input); err != nil {
+		return err
+	}
+	uh.rabbit.Publish(models.Event{EventType: "UserCreated", UserID: input.Email, Age: input.Age, NoFiles: len(input.Files)})
+	uh.appendAudit(ctx, models.AuditEntry{Action: models.AuditUserCreated, Target: input.Email}, nil, &input)
+	uh.logger.Infof("created admin %s", input.Email)
+	return nil
+}
@@ -0,0 +1,148 @@
+package user
+
+import (
+	"UserStorage/dbhandler"
+	"UserStorage/models"
+	"UserStorage/queueHandler"
+	"UserStorage/secutiry"
+	"context"
+	"encoding/json"
+	"github.com/golang/mock/gomock"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestRevokeUserTokens(t *testing.T) {
+	w := httptest.NewRecorder()
+	ctx := GetTestGinContext(w)
+	var logger = logrus.New()
+	MockJsonPost(ctx, models.RevokeRequest{UserID: "test@test.pl"}, "")
+	ctrl := gomock.NewController(t)
+	testDB := dbhandler.NewMockDBHandler(ctrl)
+	testMQ := queueHandler.NewMockQueueHandler(ctrl)
+	keys := dbhandler.NewMemoryAPIKeyStore()
+	assert.NoError(t, keys.CreateAPIKey(context.Background(), models.APIKey{ID: "key", UserID: "test@test.pl"}))
+	auth := secutiry.NewAuthObj([]byte("test"),
+		secutiry.WithRevocationStore(dbhandler.NewMemoryRevocationStore()),
+		secutiry.WithAPIKeyStore(keys))
+	testDB.EXPECT().DeleteTokens(gomock.Any(), "test@test.pl", models.TokenRefresh).Return(nil)
+	testObj := NewUserHandler(logger, testDB, testMQ, auth)
+	testObj.Revoke(ctx)
+	assert.Equal(t, w.Code, http.StatusOK)
+	msgOut := msgInf{}
+	err := json.NewDecoder(w.Body).Decode(&msgOut)
+	assert.NoError(t, err)
+	assert.Equal(t, msgOut.Message, "user tokens revoked")
+	listed, err := keys.ListAPIKeys(context.Background(), "test@test.pl")
+	assert.NoError(t, err)
+	assert.Empty(t, listed)
+}
+
+func TestRevokeToken(t *testing.T) {
+	w := httptest.NewRecorder()
+	ctx := GetTestGinContext(w)
+	var logger = logrus.New()
+	MockJsonPost(ctx, models.RevokeRequest{JTI: "jti"}, "")
+	ctrl := gomock.NewController(t)
+	testDB := dbhandler.NewMockDBHandler(ctrl)
+	testMQ := queueHandler.NewMockQueueHandler(ctrl)
+	store := dbhandler.NewMemoryRevocationStore()
+	auth := secutiry.NewAuthObj([]byte("test"), secutiry.WithRevocationStore(store))
+	testObj := NewUserHandler(logger, testDB, testMQ, auth)
+	testObj.Revoke(ctx)
+	assert.Equal(t, w.Code, http.StatusOK)
+	revoked, err := store.IsRevoked(ctx, "jti", "", time.Now())
+	assert.NoError(t, err)
+	assert.True(t, revoked)
+}
+
+func TestRevokeBadRequest(t *testing.T) {
+	w := httptest.NewRecorder()
+	ctx := GetTestGinContext(w)
+	var logger = logrus.New()
+	MockJsonPost(ctx, models.RevokeRequest{JTI: "jti", UserID: "test@test.pl"}, "")
+	ctrl := gomock.NewController(t)
+	testDB := dbhandler.NewMockDBHandler(ctrl)
+	testMQ := queueHandler.NewMockQueueHandler(ctrl)
+	auth := secutiry.NewAuthObj([]byte("test"), secutiry.WithRevocationStore(dbhandler.NewMemoryRevocationStore()))
+	testObj := NewUserHandler(logger, testDB, testMQ, auth)
+	testObj.Revoke(ctx)
+	assert.Equal(t, w.Code, http.StatusBadRequest)
+}
+
+func TestUnlock(t *testing.T) {
+	w := httptest.NewRecorder()
+	ctx := GetTestGinContext(w)
+	var logger = logrus.New()
+	MockJsonPost(ctx, nil, "test@test.pl")
+	ctrl := gomock.NewController(t)
+	testDB := dbhandler.NewMockDBHandler(ctrl)
+	testMQ := queueHandler.NewMockQueueHandler(ctrl)
+	auth := secutiry.NewAuthObj([]byte("test"))
+	testDB.EXPECT().SetLockout(gomock.Any(), "test@test.pl", models.Lockout{}).Return(nil)
+	testMQ.EXPECT().Publish(gomock.Any())
+	testObj := NewUserHandler(logger, testDB, testMQ, auth)
+	testObj.Unlock(ctx)
+	assert.Equal(t, w.Code, http.StatusOK)
+}
+
+func TestBootstrapAdminPromotes(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	testDB := dbhandler.NewMockDBHandler(ctrl)
+	testMQ := queueHandler.NewMockQueueHandler(ctrl)
+	testDB.EXPECT().GetUser(gomock.Any(), "test@test.pl").Return(models.User{Email: "test@test.pl", Age: 30, Role: models.RoleUser, Status: models.UserStatusPending}, nil)
+	testDB.EXPECT().UpdateUser(gomock.Any(), gomock.Any()).DoAndReturn(func(_ any, usr models.User) error {
+		assert.Equal(t, usr.Role, models.RoleAdmin)
+		assert.Equal(t, usr.Status, models.UserStatusActive)
+		return nil
+	})
+	expectRevokeAccess(testDB, "test@test.pl")
+	testMQ.EXPECT().Publish(gomock.Any())
+	testObj := NewUserHandler(logrus.New(), testDB, testMQ, secutiry.NewAuthObj([]byte("test")))
+	err := testObj.BootstrapAdmin(context.Background(), models.NewUserRequest{Email: "test@test.pl"})
+	assert.NoError(t, err)
+}
+
+func TestBootstrapAdminAlreadyAdmin(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	testDB := dbhandler.NewMockDBHandler(ctrl)
+	testDB.EXPECT().GetUser(gomock.Any(), "test@test.pl").Return(models.User{Email: "test@test.pl", Role: models.RoleAdmin, Status: models.UserStatusActive}, nil)
+	testObj := NewUserHandler(logrus.New(), testDB, queueHandler.NewMockQueueHandler(ctrl), secutiry.NewAuthObj([]byte("test")))
+	err := testObj.BootstrapAdmin(context.Background(), models.NewUserRequest{Email: "test@test.pl"})
+	assert.NoError(t, err)
+}
+
+func TestBootstrapAdminCreates(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	testDB := dbhandler.NewMockDBHandler(ctrl)
+	testMQ := queueHandler.NewMockQueueHandler(ctrl)
+	testDB.EXPECT().GetUser(gomock.Any(), "test@test.pl").Return(models.User{}, dbhandler.ErrNotFound)
+	testDB.EXPECT().CreateUser(gomock.Any(), gomock.Any()).DoAndReturn(func(_ any, usr models.User) error {
+		assert.Equal(t, usr.Role, models.RoleAdmin)
+		assert.Equal(t, usr.Status, models.UserStatusActive)
+		assert.NotEqual(t, usr.Password, "Secret123!")
+		return nil
+	})
+	testMQ.EXPECT().Publish(gomock.Any())
+	testObj := NewUserHandler(logrus.New(), testDB, testMQ, secutiry.NewAuthObj([]byte("test")))
+	err := testObj.BootstrapAdmin(context.Background(), models.NewUserRequest{Email: "test@test.pl", Age: 30, Password: "Secret123!"})
+	assert.NoError(t, err)
+}
+
+func TestBootstrapAdminRejected(t *testing.T) {
+	for name, req := range map[string]models.NewUserRequest{
+		"no password": {Email: "test@test.pl", Age: 30},
+		"underage":    {Email: "test@test.pl", Age: 12, Password: "Secret123!"},
+	} {
+		ctrl := gomock.NewController(t)
+		testDB := dbhandler.NewMockDBHandler(ctrl)
+		testDB.EXPECT().GetUser(gomock.Any(), "test@test.pl").Return(models.User{}, dbhandler.ErrNotFound)
+		testObj := NewUserHandler(logrus.New(), testDB, queueHandler.NewMockQueueHandler(ctrl), secutiry.NewAuthObj([]byte("test")))
+		err := testObj.BootstrapAdmin(context.Background(), req)
+		assert.Error(t, err, name)
+	}
+}
@@ -0,0 +1,134 @@
+package user
+
+import (
+	"UserStorage/dbhandler"
+	"UserStorage/models"
+	"UserStorage/secutiry"
+	"errors"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"regexp"
+	"time"
+)
+
+var serviceAccountName = regexp.MustCompile(`^[a-z0-9][a-z0-9-]{0,62}$`)
+
+func (uh *UserHandler) CreateAPIKey(c *gin.Context) {
+	id := c.Param("id")
+	var req models.APIKeyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if !secutiry.ValidScopes(req.Scopes) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid scopes"})
+		return
+	}
+	if !secutiry.HasScopes(c, req.Scopes...) {
+		c.JSON(http.StatusForbidden, gin.H{"error": secutiry.ErrScopeNotGranted.Error()})
+		return
+	}
+	ttl := secutiry.APIKeyTTL
+	if req.ExpiresIn != 0 {
+		ttl = time.Duration(req.ExpiresIn) * time.Second
+	}
+	if ttl <= 0 || ttl > secutiry.MaxAPIKeyTTL {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid expiresIn"})
+		return
+	}
+	user, err := uh.dbHan.GetUser(c.Request.Context(), id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		uh.logger.Error(err)
+		return
+	}
+	role := user.Role
+	if role == "" {
+		role = models.RoleUser
+	}
+	raw, key, err := uh.auth.IssueAPIKey(c.Request.Context(), models.APIKey{
+		UserID:    id,
+		Name:      req.Name,
+		Role:      role,
+		Scopes:    req.Scopes,
+		ExpiresAt: time.Now().Add(ttl),
+	})
+	if err != nil {
+		uh.logger.Error(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	uh.rabbit.Publish(models.Event{EventType: "APIKeyCreated", UserID: id})
+	c.JSON(http.StatusCreated, models.NewAPIKeyResponse{APIKey: key, Key: raw})
+}
+
+func (uh *UserHandler) GetAPIKeys(c *gin.Context) {
+	id := c.Param("id")
+	keys, err := uh.auth.APIKeys(c.Request.Context(), id)
+	if err != nil {
+		uh.logger.Error(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, keys)
+}
+
+func (uh *UserHandler) DeleteAPIKey(c *gin.Context) {
+	id := c.Param("id")
+	err := uh.auth.RevokeAPIKey(c.Request.Context(), id, c.Param("keyId"))
+	if errors.Is(err, dbhandler.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		uh.logger.Error(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	uh.rabbit.Publish(models.Event{EventType: "APIKeyRevoked", UserID: id})
+	c.JSON(http.StatusOK, gin.H{"message": "api key revoked"})
+}
+
+func (uh *UserHandler) CreateServiceAccount(c *gin.Context) {
+	var req models.ServiceAccountRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if !serviceAccountName.MatchString(req.Name) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid service account name"})
+		return
+	}
+	if req.Role == "" {
+		req.Role = models.RoleUser
+	}
+	if !models.ValidRole(req.Role) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user role"})
+		return
+	}
+	account := models.User{
+		Email:    models.ServiceAccountPrefix + req.Name,
+		Username: req.Name,
+		Role:     req.Role,
+		Status:   models.UserStatusActive,
+		Kind:     models.UserKindService,
+	}
+	_, err := uh.dbHan.GetUserIncludingDeleted(c.Request.Context(), account.Email)
+	if err == nil {
+		c.JSON(http.StatusConflict, gin.H{"error": dbhandler.ErrDuplicate.Error()})
+		return
+	}
+	if !errors.Is(err, dbhandler.ErrNotFound) {
+		uh.logger.Error(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if err = uh.dbHan.CreateUser(c.Request.Context(), account); err != nil {
+		uh.logger.Error(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	uh.rabbit.Publish(models.Event{EventType: "UserCreated", UserID: account.Email})
+	uh.audit(c, models.AuditUserCreated, account.Email, nil, &account)
+	c.JSON(http.StatusCreated, account)
+}
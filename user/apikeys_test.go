@@ -0,0 +1,182 @@
+package user
+
+import (
+	"UserStorage/dbhandler"
+	"UserStorage/models"
+	"UserStorage/queueHandler"
+	"UserStorage/secutiry"
+	"encoding/json"
+	"github.com/gin-gonic/gin"
+	"github.com/golang/mock/gomock"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestCreateAPIKey(t *testing.T) {
+	w := httptest.NewRecorder()
+	ctx := GetTestGinContext(w)
+	var logger = logrus.New()
+	MockJsonPost(ctx, models.APIKeyRequest{Name: "batch", Scopes: []string{secutiry.ScopeUsersRead}}, "batch@test.pl")
+	ctx.Set(secutiry.ContextScopes, secutiry.AllScopes)
+	ctrl := gomock.NewController(t)
+	testDB := dbhandler.NewMockDBHandler(ctrl)
+	testMQ := queueHandler.NewMockQueueHandler(ctrl)
+	store := dbhandler.NewMemoryAPIKeyStore()
+	auth := secutiry.NewAuthObj([]byte("test"), secutiry.WithAPIKeyStore(store))
+	testDB.EXPECT().GetUser(gomock.Any(), "batch@test.pl").Return(models.User{Email: "batch@test.pl", Role: models.RoleAdmin}, nil)
+	testMQ.EXPECT().Publish(models.Event{EventType: "APIKeyCreated", UserID: "batch@test.pl"})
+	testObj := NewUserHandler(logger, testDB, testMQ, auth)
+	testObj.CreateAPIKey(ctx)
+	assert.Equal(t, w.Code, http.StatusCreated)
+	var out models.NewAPIKeyResponse
+	err := json.NewDecoder(w.Body).Decode(&out)
+	assert.NoError(t, err)
+	assert.NotEmpty(t, out.Key)
+	key, err := auth.ValidateAPIKey(ctx, out.Key)
+	assert.NoError(t, err)
+	assert.Equal(t, key.ID, out.ID)
+	assert.Equal(t, key.Role, models.RoleAdmin)
+	assert.Equal(t, key.Scopes, []string{secutiry.ScopeUsersRead})
+}
+
+func TestCreateAPIKeyInvalidScope(t *testing.T) {
+	w := httptest.NewRecorder()
+	ctx := GetTestGinContext(w)
+	var logger = logrus.New()
+	MockJsonPost(ctx, models.APIKeyRequest{Name: "batch", Scopes: []string{"everything"}}, "batch@test.pl")
+	ctrl := gomock.NewController(t)
+	testDB := dbhandler.NewMockDBHandler(ctrl)
+	testMQ := queueHandler.NewMockQueueHandler(ctrl)
+	auth := secutiry.NewAuthObj([]byte("test"), secutiry.WithAPIKeyStore(dbhandler.NewMemoryAPIKeyStore()))
+	testObj := NewUserHandler(logger, testDB, testMQ, auth)
+	testObj.CreateAPIKey(ctx)
+	assert.Equal(t, w.Code, http.StatusBadRequest)
+}
+
+func TestDeleteAPIKeyOtherUser(t *testing.T) {
+	w := httptest.NewRecorder()
+	ctx := GetTestGinContext(w)
+	var logger = logrus.New()
+	ctrl := gomock.NewController(t)
+	testDB := dbhandler.NewMockDBHandler(ctrl)
+	testMQ := queueHandler.NewMockQueueHandler(ctrl)
+	store := dbhandler.NewMemoryAPIKeyStore()
+	auth := secutiry.NewAuthObj([]byte("test"), secutiry.WithAPIKeyStore(store))
+	assert.NoError(t, store.CreateAPIKey(ctx, models.APIKey{ID: "key1", UserID: "owner@test.pl"}))
+	MockJsonDelete(ctx, gin.Params{{Key: "id", Value: "test@test.pl"}, {Key: "keyId", Value: "key1"}})
+	testObj := NewUserHandler(logger, testDB, testMQ, auth)
+	testObj.DeleteAPIKey(ctx)
+	assert.Equal(t, w.Code, http.StatusNotFound)
+	keys, err := store.ListAPIKeys(ctx, "owner@test.pl")
+	assert.NoError(t, err)
+	assert.Len(t, keys, 1)
+}
+
+func TestRoleChangeRevokesAPIKeys(t *testing.T) {
+	w := httptest.NewRecorder()
+	ctx := GetTestGinContext(w)
+	var logger = logrus.New()
+	MockJsonPost(ctx, models.UserUpdate{Username: "batch", Age: 30, Role: models.RoleUser}, "batch@test.pl")
+	ctx.Set(secutiry.ContextSubject, "admin@test.pl")
+	ctx.Set(secutiry.ContextRole, models.RoleAdmin)
+	ctrl := gomock.NewController(t)
+	testDB := dbhandler.NewMockDBHandler(ctrl)
+	expectRevokeAccess(testDB, "batch@test.pl")
+	testMQ := queueHandler.NewMockQueueHandler(ctrl)
+	store := dbhandler.NewMemoryAPIKeyStore()
+	auth := secutiry.NewAuthObj([]byte("test"), secutiry.WithAPIKeyStore(store))
+	_, _, err := auth.IssueAPIKey(ctx, models.APIKey{UserID: "batch@test.pl", Role: models.RoleAdmin, ExpiresAt: time.Now().Add(time.Hour)})
+	assert.NoError(t, err)
+	testDB.EXPECT().GetUser(gomock.Any(), "batch@test.pl").Return(models.User{Email: "batch@test.pl", Role: models.RoleAdmin}, nil)
+	testDB.EXPECT().UpdateUser(gomock.Any(), gomock.Any()).Return(nil)
+	testMQ.EXPECT().Publish(gomock.Any())
+	testObj := NewUserHandler(logger, testDB, testMQ, auth)
+	testObj.UpdateUser(ctx)
+	assert.Equal(t, w.Code, http.StatusOK)
+	keys, err := auth.APIKeys(ctx, "batch@test.pl")
+	assert.NoError(t, err)
+	assert.Empty(t, keys)
+}
+
+func TestDeleteUserRevokesAPIKeys(t *testing.T) {
+	w := httptest.NewRecorder()
+	ctx := GetTestGinContext(w)
+	var logger = logrus.New()
+	MockJsonDelete(ctx, gin.Params{gin.Param{Key: "id", Value: "batch@test.pl"}})
+	ctrl := gomock.NewController(t)
+	testDB := dbhandler.NewMockDBHandler(ctrl)
+	expectRevokeAccess(testDB, "batch@test.pl")
+	testMQ := queueHandler.NewMockQueueHandler(ctrl)
+	store := dbhandler.NewMemoryAPIKeyStore()
+	auth := secutiry.NewAuthObj([]byte("test"), secutiry.WithAPIKeyStore(store))
+	_, _, err := auth.IssueAPIKey(ctx, models.APIKey{UserID: "batch@test.pl", Role: models.RoleUser, ExpiresAt: time.Now().Add(time.Hour)})
+	assert.NoError(t, err)
+	testDB.EXPECT().DeleteUser(gomock.Any(), "batch@test.pl", dbhandler.AnyVersion).Return(nil)
+	testMQ.EXPECT().Publish(gomock.Any())
+	testObj := NewUserHandler(logger, testDB, testMQ, auth)
+	testObj.DeleteUser(ctx)
+	assert.Equal(t, w.Code, http.StatusOK)
+	keys, err := auth.APIKeys(ctx, "batch@test.pl")
+	assert.NoError(t, err)
+	assert.Empty(t, keys)
+}
+
+func TestCreateServiceAccount(t *testing.T) {
+	w := httptest.NewRecorder()
+	ctx := GetTestGinContext(w)
+	var logger = logrus.New()
+	MockJsonPost(ctx, models.ServiceAccountRequest{Name: "batch-import"}, "")
+	ctrl := gomock.NewController(t)
+	testDB := dbhandler.NewMockDBHandler(ctrl)
+	testMQ := queueHandler.NewMockQueueHandler(ctrl)
+	auth := secutiry.NewAuthObj([]byte("test"))
+	testDB.EXPECT().GetUserIncludingDeleted(gomock.Any(), "svc:batch-import").Return(models.User{}, dbhandler.ErrNotFound)
+	testDB.EXPECT().CreateUser(gomock.Any(), models.User{
+		Email:    "svc:batch-import",
+		Username: "batch-import",
+		Role:     models.RoleUser,
+		Status:   models.UserStatusActive,
+		Kind:     models.UserKindService,
+	}).Return(nil)
+	testMQ.EXPECT().Publish(models.Event{EventType: "UserCreated", UserID: "svc:batch-import"})
+	testObj := NewUserHandler(logger, testDB, testMQ, auth)
+	testObj.CreateServiceAccount(ctx)
+	assert.Equal(t, w.Code, http.StatusCreated)
+}
+
+func TestCreateServiceAccountInvalidName(t *testing.T) {
+	w := httptest.NewRecorder()
+	ctx := GetTestGinContext(w)
+	var logger = logrus.New()
+	MockJsonPost(ctx, models.ServiceAccountRequest{Name: "Batch Import"}, "")
+	ctrl := gomock.NewController(t)
+	testDB := dbhandler.NewMockDBHandler(ctrl)
+	testMQ := queueHandler.NewMockQueueHandler(ctrl)
+	auth := secutiry.NewAuthObj([]byte("test"))
+	testObj := NewUserHandler(logger, testDB, testMQ, auth)
+	testObj.CreateServiceAccount(ctx)
+	assert.Equal(t, w.Code, http.StatusBadRequest)
+}
+
+func TestServiceAccountCannotLogin(t *testing.T) {
+	w := httptest.NewRecorder()
+	ctx := GetTestGinContext(w)
+	var logger = logrus.New()
+	MockJsonPost(ctx, models.LoginRequest{Username: "svc:batch-import", Password: "anything"}, "")
+	ctrl := gomock.NewController(t)
+	testDB := dbhandler.NewMockDBHandler(ctrl)
+	testMQ := queueHandler.NewMockQueueHandler(ctrl)
+	auth := secutiry.NewAuthObj([]byte("test"))
+	testDB.EXPECT().GetUser(gomock.Any(), "svc:batch-import").Return(models.User{
+		Email:  "svc:batch-import",
+		Status: models.UserStatusActive,
+		Kind:   models.UserKindService,
+	}, nil)
+	testObj := NewUserHandler(logger, testDB, testMQ, auth)
+	testObj.Login(ctx)
+	assert.Equal(t, w.Code, http.StatusUnauthorized)
+}
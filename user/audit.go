@@ -0,0 +1,324 @@
+package user
+
+import (
+	"UserStorage/dbhandler"
+	"UserStorage/models"
+	"UserStorage/secutiry"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"reflect"
+	"slices"
+	"strconv"
+	"time"
+)
+
+const (
+	headerRequestID  = "X-Request-ID"
+	contextRequestID = "requestID"
+)
+
+var auditValueFields = map[string]bool{
+	"role":      true,
+	"status":    true,
+	"kind":      true,
+	"totp":      true,
+	"lockout":   true,
+	"deletedAt": true,
+}
+
+func requestID(c *gin.Context) string {
+	if id := c.GetString(contextRequestID); id != "" {
+		return id
+	}
+	id := c.GetHeader(headerRequestID)
+	if id == "" {
+		id, _ = secutiry.NewOpaqueToken()
+		c.Header(headerRequestID, id)
+	}
+	c.Set(contextRequestID, id)
+	return id
+}
+
+func (uh *UserHandler) snapshot(c *gin.Context, id string) *models.User {
+	if uh.auditLog == nil {
+		return nil
+	}
+	usr, err := uh.dbHan.GetUserIncludingDeleted(c.Request.Context(), id)
+	if err != nil {
+		return nil
+	}
+	return &usr
+}
+
+func (uh *UserHandler) audit(c *gin.Context, action string, target string, before *models.User, after *models.User) {
+	if uh.auditLog == nil {
+		return
+	}
+	uh.appendAudit(c.Request.Context(), models.AuditEntry{
+		Action:    action,
+		Actor:     secutiry.Subject(c),
+		Target:    target,
+		IP:        c.ClientIP(),
+		RequestID: requestID(c),
+	}, before, after)
+}
+
+func (uh *UserHandler) appendAudit(ctx context.Context, entry models.AuditEntry, before *models.User, after *models.User) {
+	if uh.auditLog == nil {
+		return
+	}
+	actor, err := uh.auditSubject(ctx, entry.Actor)
+	if err == nil {
+		entry.Target, err = uh.auditSubject(ctx, entry.Target)
+	}
+	if err != nil {
+		uh.logger.Error(err)
+		return
+	}
+	entry.Actor = actor
+	entry.Timestamp = time.Now()
+	entry.Changes = userDiff(before, after)
+	if err = uh.sealChanges(ctx, entry.Target, entry.Changes); err != nil {
+		uh.logger.Error(err)
+		return
+	}
+	if _, err = uh.auditLog.AppendAudit(ctx, entry); err != nil {
+		uh.logger.Error(err)
+	}
+}
+
+func (uh *UserHandler) sealChanges(ctx context.Context, subject string, changes []models.FieldChange) error {
+	var key []byte
+	for i, change := range changes {
+		if !change.Redacted {
+			continue
+		}
+		if key == nil && subject != "" {
+			var err error
+			if key, err = uh.auditLog.AuditKey(ctx, subject); err != nil {
+				return err
+			}
+		}
+		if key != nil {
+			sealed, err := sealAuditValues(key, change)
+			if err != nil {
+				return err
+			}
+			changes[i].Sealed = sealed
+		}
+		changes[i].Before, changes[i].After = nil, nil
+	}
+	return nil
+}
+
+func (uh *UserHandler) openAudit(ctx context.Context, entries []models.AuditEntry) error {
+	keys := map[string][]byte{}
+	for _, entry := range entries {
+		for i, change := range entry.Changes {
+			if change.Sealed == "" {
+				continue
+			}
+			key, ok := keys[entry.Target]
+			if !ok {
+				var err error
+				key, err = uh.auditLog.AuditKey(ctx, entry.Target)
+				if err != nil && !errors.Is(err, dbhandler.ErrNotFound) {
+					return err
+				}
+				keys[entry.Target] = key
+			}
+			if key == nil {
+				continue
+			}
+			opened, err := openAuditValues(key, change)
+			if err != nil {
+				return err
+			}
+			entry.Changes[i] = opened
+		}
+	}
+	return nil
+}
+
+func auditCipher(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+func sealAuditValues(key []byte, change models.FieldChange) (string, error) {
+	aead, err := auditCipher(key)
+	if err != nil {
+		return "", err
+	}
+	plain, err := json.Marshal([]any{change.Before, change.After})
+	if err != nil {
+		return "", err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err = rand.Read(nonce); err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(aead.Seal(nonce, nonce, plain, []byte(change.Field))), nil
+}
+
+func openAuditValues(key []byte, change models.FieldChange) (models.FieldChange, error) {
+	aead, err := auditCipher(key)
+	if err != nil {
+		return change, err
+	}
+	raw, err := base64.StdEncoding.DecodeString(change.Sealed)
+	if err != nil || len(raw) < aead.NonceSize() {
+		return change, errors.New("malformed sealed audit values")
+	}
+	plain, err := aead.Open(nil, raw[:aead.NonceSize()], raw[aead.NonceSize():], []byte(change.Field))
+	if err != nil {
+		return change, err
+	}
+	var values []any
+	if err = json.Unmarshal(plain, &values); err != nil || len(values) != 2 {
+		return change, errors.New("malformed sealed audit values")
+	}
+	return models.FieldChange{Field: change.Field, Before: values[0], After: values[1]}, nil
+}
+
+func (uh *UserHandler) auditSubject(ctx context.Context, id string) (string, error) {
+	if id == "" {
+		return "", nil
+	}
+	return uh.auditLog.AuditSubject(ctx, id, true)
+}
+
+func (uh *UserHandler) auditQuerySubject(ctx context.Context, id string) (string, error) {
+	if id == "" {
+		return "", nil
+	}
+	subject, err := uh.auditLog.AuditSubject(ctx, id, false)
+	if errors.Is(err, dbhandler.ErrNotFound) {
+		return id, nil
+	}
+	return subject, err
+}
+
+func userDiff(before *models.User, after *models.User) []models.FieldChange {
+	old, updated := auditFields(before), auditFields(after)
+	var fields []string
+	for field := range old {
+		fields = append(fields, field)
+	}
+	for field := range updated {
+		if _, ok := old[field]; !ok {
+			fields = append(fields, field)
+		}
+	}
+	slices.Sort(fields)
+	changes := []models.FieldChange{}
+	for _, field := range fields {
+		if field == "version" || reflect.DeepEqual(old[field], updated[field]) {
+			continue
+		}
+		changes = append(changes, models.FieldChange{Field: field, Before: old[field], After: updated[field], Redacted: !auditValueFields[field]})
+	}
+	return changes
+}
+
+func auditFields(usr *models.User) map[string]any {
+	fields := map[string]any{}
+	if usr == nil {
+		return fields
+	}
+	raw, err := json.Marshal(usr)
+	if err != nil {
+		return fields
+	}
+	_ = json.Unmarshal(raw, &fields)
+	return fields
+}
+
+func (uh *UserHandler) GetAuditLog(c *gin.Context) {
+	if uh.auditLog == nil {
+		c.JSON(http.StatusNotImplemented, gin.H{"error": "audit log is not configured"})
+		return
+	}
+	query, err := parseAuditQuery(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if query.Actor, err = uh.auditQuerySubject(c.Request.Context(), query.Actor); err == nil {
+		query.Target, err = uh.auditQuerySubject(c.Request.Context(), query.Target)
+	}
+	if err != nil {
+		uh.logger.Error(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	entries, err := uh.auditLog.QueryAudit(c.Request.Context(), query)
+	if err == nil {
+		err = uh.openAudit(c.Request.Context(), entries)
+	}
+	if err != nil {
+		uh.logger.Error(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if len(entries) == query.Limit {
+		next := strconv.FormatInt(entries[len(entries)-1].Seq, 10)
+		c.Header("X-Next-Cursor", next)
+	}
+	c.JSON(http.StatusOK, entries)
+}
+
+func (uh *UserHandler) VerifyAuditLog(c *gin.Context) {
+	if uh.auditLog == nil {
+		c.JSON(http.StatusNotImplemented, gin.H{"error": "audit log is not configured"})
+		return
+	}
+	result, err := dbhandler.VerifyAudit(c.Request.Context(), uh.auditLog)
+	if err != nil {
+		uh.logger.Error(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, result)
+}
+
+func parseAuditQuery(c *gin.Context) (models.AuditQuery, error) {
+	query := models.AuditQuery{
+		Actor:  c.Query("actor"),
+		Target: c.Query("target"),
+		Action: c.Query("action"),
+		Limit:  models.DefaultAuditPageSize,
+	}
+	if v := c.Query("limit"); v != "" {
+		limit, err := strconv.Atoi(v)
+		if err != nil || limit < 1 || limit > models.MaxAuditPageSize {
+			return models.AuditQuery{}, errors.New("limit must be between 1 and " + strconv.Itoa(models.MaxAuditPageSize))
+		}
+		query.Limit = limit
+	}
+	if v := c.Query("cursor"); v != "" {
+		after, err := strconv.ParseInt(v, 10, 64)
+		if err != nil || after < 0 {
+			return models.AuditQuery{}, errors.New("cursor must be a sequence number")
+		}
+		query.AfterSeq = after
+	}
+	var err error
+	if query.From, err = optionalTime(c, "from"); err != nil {
+		return models.AuditQuery{}, err
+	}
+	if query.To, err = optionalTime(c, "to"); err != nil {
+		return models.AuditQuery{}, err
+	}
+	return query, nil
+}
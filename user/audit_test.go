@@ -0,0 +1,182 @@
+package user
+
+import (
+	"UserStorage/dbhandler"
+	"UserStorage/models"
+	"UserStorage/queueHandler"
+	"UserStorage/secutiry"
+	"context"
+	"encoding/json"
+	"github.com/gin-gonic/gin"
+	"github.com/golang/mock/gomock"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestUserDiff(t *testing.T) {
+	before := models.User{Email: "test@test.pl", Username: "old", Age: 30, Role: models.RoleUser, Version: 1}
+	after := models.User{Email: "test@test.pl", Username: "new", Age: 30, Role: models.RoleAdmin, Version: 2, Files: []models.File{{Name: "a"}}}
+	changes := userDiff(&before, &after)
+	assert.Len(t, changes, 3)
+	assert.Equal(t, changes[0].Field, "files")
+	assert.True(t, changes[0].Redacted)
+	assert.Equal(t, changes[1], models.FieldChange{Field: "role", Before: models.RoleUser, After: models.RoleAdmin})
+	assert.Equal(t, changes[2], models.FieldChange{Field: "username", Before: "old", After: "new", Redacted: true})
+	assert.Len(t, userDiff(nil, &after), 8)
+}
+
+func TestSealAuditValues(t *testing.T) {
+	key := make([]byte, 32)
+	change := models.FieldChange{Field: "username", Before: "old", After: "new", Redacted: true}
+	sealed, err := sealAuditValues(key, change)
+	assert.NoError(t, err)
+	assert.NotContains(t, sealed, "new")
+	opened, err := openAuditValues(key, models.FieldChange{Field: "username", Redacted: true, Sealed: sealed})
+	assert.NoError(t, err)
+	assert.Equal(t, opened, models.FieldChange{Field: "username", Before: "old", After: "new"})
+	_, err = openAuditValues(key, models.FieldChange{Field: "email", Redacted: true, Sealed: sealed})
+	assert.Error(t, err)
+	_, err = openAuditValues(make([]byte, 16), models.FieldChange{Field: "username", Redacted: true, Sealed: sealed})
+	assert.Error(t, err)
+}
+
+func TestUpdUserAudited(t *testing.T) {
+	w := httptest.NewRecorder()
+	ctx := GetTestGinContext(w)
+	MockJsonPost(ctx, models.UserUpdate{Username: "new", Age: 21}, "test@test.pl")
+	ctx.Request.Header.Set("X-Request-ID", "req-1")
+	ctx.Request.RemoteAddr = "10.0.0.1:1234"
+	ctx.Set(secutiry.ContextSubject, "admin@test.pl")
+	ctx.Set(secutiry.ContextRole, models.RoleAdmin)
+	ctrl := gomock.NewController(t)
+	testDB := dbhandler.NewMockDBHandler(ctrl)
+	testMQ := queueHandler.NewMockQueueHandler(ctrl)
+	testDB.EXPECT().GetUser(gomock.Any(), "test@test.pl").Return(models.User{Email: "test@test.pl", Username: "old", Age: 21, Role: models.RoleUser}, nil)
+	testDB.EXPECT().UpdateUser(gomock.Any(), gomock.Any()).Return(nil)
+	testMQ.EXPECT().Publish(gomock.Any())
+	store := dbhandler.NewMemoryAuditStore()
+	testObj := NewUserHandler(logrus.New(), testDB, testMQ, secutiry.NewAuthObj([]byte("test")), WithAuditLog(store))
+	testObj.UpdateUser(ctx)
+	assert.Equal(t, w.Code, http.StatusOK)
+	entries, err := store.QueryAudit(context.Background(), models.AuditQuery{})
+	assert.NoError(t, err)
+	assert.Len(t, entries, 1)
+	assert.Equal(t, entries[0].Action, models.AuditUserUpdated)
+	actor, err := store.AuditSubject(context.Background(), "admin@test.pl", false)
+	assert.NoError(t, err)
+	target, err := store.AuditSubject(context.Background(), "test@test.pl", false)
+	assert.NoError(t, err)
+	assert.Equal(t, entries[0].Actor, actor)
+	assert.Equal(t, entries[0].Target, target)
+	assert.Equal(t, entries[0].IP, "10.0.0.1")
+	assert.Equal(t, entries[0].RequestID, "req-1")
+	assert.Len(t, entries[0].Changes, 1)
+	assert.Equal(t, entries[0].Changes[0].Field, "username")
+	assert.Nil(t, entries[0].Changes[0].After)
+	assert.NotEmpty(t, entries[0].Changes[0].Sealed)
+	assert.NoError(t, testObj.openAudit(context.Background(), entries))
+	assert.Equal(t, entries[0].Changes, []models.FieldChange{{Field: "username", Before: "old", After: "new"}})
+}
+
+func TestDeleteUsrAudited(t *testing.T) {
+	w := httptest.NewRecorder()
+	ctx := GetTestGinContext(w)
+	MockJsonDelete(ctx, gin.Params{gin.Param{Key: "id", Value: "test@test.pl"}})
+	ctrl := gomock.NewController(t)
+	testDB := dbhandler.NewMockDBHandler(ctrl)
+	expectRevokeAccess(testDB, "test@test.pl")
+	testMQ := queueHandler.NewMockQueueHandler(ctrl)
+	deletedAt := time.Now()
+	gomock.InOrder(
+		testDB.EXPECT().GetUserIncludingDeleted(gomock.Any(), "test@test.pl").Return(models.User{Email: "test@test.pl"}, nil),
+		testDB.EXPECT().DeleteUser(gomock.Any(), "test@test.pl", dbhandler.AnyVersion).Return(nil),
+		testDB.EXPECT().GetUserIncludingDeleted(gomock.Any(), "test@test.pl").Return(models.User{Email: "test@test.pl", DeletedAt: &deletedAt}, nil),
+	)
+	testMQ.EXPECT().Publish(gomock.Any())
+	store := dbhandler.NewMemoryAuditStore()
+	testObj := NewUserHandler(logrus.New(), testDB, testMQ, secutiry.NewAuthObj([]byte("test")), WithAuditLog(store))
+	testObj.DeleteUser(ctx)
+	assert.Equal(t, w.Code, http.StatusOK)
+	entries, _ := store.QueryAudit(context.Background(), models.AuditQuery{})
+	assert.Len(t, entries, 1)
+	assert.Equal(t, entries[0].Changes[0].Field, "deletedAt")
+	assert.NotEmpty(t, w.Header().Get("X-Request-ID"))
+}
+
+func TestGetAuditLog(t *testing.T) {
+	store := dbhandler.NewMemoryAuditStore()
+	for _, target := range []string{"a@test.pl", "b@test.pl", "a@test.pl", "a@test.pl"} {
+		_, err := store.AppendAudit(context.Background(), models.AuditEntry{Timestamp: time.Now(), Action: models.AuditUserUpdated, Target: target})
+		assert.NoError(t, err)
+	}
+	w := httptest.NewRecorder()
+	ctx := GetTestGinContext(w)
+	MockJsonGet(ctx, gin.Params{}, url.Values{"target": {"a@test.pl"}, "limit": {"2"}, "cursor": {"1"}})
+	ctrl := gomock.NewController(t)
+	testObj := NewUserHandler(logrus.New(), dbhandler.NewMockDBHandler(ctrl), queueHandler.NewMockQueueHandler(ctrl), secutiry.NewAuthObj([]byte("test")), WithAuditLog(store))
+	testObj.GetAuditLog(ctx)
+	assert.Equal(t, w.Code, http.StatusOK)
+	var entries []models.AuditEntry
+	err := json.NewDecoder(w.Body).Decode(&entries)
+	assert.NoError(t, err)
+	assert.Len(t, entries, 2)
+	assert.Equal(t, entries[0].Seq, int64(3))
+	assert.Equal(t, entries[1].Seq, int64(4))
+	assert.Equal(t, w.Header().Get("X-Next-Cursor"), "4")
+}
+
+func TestGetAuditLogByEmail(t *testing.T) {
+	store := dbhandler.NewMemoryAuditStore()
+	subject, err := store.AuditSubject(context.Background(), "a@test.pl", true)
+	assert.NoError(t, err)
+	for _, target := range []string{subject, "b@test.pl"} {
+		_, err = store.AppendAudit(context.Background(), models.AuditEntry{Timestamp: time.Now(), Action: models.AuditUserUpdated, Target: target})
+		assert.NoError(t, err)
+	}
+	w := httptest.NewRecorder()
+	ctx := GetTestGinContext(w)
+	MockJsonGet(ctx, gin.Params{}, url.Values{"target": {"a@test.pl"}})
+	ctrl := gomock.NewController(t)
+	testObj := NewUserHandler(logrus.New(), dbhandler.NewMockDBHandler(ctrl), queueHandler.NewMockQueueHandler(ctrl), secutiry.NewAuthObj([]byte("test")), WithAuditLog(store))
+	testObj.GetAuditLog(ctx)
+	assert.Equal(t, w.Code, http.StatusOK)
+	var entries []models.AuditEntry
+	err = json.NewDecoder(w.Body).Decode(&entries)
+	assert.NoError(t, err)
+	assert.Len(t, entries, 1)
+	assert.Equal(t, entries[0].Target, subject)
+}
+
+func TestGetAuditLogBadQuery(t *testing.T) {
+	w := httptest.NewRecorder()
+	ctx := GetTestGinContext(w)
+	MockJsonGet(ctx, gin.Params{}, url.Values{"from": {"yesterday"}})
+	ctrl := gomock.NewController(t)
+	testObj := NewUserHandler(logrus.New(), dbhandler.NewMockDBHandler(ctrl), queueHandler.NewMockQueueHandler(ctrl), secutiry.NewAuthObj([]byte("test")), WithAuditLog(dbhandler.NewMemoryAuditStore()))
+	testObj.GetAuditLog(ctx)
+	assert.Equal(t, w.Code, http.StatusBadRequest)
+}
+
+func TestVerifyAuditLog(t *testing.T) {
+	store := dbhandler.NewMemoryAuditStore()
+	for range 3 {
+		_, err := store.AppendAudit(context.Background(), models.AuditEntry{Timestamp: time.Now(), Action: models.AuditUserCreated, Target: "a@test.pl"})
+		assert.NoError(t, err)
+	}
+	w := httptest.NewRecorder()
+	ctx := GetTestGinContext(w)
+	MockJsonGet(ctx, gin.Params{}, url.Values{})
+	ctrl := gomock.NewController(t)
+	testObj := NewUserHandler(logrus.New(), dbhandler.NewMockDBHandler(ctrl), queueHandler.NewMockQueueHandler(ctrl), secutiry.NewAuthObj([]byte("test")), WithAuditLog(store))
+	testObj.VerifyAuditLog(ctx)
+	assert.Equal(t, w.Code, http.StatusOK)
+	var result models.AuditVerification
+	err := json.NewDecoder(w.Body).Decode(&result)
+	assert.NoError(t, err)
+	assert.Equal(t, result, models.AuditVerification{Valid: true, Checked: 3})
+}
@@ -0,0 +1,353 @@
+package user
+
+import (
+	"UserStorage/dbhandler"
+	"UserStorage/models"
+	"UserStorage/secutiry"
+	"errors"
+	"github.com/gin-gonic/gin"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+func (uh *UserHandler) Login(c *gin.Context) {
+	var req models.LoginRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	now := time.Now()
+	ip := c.ClientIP()
+	if until, locked := uh.ipAttempts.LockedUntil(ip, now); locked {
+		retryAfter(c, until, now)
+		c.JSON(http.StatusTooManyRequests, gin.H{"error": "too many failed login attempts"})
+		return
+	}
+	user, err := uh.dbHan.GetUser(c.Request.Context(), req.Username)
+	if err == nil && user.Kind == models.UserKindService {
+		err = errors.New("service accounts cannot log in")
+	}
+	if err != nil {
+		uh.loginFailedFromIP(ip, req.Username, now)
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid credentials"})
+		uh.logger.Error(err)
+		return
+	}
+	if user.Lockout.LockedUntil.After(now) {
+		retryAfter(c, user.Lockout.LockedUntil, now)
+		c.JSON(http.StatusLocked, gin.H{"error": "account locked"})
+		return
+	}
+	ok, rehash, err := uh.hasher.Verify(user.Password, req.Password)
+	if err != nil {
+		uh.logger.Error(err)
+	}
+	if !ok {
+		uh.loginFailedFromIP(ip, req.Username, now)
+		uh.loginFailed(c, user, ip, now)
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid credentials"})
+		return
+	}
+	if user.Status == models.UserStatusPending {
+		c.JSON(http.StatusForbidden, gin.H{"error": "account not verified"})
+		return
+	}
+	if rehash {
+		uh.rehashPassword(c, user.Email, req.Password)
+	}
+	if user.Lockout != (models.Lockout{}) {
+		if err = uh.dbHan.SetLockout(c.Request.Context(), user.Email, models.Lockout{}); err != nil {
+			uh.logger.Error(err)
+		}
+	}
+	if user.TOTP.Enabled {
+		uh.mfaChallenge(c, user)
+		return
+	}
+	if mfaEnrollmentRequired(user) {
+		uh.mfaEnrollment(c, user)
+		return
+	}
+	uh.issueTokens(c, user, "")
+}
+
+func (uh *UserHandler) loginFailed(c *gin.Context, user models.User, ip string, now time.Time) {
+	lockout, err := uh.dbHan.RecordFailedLogin(c.Request.Context(), user.Email)
+	if err != nil {
+		uh.logger.Error(err)
+		return
+	}
+	lockout, locked := uh.accountLockout.Lock(lockout, now)
+	if !locked {
+		return
+	}
+	locked, err = uh.dbHan.LockAccount(c.Request.Context(), user.Email, uh.accountLockout.Threshold, lockout)
+	if err != nil {
+		uh.logger.Error(err)
+		return
+	}
+	if locked {
+		uh.logger.Warnf("account %s locked until %s", user.Email, lockout.LockedUntil)
+		uh.rabbit.Publish(models.LockoutEvent{EventType: "LoginLocked", UserID: user.Email, IP: ip, LockedUntil: lockout.LockedUntil})
+	}
+}
+
+func (uh *UserHandler) loginFailedFromIP(ip string, username string, now time.Time) {
+	lockout, locked := uh.ipAttempts.Fail(ip, now)
+	if locked {
+		uh.logger.Warnf("login from %s locked until %s", ip, lockout.LockedUntil)
+		uh.rabbit.Publish(models.LockoutEvent{EventType: "LoginLocked", UserID: username, IP: ip, LockedUntil: lockout.LockedUntil})
+	}
+}
+
+func (uh *UserHandler) rehashPassword(c *gin.Context, id string, password string) {
+	hashed, err := uh.hasher.Hash(password)
+	if err != nil {
+		uh.logger.Error(err)
+		return
+	}
+	if err = uh.dbHan.UpdatePassword(c.Request.Context(), id, hashed); err != nil {
+		uh.logger.Error(err)
+	}
+}
+
+func retryAfter(c *gin.Context, until time.Time, now time.Time) {
+	c.Header("Retry-After", strconv.Itoa(int(math.Ceil(until.Sub(now).Seconds()))))
+}
+
+func (uh *UserHandler) Signup(c *gin.Context) {
+	input, ok := uh.createUser(c, true)
+	if !ok {
+		return
+	}
+	if err := uh.sendVerification(c, input.Email); err != nil {
+		uh.logger.Error(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusCreated, input)
+}
+
+func (uh *UserHandler) ResendVerification(c *gin.Context) {
+	var req models.ResendVerificationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	accepted := gin.H{"message": "if the account is awaiting verification a new token has been sent"}
+	user, err := uh.dbHan.GetUser(c.Request.Context(), req.Email)
+	if err != nil || user.Status != models.UserStatusPending {
+		if err != nil {
+			uh.logger.Error(err)
+		}
+		c.JSON(http.StatusAccepted, accepted)
+		return
+	}
+	err = uh.dbHan.DeleteTokens(c.Request.Context(), user.Email, models.TokenVerification)
+	if err == nil {
+		err = uh.sendVerification(c, user.Email)
+	}
+	if err != nil {
+		uh.logger.Error(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusAccepted, accepted)
+}
+
+func (uh *UserHandler) sendVerification(c *gin.Context, email string) error {
+	token, expiresAt, err := uh.saveToken(c.Request.Context(), models.TokenVerification, email, secutiry.VerificationTokenTTL)
+	if err != nil {
+		return err
+	}
+	uh.rabbit.Publish(models.TokenEvent{EventType: "UserVerificationRequested", UserID: email, Token: token, ExpiresAt: expiresAt})
+	return nil
+}
+
+func (uh *UserHandler) Confirm(c *gin.Context) {
+	var req models.ConfirmRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	token, err := uh.consumeToken(c.Request.Context(), req.Token, models.TokenVerification)
+	if errors.Is(err, dbhandler.ErrNotFound) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid verification token"})
+		return
+	}
+	if err != nil {
+		uh.logger.Error(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	user, err := uh.dbHan.GetUser(c.Request.Context(), token.UserID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		uh.logger.Error(err)
+		return
+	}
+	before := user
+	user.Status = models.UserStatusActive
+	err = uh.dbHan.UpdateUser(c.Request.Context(), user)
+	if err != nil {
+		uh.logger.Error(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	uh.audit(c, models.AuditUserUpdated, user.Email, &before, &user)
+	uh.rabbit.Publish(models.Event{EventType: "UserVerified", UserID: user.Email, Age: user.Age, NoFiles: len(user.Files)})
+	c.JSON(http.StatusOK, gin.H{"message": "account verified"})
+}
+
+func (uh *UserHandler) Refresh(c *gin.Context) {
+	var req models.RefreshRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	token, err := uh.consumeToken(c.Request.Context(), req.RefreshToken, models.TokenRefresh)
+	if errors.Is(err, dbhandler.ErrNotFound) {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid refresh token"})
+		return
+	}
+	if err != nil {
+		uh.logger.Error(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	user, err := uh.dbHan.GetUser(c.Request.Context(), token.UserID)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid refresh token"})
+		uh.logger.Error(err)
+		return
+	}
+	if mfaEnrollmentRequired(user) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "2fa enrollment required"})
+		return
+	}
+	uh.issueTokens(c, user, token.Data["sid"])
+}
+
+func (uh *UserHandler) Logout(c *gin.Context) {
+	var req models.RefreshRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	token, err := uh.dbHan.ConsumeToken(c.Request.Context(), secutiry.HashToken(req.RefreshToken), models.TokenRefresh)
+	if err != nil && !errors.Is(err, dbhandler.ErrNotFound) {
+		uh.logger.Error(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if sid := token.Data["sid"]; sid != "" {
+		if _, err = uh.auth.EndSession(c.Request.Context(), token.UserID, sid); err != nil && !errors.Is(err, dbhandler.ErrNotFound) {
+			uh.logger.Error(err)
+		}
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "logged out"})
+}
+
+func (uh *UserHandler) issueTokens(c *gin.Context, user models.User, sid string) {
+	tokens, ok := uh.newTokens(c, user, sid)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, tokens)
+}
+
+func (uh *UserHandler) newTokens(c *gin.Context, user models.User, sid string) (gin.H, bool) {
+	role := user.Role
+	if role == "" {
+		role = models.RoleUser
+	}
+	refresh, err := secutiry.NewOpaqueToken()
+	if err != nil {
+		uh.logger.Error(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return nil, false
+	}
+	refreshID := secutiry.HashToken(refresh)
+	expiresAt := time.Now().Add(secutiry.RefreshTokenTTL)
+	if sid == "" {
+		session, err := uh.auth.StartSession(c.Request.Context(), models.Session{
+			UserID:         user.Email,
+			IP:             c.ClientIP(),
+			UserAgent:      c.Request.UserAgent(),
+			RefreshTokenID: refreshID,
+			ExpiresAt:      expiresAt,
+		})
+		if err != nil {
+			uh.logger.Error(err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return nil, false
+		}
+		sid = session.ID
+	} else {
+		err = uh.auth.RotateSession(c.Request.Context(), sid, refreshID, expiresAt)
+		if errors.Is(err, dbhandler.ErrNotFound) {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "session terminated"})
+			return nil, false
+		}
+		if err != nil {
+			uh.logger.Error(err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return nil, false
+		}
+	}
+	token, err := uh.auth.CreateSessionToken(sid, user.Email, role)
+	if err != nil {
+		uh.logger.Error(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return nil, false
+	}
+	var data map[string]string
+	if sid != "" {
+		data = map[string]string{"sid": sid}
+	}
+	err = uh.dbHan.SaveToken(c.Request.Context(), models.Token{
+		ID:        refreshID,
+		Kind:      models.TokenRefresh,
+		UserID:    user.Email,
+		ExpiresAt: expiresAt,
+		Data:      data,
+	})
+	if err != nil {
+		uh.logger.Error(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return nil, false
+	}
+	return gin.H{
+		"token":        token,
+		"refreshToken": refresh,
+		"expiresIn":    int(secutiry.AccessTokenTTL.Seconds()),
+	}, true
+}
+
+func (uh *UserHandler) Downscope(c *gin.Context) {
+	var req models.DownscopeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if !secutiry.ValidScopes(req.Scopes) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid scopes"})
+		return
+	}
+	token, expiresAt, err := uh.auth.Downscope(c, req.Scopes)
+	if errors.Is(err, secutiry.ErrScopeNotGranted) {
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		uh.logger.Error(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"token":     token,
+		"expiresIn": int(time.Until(expiresAt).Seconds()),
+	})
+}
@@ -0,0 +1,481 @@
+package user
+
+import (
+	"UserStorage/dbhandler"
+	"UserStorage/models"
+	"UserStorage/queueHandler"
+	"UserStorage/secutiry"
+	"encoding/json"
+	"github.com/gin-gonic/gin"
+	"github.com/golang/mock/gomock"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/bcrypt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+type msgTokens struct {
+	Token        string `json:"token"`
+	RefreshToken string `json:"refreshToken"`
+}
+
+func TestLogin(t *testing.T) {
+	w := httptest.NewRecorder()
+	ctx := GetTestGinContext(w)
+	var logger = logrus.New()
+	MockJsonPost(ctx, models.LoginRequest{Username: "test@test.pl", Password: "test"}, "")
+	ctrl := gomock.NewController(t)
+	testDB := dbhandler.NewMockDBHandler(ctrl)
+	testMQ := queueHandler.NewMockQueueHandler(ctrl)
+	auth := secutiry.NewAuthObj([]byte("test"))
+	hash, err := bcrypt.GenerateFromPassword([]byte("test"), bcrypt.DefaultCost)
+	assert.NoError(t, err)
+	testDB.EXPECT().GetUser(gomock.Any(), "test@test.pl").Return(models.User{Email: "test@test.pl", Password: string(hash)}, nil)
+	testDB.EXPECT().SaveToken(gomock.Any(), gomock.Any()).DoAndReturn(func(_ any, token models.Token) error {
+		assert.Equal(t, token.Kind, models.TokenRefresh)
+		assert.Equal(t, token.UserID, "test@test.pl")
+		return nil
+	})
+	testObj := NewUserHandler(logger, testDB, testMQ, auth)
+	testObj.Login(ctx)
+	assert.Equal(t, w.Code, http.StatusOK)
+	var out msgTokens
+	err = json.NewDecoder(w.Body).Decode(&out)
+	assert.NoError(t, err)
+	claims, err := auth.ValidateToken(out.Token)
+	assert.NoError(t, err)
+	assert.Equal(t, claims.Subject, "test@test.pl")
+	assert.Equal(t, claims.Role, models.RoleUser)
+	assert.NotEmpty(t, out.RefreshToken)
+}
+
+func TestLoginWrongPassword(t *testing.T) {
+	w := httptest.NewRecorder()
+	ctx := GetTestGinContext(w)
+	var logger = logrus.New()
+	MockJsonPost(ctx, models.LoginRequest{Username: "test@test.pl", Password: "wrong"}, "")
+	ctrl := gomock.NewController(t)
+	testDB := dbhandler.NewMockDBHandler(ctrl)
+	testMQ := queueHandler.NewMockQueueHandler(ctrl)
+	auth := secutiry.NewAuthObj([]byte("test"))
+	hash, err := bcrypt.GenerateFromPassword([]byte("test"), bcrypt.MinCost)
+	assert.NoError(t, err)
+	testDB.EXPECT().GetUser(gomock.Any(), gomock.Any()).Return(models.User{Email: "test@test.pl", Password: string(hash)}, nil)
+	testDB.EXPECT().RecordFailedLogin(gomock.Any(), "test@test.pl").Return(models.Lockout{FailedAttempts: 1}, nil)
+	testObj := NewUserHandler(logger, testDB, testMQ, auth)
+	testObj.Login(ctx)
+	assert.Equal(t, w.Code, http.StatusUnauthorized)
+	msgErr := msgErr{}
+	err = json.NewDecoder(w.Body).Decode(&msgErr)
+	assert.NoError(t, err)
+	assert.Equal(t, msgErr.Err, "invalid credentials")
+}
+
+func TestRefresh(t *testing.T) {
+	w := httptest.NewRecorder()
+	ctx := GetTestGinContext(w)
+	var logger = logrus.New()
+	MockJsonPost(ctx, models.RefreshRequest{RefreshToken: "refresh"}, "")
+	ctrl := gomock.NewController(t)
+	testDB := dbhandler.NewMockDBHandler(ctrl)
+	testMQ := queueHandler.NewMockQueueHandler(ctrl)
+	auth := secutiry.NewAuthObj([]byte("test"))
+	testDB.EXPECT().ConsumeToken(gomock.Any(), secutiry.HashToken("refresh"), models.TokenRefresh).Return(models.Token{
+		ID:        secutiry.HashToken("refresh"),
+		Kind:      models.TokenRefresh,
+		UserID:    "test@test.pl",
+		ExpiresAt: time.Now().Add(time.Hour),
+	}, nil)
+	testDB.EXPECT().GetUser(gomock.Any(), "test@test.pl").Return(models.User{Email: "test@test.pl"}, nil)
+	testDB.EXPECT().SaveToken(gomock.Any(), gomock.Any()).Return(nil)
+	testObj := NewUserHandler(logger, testDB, testMQ, auth)
+	testObj.Refresh(ctx)
+	assert.Equal(t, w.Code, http.StatusOK)
+	var out msgTokens
+	err := json.NewDecoder(w.Body).Decode(&out)
+	assert.NoError(t, err)
+	assert.NotEqual(t, out.RefreshToken, "refresh")
+}
+
+func TestRefreshExpired(t *testing.T) {
+	w := httptest.NewRecorder()
+	ctx := GetTestGinContext(w)
+	var logger = logrus.New()
+	MockJsonPost(ctx, models.RefreshRequest{RefreshToken: "refresh"}, "")
+	ctrl := gomock.NewController(t)
+	testDB := dbhandler.NewMockDBHandler(ctrl)
+	testMQ := queueHandler.NewMockQueueHandler(ctrl)
+	auth := secutiry.NewAuthObj([]byte("test"))
+	testDB.EXPECT().ConsumeToken(gomock.Any(), gomock.Any(), gomock.Any()).Return(models.Token{
+		UserID:    "test@test.pl",
+		ExpiresAt: time.Now().Add(-time.Minute),
+	}, nil)
+	testObj := NewUserHandler(logger, testDB, testMQ, auth)
+	testObj.Refresh(ctx)
+	assert.Equal(t, w.Code, http.StatusUnauthorized)
+}
+
+func TestRefreshUnknown(t *testing.T) {
+	w := httptest.NewRecorder()
+	ctx := GetTestGinContext(w)
+	var logger = logrus.New()
+	MockJsonPost(ctx, models.RefreshRequest{RefreshToken: "refresh"}, "")
+	ctrl := gomock.NewController(t)
+	testDB := dbhandler.NewMockDBHandler(ctrl)
+	testMQ := queueHandler.NewMockQueueHandler(ctrl)
+	auth := secutiry.NewAuthObj([]byte("test"))
+	testDB.EXPECT().ConsumeToken(gomock.Any(), gomock.Any(), gomock.Any()).Return(models.Token{}, dbhandler.ErrNotFound)
+	testObj := NewUserHandler(logger, testDB, testMQ, auth)
+	testObj.Refresh(ctx)
+	assert.Equal(t, w.Code, http.StatusUnauthorized)
+	msgErr := msgErr{}
+	err := json.NewDecoder(w.Body).Decode(&msgErr)
+	assert.NoError(t, err)
+	assert.Equal(t, msgErr.Err, "invalid refresh token")
+}
+
+func TestLogout(t *testing.T) {
+	w := httptest.NewRecorder()
+	ctx := GetTestGinContext(w)
+	var logger = logrus.New()
+	MockJsonPost(ctx, models.RefreshRequest{RefreshToken: "refresh"}, "")
+	ctrl := gomock.NewController(t)
+	testDB := dbhandler.NewMockDBHandler(ctrl)
+	testMQ := queueHandler.NewMockQueueHandler(ctrl)
+	auth := secutiry.NewAuthObj([]byte("test"))
+	testDB.EXPECT().ConsumeToken(gomock.Any(), secutiry.HashToken("refresh"), models.TokenRefresh).Return(models.Token{}, dbhandler.ErrNotFound)
+	testObj := NewUserHandler(logger, testDB, testMQ, auth)
+	testObj.Logout(ctx)
+	assert.Equal(t, w.Code, http.StatusOK)
+	msgOut := msgInf{}
+	err := json.NewDecoder(w.Body).Decode(&msgOut)
+	assert.NoError(t, err)
+	assert.Equal(t, msgOut.Message, "logged out")
+}
+
+func TestLoginUnverified(t *testing.T) {
+	w := httptest.NewRecorder()
+	ctx := GetTestGinContext(w)
+	var logger = logrus.New()
+	MockJsonPost(ctx, models.LoginRequest{Username: "test@test.pl", Password: "test"}, "")
+	ctrl := gomock.NewController(t)
+	testDB := dbhandler.NewMockDBHandler(ctrl)
+	testMQ := queueHandler.NewMockQueueHandler(ctrl)
+	auth := secutiry.NewAuthObj([]byte("test"))
+	hash, err := bcrypt.GenerateFromPassword([]byte("test"), bcrypt.MinCost)
+	assert.NoError(t, err)
+	testDB.EXPECT().GetUser(gomock.Any(), gomock.Any()).Return(models.User{Email: "test@test.pl", Password: string(hash), Status: models.UserStatusPending}, nil)
+	testObj := NewUserHandler(logger, testDB, testMQ, auth)
+	testObj.Login(ctx)
+	assert.Equal(t, w.Code, http.StatusForbidden)
+	msgErr := msgErr{}
+	err = json.NewDecoder(w.Body).Decode(&msgErr)
+	assert.NoError(t, err)
+	assert.Equal(t, msgErr.Err, "account not verified")
+}
+
+func TestSignup(t *testing.T) {
+	w := httptest.NewRecorder()
+	ctx := GetTestGinContext(w)
+	var logger = logrus.New()
+	testUser := gin.H{
+		"email":    "test@test.pl",
+		"username": "test",
+		"age":      21,
+		"status":   models.UserStatusActive,
+		"password": "correct horse",
+	}
+	MockJsonPost(ctx, testUser, "")
+	ctrl := gomock.NewController(t)
+	testDB := dbhandler.NewMockDBHandler(ctrl)
+	testMQ := queueHandler.NewMockQueueHandler(ctrl)
+	auth := secutiry.NewAuthObj([]byte("test"))
+	testDB.EXPECT().CreateUser(gomock.Any(), gomock.Any()).DoAndReturn(func(_ any, usr models.User) error {
+		assert.Equal(t, usr.Status, models.UserStatusPending)
+		assert.NoError(t, bcrypt.CompareHashAndPassword([]byte(usr.Password), []byte("correct horse")))
+		return nil
+	})
+	testDB.EXPECT().SaveToken(gomock.Any(), gomock.Any()).DoAndReturn(func(_ any, token models.Token) error {
+		assert.Equal(t, token.Kind, models.TokenVerification)
+		return nil
+	})
+	testMQ.EXPECT().Publish(gomock.Any())
+	testMQ.EXPECT().Publish(gomock.AssignableToTypeOf(models.TokenEvent{})).Do(func(ev any) {
+		event := ev.(models.TokenEvent)
+		assert.Equal(t, event.EventType, "UserVerificationRequested")
+		assert.NotEmpty(t, event.Token)
+	})
+	testObj := NewUserHandler(logger, testDB, testMQ, auth)
+	testObj.Signup(ctx)
+	assert.Equal(t, w.Code, http.StatusCreated)
+}
+
+func TestSignupIgnoresIdentities(t *testing.T) {
+	w := httptest.NewRecorder()
+	ctx := GetTestGinContext(w)
+	var logger = logrus.New()
+	MockJsonPost(ctx, gin.H{
+		"email":      "test@test.pl",
+		"username":   "test",
+		"age":        21,
+		"password":   "correct horse",
+		"identities": []models.Identity{{Issuer: "https://idp.test", Subject: "victim"}},
+	}, "")
+	ctrl := gomock.NewController(t)
+	testDB := dbhandler.NewMockDBHandler(ctrl)
+	testMQ := queueHandler.NewMockQueueHandler(ctrl)
+	auth := secutiry.NewAuthObj([]byte("test"))
+	testDB.EXPECT().CreateUser(gomock.Any(), gomock.Any()).DoAndReturn(func(_ any, usr models.User) error {
+		assert.Empty(t, usr.Identities)
+		return nil
+	})
+	testDB.EXPECT().SaveToken(gomock.Any(), gomock.Any()).Return(nil)
+	testMQ.EXPECT().Publish(gomock.Any()).Times(2)
+	testObj := NewUserHandler(logger, testDB, testMQ, auth)
+	testObj.Signup(ctx)
+	assert.Equal(t, w.Code, http.StatusCreated)
+	var out map[string]any
+	err := json.NewDecoder(w.Body).Decode(&out)
+	assert.NoError(t, err)
+	assert.NotContains(t, out, "identities")
+}
+
+func TestSignupDuplicate(t *testing.T) {
+	w := httptest.NewRecorder()
+	ctx := GetTestGinContext(w)
+	MockJsonPost(ctx, gin.H{"email": "test@test.pl", "username": "test", "age": 21, "password": "correct horse"}, "")
+	ctrl := gomock.NewController(t)
+	testDB := dbhandler.NewMockDBHandler(ctrl)
+	testDB.EXPECT().CreateUser(gomock.Any(), gomock.Any()).Return(dbhandler.ErrDuplicate)
+	testObj := NewUserHandler(logrus.New(), testDB, queueHandler.NewMockQueueHandler(ctrl), secutiry.NewAuthObj([]byte("test")))
+	testObj.Signup(ctx)
+	assert.Equal(t, w.Code, http.StatusConflict)
+	assert.NotContains(t, w.Body.String(), "E11000")
+}
+
+func TestResendVerification(t *testing.T) {
+	w := httptest.NewRecorder()
+	ctx := GetTestGinContext(w)
+	MockJsonPost(ctx, models.ResendVerificationRequest{Email: "test@test.pl"}, "")
+	ctrl := gomock.NewController(t)
+	testDB := dbhandler.NewMockDBHandler(ctrl)
+	testMQ := queueHandler.NewMockQueueHandler(ctrl)
+	testDB.EXPECT().GetUser(gomock.Any(), "test@test.pl").Return(models.User{Email: "test@test.pl", Status: models.UserStatusPending}, nil)
+	testDB.EXPECT().DeleteTokens(gomock.Any(), "test@test.pl", models.TokenVerification).Return(nil)
+	testDB.EXPECT().SaveToken(gomock.Any(), gomock.Any()).DoAndReturn(func(_ any, token models.Token) error {
+		assert.Equal(t, token.Kind, models.TokenVerification)
+		return nil
+	})
+	testMQ.EXPECT().Publish(gomock.AssignableToTypeOf(models.TokenEvent{})).Do(func(ev any) {
+		assert.Equal(t, ev.(models.TokenEvent).EventType, "UserVerificationRequested")
+	})
+	testObj := NewUserHandler(logrus.New(), testDB, testMQ, secutiry.NewAuthObj([]byte("test")))
+	testObj.ResendVerification(ctx)
+	assert.Equal(t, w.Code, http.StatusAccepted)
+}
+
+func TestResendVerificationNotPending(t *testing.T) {
+	for _, tc := range []struct {
+		user models.User
+		err  error
+	}{
+		{models.User{Email: "test@test.pl", Status: models.UserStatusActive}, nil},
+		{models.User{}, dbhandler.ErrNotFound},
+	} {
+		w := httptest.NewRecorder()
+		ctx := GetTestGinContext(w)
+		MockJsonPost(ctx, models.ResendVerificationRequest{Email: "test@test.pl"}, "")
+		ctrl := gomock.NewController(t)
+		testDB := dbhandler.NewMockDBHandler(ctrl)
+		testDB.EXPECT().GetUser(gomock.Any(), "test@test.pl").Return(tc.user, tc.err)
+		testObj := NewUserHandler(logrus.New(), testDB, queueHandler.NewMockQueueHandler(ctrl), secutiry.NewAuthObj([]byte("test")))
+		testObj.ResendVerification(ctx)
+		assert.Equal(t, w.Code, http.StatusAccepted)
+	}
+}
+
+func TestConfirm(t *testing.T) {
+	w := httptest.NewRecorder()
+	ctx := GetTestGinContext(w)
+	var logger = logrus.New()
+	MockJsonPost(ctx, models.ConfirmRequest{Token: "verify"}, "")
+	ctrl := gomock.NewController(t)
+	testDB := dbhandler.NewMockDBHandler(ctrl)
+	testMQ := queueHandler.NewMockQueueHandler(ctrl)
+	auth := secutiry.NewAuthObj([]byte("test"))
+	testDB.EXPECT().ConsumeToken(gomock.Any(), secutiry.HashToken("verify"), models.TokenVerification).Return(models.Token{
+		UserID:    "test@test.pl",
+		ExpiresAt: time.Now().Add(time.Hour),
+	}, nil)
+	testDB.EXPECT().GetUser(gomock.Any(), "test@test.pl").Return(models.User{Email: "test@test.pl", Status: models.UserStatusPending}, nil)
+	testDB.EXPECT().UpdateUser(gomock.Any(), gomock.Any()).DoAndReturn(func(_ any, usr models.User) error {
+		assert.Equal(t, usr.Status, models.UserStatusActive)
+		return nil
+	})
+	testMQ.EXPECT().Publish(gomock.Any())
+	testObj := NewUserHandler(logger, testDB, testMQ, auth)
+	testObj.Confirm(ctx)
+	assert.Equal(t, w.Code, http.StatusOK)
+}
+
+func TestConfirmInvalid(t *testing.T) {
+	w := httptest.NewRecorder()
+	ctx := GetTestGinContext(w)
+	var logger = logrus.New()
+	MockJsonPost(ctx, models.ConfirmRequest{Token: "verify"}, "")
+	ctrl := gomock.NewController(t)
+	testDB := dbhandler.NewMockDBHandler(ctrl)
+	testMQ := queueHandler.NewMockQueueHandler(ctrl)
+	auth := secutiry.NewAuthObj([]byte("test"))
+	testDB.EXPECT().ConsumeToken(gomock.Any(), gomock.Any(), gomock.Any()).Return(models.Token{}, dbhandler.ErrNotFound)
+	testObj := NewUserHandler(logger, testDB, testMQ, auth)
+	testObj.Confirm(ctx)
+	assert.Equal(t, w.Code, http.StatusBadRequest)
+}
+
+func TestLoginLocksAccount(t *testing.T) {
+	w := httptest.NewRecorder()
+	ctx := GetTestGinContext(w)
+	var logger = logrus.New()
+	MockJsonPost(ctx, models.LoginRequest{Username: "test@test.pl", Password: "wrong"}, "")
+	ctrl := gomock.NewController(t)
+	testDB := dbhandler.NewMockDBHandler(ctrl)
+	testMQ := queueHandler.NewMockQueueHandler(ctrl)
+	auth := secutiry.NewAuthObj([]byte("test"))
+	hash, err := bcrypt.GenerateFromPassword([]byte("test"), bcrypt.MinCost)
+	assert.NoError(t, err)
+	testDB.EXPECT().GetUser(gomock.Any(), gomock.Any()).Return(models.User{
+		Email:    "test@test.pl",
+		Password: string(hash),
+		Lockout:  models.Lockout{FailedAttempts: 2, Count: 1},
+	}, nil)
+	testDB.EXPECT().RecordFailedLogin(gomock.Any(), "test@test.pl").Return(models.Lockout{FailedAttempts: 3, Count: 1}, nil)
+	testDB.EXPECT().LockAccount(gomock.Any(), "test@test.pl", 3, gomock.Any()).DoAndReturn(func(_ any, _ string, _ int, lockout models.Lockout) (bool, error) {
+		assert.Equal(t, lockout.Count, 2)
+		assert.Equal(t, lockout.FailedAttempts, 0)
+		assert.True(t, lockout.LockedUntil.After(time.Now().Add(time.Minute)))
+		return true, nil
+	})
+	testMQ.EXPECT().Publish(gomock.AssignableToTypeOf(models.LockoutEvent{}))
+	testObj := NewUserHandler(logger, testDB, testMQ, auth, WithLockoutPolicy(
+		secutiry.LockoutPolicy{Threshold: 3, BaseDelay: time.Minute, MaxDelay: time.Hour},
+		secutiry.LockoutPolicy{Threshold: 10, BaseDelay: time.Minute, MaxDelay: time.Hour},
+	))
+	testObj.Login(ctx)
+	assert.Equal(t, w.Code, http.StatusUnauthorized)
+}
+
+func TestLoginFailuresCountedDespiteStaleReads(t *testing.T) {
+	hash, err := bcrypt.GenerateFromPassword([]byte("test"), bcrypt.MinCost)
+	assert.NoError(t, err)
+	ctrl := gomock.NewController(t)
+	testDB := dbhandler.NewMockDBHandler(ctrl)
+	testMQ := queueHandler.NewMockQueueHandler(ctrl)
+	stale := models.User{Email: "test@test.pl", Password: string(hash)}
+	testDB.EXPECT().GetUser(gomock.Any(), "test@test.pl").Return(stale, nil).Times(4)
+	for attempts := 1; attempts <= 4; attempts++ {
+		testDB.EXPECT().RecordFailedLogin(gomock.Any(), "test@test.pl").Return(models.Lockout{FailedAttempts: attempts}, nil)
+	}
+	testDB.EXPECT().LockAccount(gomock.Any(), "test@test.pl", 3, gomock.Any()).Return(true, nil)
+	testDB.EXPECT().LockAccount(gomock.Any(), "test@test.pl", 3, gomock.Any()).Return(false, nil)
+	testMQ.EXPECT().Publish(gomock.AssignableToTypeOf(models.LockoutEvent{})).Times(1)
+	testObj := NewUserHandler(logrus.New(), testDB, testMQ, secutiry.NewAuthObj([]byte("test")), WithLockoutPolicy(
+		secutiry.LockoutPolicy{Threshold: 3, BaseDelay: time.Minute, MaxDelay: time.Hour},
+		secutiry.LockoutPolicy{Threshold: 10, BaseDelay: time.Minute, MaxDelay: time.Hour},
+	))
+	for i := 0; i < 4; i++ {
+		w := httptest.NewRecorder()
+		ctx := GetTestGinContext(w)
+		MockJsonPost(ctx, models.LoginRequest{Username: "test@test.pl", Password: "wrong"}, "")
+		testObj.Login(ctx)
+		assert.Equal(t, w.Code, http.StatusUnauthorized)
+	}
+}
+
+func TestLoginLockedAccount(t *testing.T) {
+	w := httptest.NewRecorder()
+	ctx := GetTestGinContext(w)
+	var logger = logrus.New()
+	MockJsonPost(ctx, models.LoginRequest{Username: "test@test.pl", Password: "test"}, "")
+	ctrl := gomock.NewController(t)
+	testDB := dbhandler.NewMockDBHandler(ctrl)
+	testMQ := queueHandler.NewMockQueueHandler(ctrl)
+	auth := secutiry.NewAuthObj([]byte("test"))
+	testDB.EXPECT().GetUser(gomock.Any(), gomock.Any()).Return(models.User{
+		Email:   "test@test.pl",
+		Lockout: models.Lockout{Count: 1, LockedUntil: time.Now().Add(time.Minute)},
+	}, nil)
+	testObj := NewUserHandler(logger, testDB, testMQ, auth)
+	testObj.Login(ctx)
+	assert.Equal(t, w.Code, http.StatusLocked)
+	assert.NotEmpty(t, w.Header().Get("Retry-After"))
+}
+
+func TestLoginLocksIP(t *testing.T) {
+	var logger = logrus.New()
+	ctrl := gomock.NewController(t)
+	testDB := dbhandler.NewMockDBHandler(ctrl)
+	testMQ := queueHandler.NewMockQueueHandler(ctrl)
+	auth := secutiry.NewAuthObj([]byte("test"))
+	testObj := NewUserHandler(logger, testDB, testMQ, auth, WithLockoutPolicy(
+		secutiry.LockoutPolicy{Threshold: 10, BaseDelay: time.Minute, MaxDelay: time.Hour},
+		secutiry.LockoutPolicy{Threshold: 2, BaseDelay: time.Minute, MaxDelay: time.Hour},
+	))
+	testDB.EXPECT().GetUser(gomock.Any(), gomock.Any()).Return(models.User{}, dbhandler.ErrNotFound).Times(2)
+	testMQ.EXPECT().Publish(gomock.AssignableToTypeOf(models.LockoutEvent{}))
+	for _, code := range []int{http.StatusUnauthorized, http.StatusUnauthorized, http.StatusTooManyRequests} {
+		w := httptest.NewRecorder()
+		ctx := GetTestGinContext(w)
+		MockJsonPost(ctx, models.LoginRequest{Username: "nobody@test.pl", Password: "test"}, "")
+		testObj.Login(ctx)
+		assert.Equal(t, w.Code, code)
+	}
+}
+
+func TestLoginRehash(t *testing.T) {
+	w := httptest.NewRecorder()
+	ctx := GetTestGinContext(w)
+	var logger = logrus.New()
+	MockJsonPost(ctx, models.LoginRequest{Username: "test@test.pl", Password: "test"}, "")
+	ctrl := gomock.NewController(t)
+	testDB := dbhandler.NewMockDBHandler(ctrl)
+	testMQ := queueHandler.NewMockQueueHandler(ctrl)
+	auth := secutiry.NewAuthObj([]byte("test"))
+	hash, err := bcrypt.GenerateFromPassword([]byte("test"), bcrypt.MinCost)
+	assert.NoError(t, err)
+	hasher := secutiry.PasswordHasher{Algorithm: secutiry.AlgorithmArgon2id, Argon2: secutiry.Argon2Params{
+		Memory: 1024, Iterations: 1, Parallelism: 1, SaltLength: 16, KeyLength: 32,
+	}}
+	testDB.EXPECT().GetUser(gomock.Any(), gomock.Any()).Return(models.User{Email: "test@test.pl", Password: string(hash)}, nil)
+	testDB.EXPECT().UpdatePassword(gomock.Any(), "test@test.pl", gomock.Any()).DoAndReturn(func(_ any, _ string, password string) error {
+		ok, rehash, err := hasher.Verify(password, "test")
+		assert.NoError(t, err)
+		assert.True(t, ok)
+		assert.False(t, rehash)
+		return nil
+	})
+	testDB.EXPECT().SaveToken(gomock.Any(), gomock.Any()).Return(nil)
+	testObj := NewUserHandler(logger, testDB, testMQ, auth, WithPasswordHasher(hasher))
+	testObj.Login(ctx)
+	assert.Equal(t, w.Code, http.StatusOK)
+}
+
+func TestDownscopeNotGranted(t *testing.T) {
+	w := httptest.NewRecorder()
+	ctx := GetTestGinContext(w)
+	var logger = logrus.New()
+	MockJsonPost(ctx, models.DownscopeRequest{Scopes: []string{secutiry.ScopeUsersWrite}}, "")
+	ctx.Set(secutiry.ContextSubject, "test@test.pl")
+	ctx.Set(secutiry.ContextRole, models.RoleUser)
+	ctx.Set(secutiry.ContextScopes, []string{secutiry.ScopeUsersRead})
+	ctrl := gomock.NewController(t)
+	testDB := dbhandler.NewMockDBHandler(ctrl)
+	testMQ := queueHandler.NewMockQueueHandler(ctrl)
+	auth := secutiry.NewAuthObj([]byte("test"))
+	testObj := NewUserHandler(logger, testDB, testMQ, auth)
+	testObj.Downscope(ctx)
+	assert.Equal(t, w.Code, http.StatusForbidden)
+}
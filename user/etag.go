@@ -0,0 +1,69 @@
+package user
+
+import (
+	"UserStorage/dbhandler"
+	"UserStorage/models"
+	"errors"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+func etag(version int64) string {
+	return `"` + strconv.FormatInt(version, 10) + `"`
+}
+
+func etagMatches(header string, version int64, weak bool) bool {
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimSpace(tag)
+		if tag == "*" {
+			return true
+		}
+		if strings.HasPrefix(tag, "W/") {
+			if !weak {
+				continue
+			}
+			tag = tag[2:]
+		}
+		if tag == etag(version) {
+			return true
+		}
+	}
+	return false
+}
+
+func (uh *UserHandler) checkPreconditions(c *gin.Context, usr models.User) bool {
+	if ifMatch := c.GetHeader("If-Match"); ifMatch != "" && !etagMatches(ifMatch, usr.Version, false) {
+		c.JSON(http.StatusPreconditionFailed, gin.H{"error": "If-Match does not match the current version"})
+		return false
+	}
+	if ifNoneMatch := c.GetHeader("If-None-Match"); ifNoneMatch != "" && etagMatches(ifNoneMatch, usr.Version, true) {
+		c.JSON(http.StatusPreconditionFailed, gin.H{"error": "If-None-Match matches the current version"})
+		return false
+	}
+	return true
+}
+
+func (uh *UserHandler) preconditionVersion(c *gin.Context, id string) (int64, bool) {
+	if c.GetHeader("If-Match") == "" && c.GetHeader("If-None-Match") == "" {
+		return dbhandler.AnyVersion, true
+	}
+	usr, ok := uh.loadUser(c, id)
+	if !ok || !uh.checkPreconditions(c, usr) {
+		return 0, false
+	}
+	return usr.Version, true
+}
+
+func (uh *UserHandler) writeFailed(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, dbhandler.ErrVersionConflict):
+		c.JSON(http.StatusPreconditionFailed, gin.H{"error": err.Error()})
+	case errors.Is(err, dbhandler.ErrNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	default:
+		uh.logger.Error(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
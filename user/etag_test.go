@@ -0,0 +1,128 @@
+package user
+
+import (
+	"UserStorage/dbhandler"
+	"UserStorage/models"
+	"UserStorage/queueHandler"
+	"UserStorage/secutiry"
+	"github.com/gin-gonic/gin"
+	"github.com/golang/mock/gomock"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+)
+
+func TestETagMatches(t *testing.T) {
+	assert.True(t, etagMatches(`"3"`, 3, false))
+	assert.True(t, etagMatches(`"1", "3"`, 3, false))
+	assert.True(t, etagMatches(`*`, 3, false))
+	assert.False(t, etagMatches(`"4"`, 3, false))
+	assert.False(t, etagMatches(`W/"3"`, 3, false))
+	assert.True(t, etagMatches(`W/"3"`, 3, true))
+}
+
+func TestGetUserETag(t *testing.T) {
+	w := httptest.NewRecorder()
+	ctx := GetTestGinContext(w)
+	MockJsonGet(ctx, gin.Params{gin.Param{Key: "id", Value: "test@test.pl"}}, url.Values{})
+	ctrl := gomock.NewController(t)
+	testDB := dbhandler.NewMockDBHandler(ctrl)
+	testDB.EXPECT().GetUser(gomock.Any(), "test@test.pl").Return(models.User{Email: "test@test.pl", Version: 7}, nil)
+	testObj := NewUserHandler(logrus.New(), testDB, queueHandler.NewMockQueueHandler(ctrl), secutiry.NewAuthObj([]byte("test")))
+	testObj.GetUser(ctx)
+	assert.Equal(t, w.Code, http.StatusOK)
+	assert.Equal(t, w.Header().Get("ETag"), `"7"`)
+}
+
+func TestGetUserNotModified(t *testing.T) {
+	w := httptest.NewRecorder()
+	ctx := GetTestGinContext(w)
+	MockJsonGet(ctx, gin.Params{gin.Param{Key: "id", Value: "test@test.pl"}}, url.Values{})
+	ctx.Request.Header.Set("If-None-Match", `"7"`)
+	ctrl := gomock.NewController(t)
+	testDB := dbhandler.NewMockDBHandler(ctrl)
+	testDB.EXPECT().GetUser(gomock.Any(), "test@test.pl").Return(models.User{Email: "test@test.pl", Version: 7}, nil)
+	testObj := NewUserHandler(logrus.New(), testDB, queueHandler.NewMockQueueHandler(ctrl), secutiry.NewAuthObj([]byte("test")))
+	testObj.GetUser(ctx)
+	assert.Equal(t, ctx.Writer.Status(), http.StatusNotModified)
+}
+
+func TestUpdUserIfMatchFailed(t *testing.T) {
+	w := httptest.NewRecorder()
+	ctx := GetTestGinContext(w)
+	MockJsonPost(ctx, models.UserUpdate{Username: "test", Age: 21}, "test@test.pl")
+	ctx.Request.Header.Set("If-Match", `"2"`)
+	ctrl := gomock.NewController(t)
+	testDB := dbhandler.NewMockDBHandler(ctrl)
+	testDB.EXPECT().GetUser(gomock.Any(), "test@test.pl").Return(models.User{Email: "test@test.pl", Version: 3}, nil)
+	testObj := NewUserHandler(logrus.New(), testDB, queueHandler.NewMockQueueHandler(ctrl), secutiry.NewAuthObj([]byte("test")))
+	testObj.UpdateUser(ctx)
+	assert.Equal(t, w.Code, http.StatusPreconditionFailed)
+}
+
+func TestUpdUserConcurrentWrite(t *testing.T) {
+	w := httptest.NewRecorder()
+	ctx := GetTestGinContext(w)
+	MockJsonPost(ctx, models.UserUpdate{Username: "test", Age: 21}, "test@test.pl")
+	ctx.Request.Header.Set("If-Match", `"3"`)
+	ctrl := gomock.NewController(t)
+	testDB := dbhandler.NewMockDBHandler(ctrl)
+	testDB.EXPECT().GetUser(gomock.Any(), "test@test.pl").Return(models.User{Email: "test@test.pl", Version: 3}, nil)
+	testDB.EXPECT().UpdateUser(gomock.Any(), gomock.Any()).Return(dbhandler.ErrVersionConflict)
+	testObj := NewUserHandler(logrus.New(), testDB, queueHandler.NewMockQueueHandler(ctrl), secutiry.NewAuthObj([]byte("test")))
+	testObj.UpdateUser(ctx)
+	assert.Equal(t, w.Code, http.StatusPreconditionFailed)
+}
+
+func TestUpdUserETag(t *testing.T) {
+	w := httptest.NewRecorder()
+	ctx := GetTestGinContext(w)
+	MockJsonPost(ctx, models.UserUpdate{Username: "test", Age: 21}, "test@test.pl")
+	ctx.Request.Header.Set("If-Match", `"3"`)
+	ctrl := gomock.NewController(t)
+	testDB := dbhandler.NewMockDBHandler(ctrl)
+	testMQ := queueHandler.NewMockQueueHandler(ctrl)
+	testDB.EXPECT().GetUser(gomock.Any(), "test@test.pl").Return(models.User{Email: "test@test.pl", Version: 3}, nil)
+	testDB.EXPECT().UpdateUser(gomock.Any(), gomock.Any()).DoAndReturn(func(_ any, usr models.User) error {
+		assert.Equal(t, usr.Version, int64(3))
+		return nil
+	})
+	testMQ.EXPECT().Publish(gomock.Any())
+	testObj := NewUserHandler(logrus.New(), testDB, testMQ, secutiry.NewAuthObj([]byte("test")))
+	testObj.UpdateUser(ctx)
+	assert.Equal(t, w.Code, http.StatusOK)
+	assert.Equal(t, w.Header().Get("ETag"), `"4"`)
+}
+
+func TestDeleteUsrIfMatch(t *testing.T) {
+	w := httptest.NewRecorder()
+	ctx := GetTestGinContext(w)
+	MockJsonDelete(ctx, gin.Params{gin.Param{Key: "id", Value: "test@test.pl"}})
+	ctx.Request.Header.Set("If-Match", `"5"`)
+	ctrl := gomock.NewController(t)
+	testDB := dbhandler.NewMockDBHandler(ctrl)
+	expectRevokeAccess(testDB, "test@test.pl")
+	testMQ := queueHandler.NewMockQueueHandler(ctrl)
+	testDB.EXPECT().GetUser(gomock.Any(), "test@test.pl").Return(models.User{Email: "test@test.pl", Version: 5}, nil)
+	testDB.EXPECT().DeleteUser(gomock.Any(), "test@test.pl", int64(5)).Return(nil)
+	testMQ.EXPECT().Publish(gomock.Any())
+	testObj := NewUserHandler(logrus.New(), testDB, testMQ, secutiry.NewAuthObj([]byte("test")))
+	testObj.DeleteUser(ctx)
+	assert.Equal(t, w.Code, http.StatusOK)
+}
+
+func TestDeleteFilesIfNoneMatch(t *testing.T) {
+	w := httptest.NewRecorder()
+	ctx := GetTestGinContext(w)
+	MockJsonDelete(ctx, gin.Params{gin.Param{Key: "id", Value: "test@test.pl"}})
+	ctx.Request.Header.Set("If-None-Match", `*`)
+	ctrl := gomock.NewController(t)
+	testDB := dbhandler.NewMockDBHandler(ctrl)
+	testDB.EXPECT().GetUser(gomock.Any(), "test@test.pl").Return(models.User{Email: "test@test.pl", Version: 5}, nil)
+	testObj := NewUserHandler(logrus.New(), testDB, queueHandler.NewMockQueueHandler(ctrl), secutiry.NewAuthObj([]byte("test")))
+	testObj.DeleteFilesFromUser(ctx)
+	assert.Equal(t, w.Code, http.StatusPreconditionFailed)
+}
@@ -0,0 +1,85 @@
+package user
+
+import (
+	"UserStorage/models"
+	"encoding/csv"
+	"encoding/json"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+const (
+	contentTypeNDJSON = "application/x-ndjson"
+	contentTypeCSV    = "text/csv"
+
+	exportFlushEvery = 500
+)
+
+var csvHeader = []string{"email", "username", "age", "status", "role", "files"}
+
+func csvRecord(user models.User) []string {
+	files := make([]string, len(user.Files))
+	for i, file := range user.Files {
+		files[i] = file.Name
+	}
+	return []string{user.Email, user.Username, strconv.Itoa(user.Age), user.Status, user.Role, strings.Join(files, ";")}
+}
+
+func (uh *UserHandler) ExportUsers(c *gin.Context) {
+	format := c.NegotiateFormat(contentTypeNDJSON, contentTypeCSV)
+	if format == "" {
+		c.JSON(http.StatusNotAcceptable, gin.H{"error": "supported formats are " + contentTypeNDJSON + " and " + contentTypeCSV})
+		return
+	}
+	c.Header("Content-Type", format+"; charset=utf-8")
+	c.Status(http.StatusOK)
+
+	var write func(models.User) error
+	var flush func() error
+	if format == contentTypeCSV {
+		w := csv.NewWriter(c.Writer)
+		if err := w.Write(csvHeader); err != nil {
+			uh.logger.Error(err)
+			return
+		}
+		write = func(user models.User) error {
+			return w.Write(csvRecord(user))
+		}
+		flush = func() error {
+			w.Flush()
+			return w.Error()
+		}
+	} else {
+		enc := json.NewEncoder(c.Writer)
+		write = func(user models.User) error {
+			return enc.Encode(user)
+		}
+		flush = func() error { return nil }
+	}
+
+	n := 0
+	err := uh.dbHan.ExportUsers(c.Request.Context(), func(user models.User) error {
+		user.Password = ""
+		if err := write(user); err != nil {
+			return err
+		}
+		n++
+		if n%exportFlushEvery == 0 {
+			if err := flush(); err != nil {
+				return err
+			}
+			c.Writer.Flush()
+		}
+		return nil
+	})
+	if err == nil {
+		err = flush()
+	}
+	if err != nil {
+		uh.logger.Error(err)
+		return
+	}
+	c.Writer.Flush()
+}
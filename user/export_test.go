@@ -0,0 +1,82 @@
+package user
+
+import (
+	"UserStorage/dbhandler"
+	"UserStorage/models"
+	"UserStorage/queueHandler"
+	"UserStorage/secutiry"
+	"bufio"
+	"encoding/json"
+	"github.com/gin-gonic/gin"
+	"github.com/golang/mock/gomock"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func exportUsers(_ any, fn func(models.User) error) error {
+	for _, user := range []models.User{
+		{Email: "a@test.pl", Username: "a", Age: 20, Password: "hash", Files: []models.File{{Name: "x"}, {Name: "y"}}},
+		{Email: "b@test.pl", Username: "b, \"quoted\"", Age: 30, Password: "hash"},
+	} {
+		if err := fn(user); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func TestExportUsersNDJSON(t *testing.T) {
+	w := httptest.NewRecorder()
+	ctx := GetTestGinContext(w)
+	MockJsonGet(ctx, gin.Params{}, url.Values{})
+	ctrl := gomock.NewController(t)
+	testDB := dbhandler.NewMockDBHandler(ctrl)
+	testDB.EXPECT().ExportUsers(gomock.Any(), gomock.Any()).DoAndReturn(exportUsers)
+	testObj := NewUserHandler(logrus.New(), testDB, queueHandler.NewMockQueueHandler(ctrl), secutiry.NewAuthObj([]byte("test")))
+	testObj.ExportUsers(ctx)
+	assert.Equal(t, w.Code, http.StatusOK)
+	assert.True(t, strings.HasPrefix(w.Header().Get("Content-Type"), contentTypeNDJSON))
+	assert.NotContains(t, w.Body.String(), "hash")
+	scanner := bufio.NewScanner(w.Body)
+	var emails []string
+	for scanner.Scan() {
+		var user map[string]any
+		assert.NoError(t, json.Unmarshal(scanner.Bytes(), &user))
+		_, hasPassword := user["password"]
+		assert.False(t, hasPassword)
+		emails = append(emails, user["email"].(string))
+	}
+	assert.Equal(t, emails, []string{"a@test.pl", "b@test.pl"})
+}
+
+func TestExportUsersCSV(t *testing.T) {
+	w := httptest.NewRecorder()
+	ctx := GetTestGinContext(w)
+	MockJsonGet(ctx, gin.Params{}, url.Values{})
+	ctx.Request.Header.Set("Accept", "text/csv")
+	ctrl := gomock.NewController(t)
+	testDB := dbhandler.NewMockDBHandler(ctrl)
+	testDB.EXPECT().ExportUsers(gomock.Any(), gomock.Any()).DoAndReturn(exportUsers)
+	testObj := NewUserHandler(logrus.New(), testDB, queueHandler.NewMockQueueHandler(ctrl), secutiry.NewAuthObj([]byte("test")))
+	testObj.ExportUsers(ctx)
+	assert.Equal(t, w.Code, http.StatusOK)
+	assert.Equal(t, w.Body.String(), "email,username,age,status,role,files\n"+
+		"a@test.pl,a,20,,,x;y\n"+
+		"b@test.pl,\"b, \"\"quoted\"\"\",30,,,\n")
+}
+
+func TestExportUsersNotAcceptable(t *testing.T) {
+	w := httptest.NewRecorder()
+	ctx := GetTestGinContext(w)
+	MockJsonGet(ctx, gin.Params{}, url.Values{})
+	ctx.Request.Header.Set("Accept", "application/xml")
+	ctrl := gomock.NewController(t)
+	testObj := NewUserHandler(logrus.New(), dbhandler.NewMockDBHandler(ctrl), queueHandler.NewMockQueueHandler(ctrl), secutiry.NewAuthObj([]byte("test")))
+	testObj.ExportUsers(ctx)
+	assert.Equal(t, w.Code, http.StatusNotAcceptable)
+}
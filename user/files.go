@@ -0,0 +1,184 @@
+package user
+
+import (
+	"UserStorage/models"
+	"UserStorage/storage"
+	"bufio"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"github.com/gin-gonic/gin"
+	"io"
+	"mime"
+	"net/http"
+	"path"
+	"slices"
+	"strings"
+	"time"
+)
+
+const (
+	DefaultMaxUploadSize = 100 << 20
+
+	sniffLength = 512
+)
+
+func (uh *UserHandler) uploadFile(c *gin.Context, id string) {
+	version, ok := uh.preconditionVersion(c, id)
+	if !ok {
+		return
+	}
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, uh.maxUploadSize)
+	name, contentType, src, err := uploadSource(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	body := bufio.NewReaderSize(src, sniffLength)
+	if contentType == "" || contentType == "application/octet-stream" {
+		head, _ := body.Peek(sniffLength)
+		contentType = http.DetectContentType(head)
+	}
+
+	raw := make([]byte, 16)
+	if _, err = rand.Read(raw); err != nil {
+		uh.logger.Error(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	fileID := hex.EncodeToString(raw)
+	size, err := uh.blobs.Put(c.Request.Context(), blobKey(id, fileID), body)
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "file is larger than the upload limit"})
+		return
+	}
+	if err != nil {
+		uh.logger.Error(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	now := time.Now().UTC()
+	file := models.File{ID: fileID, Name: name, ContentType: contentType, Size: size, UploadedAt: &now}
+	before := uh.snapshot(c, id)
+	if err = uh.dbHan.AddFileToUser(c.Request.Context(), id, file, version); err != nil {
+		uh.deleteBlobs(c.Request.Context(), id, []models.File{file})
+		uh.writeFailed(c, err)
+		return
+	}
+	uh.audit(c, models.AuditFileAdded, id, before, uh.snapshot(c, id))
+	c.JSON(http.StatusCreated, file)
+}
+
+func uploadSource(c *gin.Context) (string, string, io.Reader, error) {
+	if c.ContentType() != gin.MIMEMultipartPOSTForm {
+		name := c.Query("name")
+		if name == "" {
+			if _, params, err := mime.ParseMediaType(c.GetHeader("Content-Disposition")); err == nil {
+				name = params["filename"]
+			}
+		}
+		name = cleanFileName(name)
+		if name == "" {
+			return "", "", nil, errors.New("file name is required, pass ?name= or a Content-Disposition filename")
+		}
+		return name, c.ContentType(), c.Request.Body, nil
+	}
+	reader, err := c.Request.MultipartReader()
+	if err != nil {
+		return "", "", nil, err
+	}
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			return "", "", nil, errors.New(`multipart form has no "file" field`)
+		}
+		if err != nil {
+			return "", "", nil, err
+		}
+		if part.FormName() != "file" {
+			continue
+		}
+		name := cleanFileName(part.FileName())
+		if name == "" {
+			return "", "", nil, errors.New("file name is required")
+		}
+		contentType, _, _ := mime.ParseMediaType(part.Header.Get("Content-Type"))
+		return name, contentType, part, nil
+	}
+}
+
+func cleanFileName(name string) string {
+	name = path.Base(strings.ReplaceAll(name, `\`, "/"))
+	if name == "." || name == "/" {
+		return ""
+	}
+	return name
+}
+
+func (uh *UserHandler) DownloadFile(c *gin.Context) {
+	id := c.Param("id")
+	fileID := c.Param("fileId")
+	files, err := uh.dbHan.GetUserFiles(c.Request.Context(), id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	i := slices.IndexFunc(files, func(f models.File) bool { return f.ID != "" && f.ID == fileID })
+	if i < 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "file not found"})
+		return
+	}
+	file := files[i]
+	if uh.blobs == nil {
+		c.JSON(http.StatusNotImplemented, gin.H{"error": "file storage is not configured"})
+		return
+	}
+	blob, err := uh.blobs.Open(c.Request.Context(), blobKey(id, file.ID))
+	if errors.Is(err, storage.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "file content not found"})
+		return
+	}
+	if err != nil {
+		uh.logger.Error(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	defer blob.Close()
+
+	contentType := file.ContentType
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	var modified time.Time
+	if file.UploadedAt != nil {
+		modified = *file.UploadedAt
+	}
+	c.Header("Content-Type", contentType)
+	c.Header("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": file.Name}))
+	c.Header("X-Content-Type-Options", "nosniff")
+	c.Header("ETag", `"`+file.ID+`"`)
+	http.ServeContent(c.Writer, c.Request, file.Name, modified, blob)
+}
+
+func blobKey(userID, fileID string) string {
+	sum := sha256.Sum256([]byte(userID + "\x00" + fileID))
+	return hex.EncodeToString(sum[:])
+}
+
+func (uh *UserHandler) deleteBlobs(ctx context.Context, userID string, files []models.File) {
+	if uh.blobs == nil {
+		return
+	}
+	for _, file := range files {
+		if file.ID == "" {
+			continue
+		}
+		if err := uh.blobs.Delete(ctx, blobKey(userID, file.ID)); err != nil && !errors.Is(err, storage.ErrNotFound) {
+			uh.logger.Error(err)
+		}
+	}
+}
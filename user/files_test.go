@@ -0,0 +1,208 @@
+package user
+
+import (
+	"UserStorage/dbhandler"
+	"UserStorage/models"
+	"UserStorage/queueHandler"
+	"UserStorage/secutiry"
+	"UserStorage/storage"
+	"bytes"
+	"context"
+	"encoding/json"
+	"github.com/gin-gonic/gin"
+	"github.com/golang/mock/gomock"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func newFileTestHandler(t *testing.T, maxUploadSize int64) (*UserHandler, *dbhandler.MockDBHandler, storage.BlobStore) {
+	ctrl := gomock.NewController(t)
+	testDB := dbhandler.NewMockDBHandler(ctrl)
+	testMQ := queueHandler.NewMockQueueHandler(ctrl)
+	blobs := storage.NewMemoryBlobStore()
+	testObj := NewUserHandler(logrus.New(), testDB, testMQ, secutiry.NewAuthObj([]byte("test")), WithBlobStore(blobs, maxUploadSize))
+	return testObj, testDB, blobs
+}
+
+func mockUpload(c *gin.Context, contentType string, body []byte) {
+	c.Request.Method = "POST"
+	c.Request.Header.Set("Content-Type", contentType)
+	c.Params = gin.Params{gin.Param{Key: "id", Value: "test@test.pl"}}
+	c.Request.Body = io.NopCloser(bytes.NewReader(body))
+}
+
+func readBlob(t *testing.T, blobs storage.BlobStore, key string) string {
+	blob, err := blobs.Open(context.Background(), key)
+	assert.NoError(t, err)
+	defer blob.Close()
+	content, err := io.ReadAll(blob)
+	assert.NoError(t, err)
+	return string(content)
+}
+
+func TestUploadFileMultipart(t *testing.T) {
+	w := httptest.NewRecorder()
+	ctx := GetTestGinContext(w)
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	assert.NoError(t, form.WriteField("comment", "ignored"))
+	part, err := form.CreateFormFile("file", "../../report.txt")
+	assert.NoError(t, err)
+	_, err = part.Write([]byte("quarterly numbers"))
+	assert.NoError(t, err)
+	assert.NoError(t, form.Close())
+	mockUpload(ctx, form.FormDataContentType(), body.Bytes())
+
+	testObj, testDB, blobs := newFileTestHandler(t, DefaultMaxUploadSize)
+	var stored models.File
+	testDB.EXPECT().AddFileToUser(gomock.Any(), "test@test.pl", gomock.Any(), dbhandler.AnyVersion).DoAndReturn(func(_ any, _ string, file models.File, _ int64) error {
+		stored = file
+		return nil
+	})
+	testObj.AddFileToUser(ctx)
+	assert.Equal(t, http.StatusCreated, w.Code)
+	var out models.File
+	assert.NoError(t, json.NewDecoder(w.Body).Decode(&out))
+	assert.Equal(t, stored.ID, out.ID)
+	assert.Equal(t, "report.txt", out.Name)
+	assert.Equal(t, int64(17), out.Size)
+	assert.Equal(t, "text/plain; charset=utf-8", out.ContentType)
+	assert.NotNil(t, out.UploadedAt)
+	assert.Equal(t, "quarterly numbers", readBlob(t, blobs, blobKey("test@test.pl", out.ID)))
+}
+
+func TestUploadFileStreamSniffsContentType(t *testing.T) {
+	w := httptest.NewRecorder()
+	ctx := GetTestGinContext(w)
+	png := append([]byte("\x89PNG\r\n\x1a\n"), make([]byte, 64)...)
+	mockUpload(ctx, "application/octet-stream", png)
+	ctx.Request.Header.Set("Content-Disposition", `attachment; filename="logo.png"`)
+
+	testObj, testDB, _ := newFileTestHandler(t, DefaultMaxUploadSize)
+	testDB.EXPECT().AddFileToUser(gomock.Any(), "test@test.pl", gomock.Any(), dbhandler.AnyVersion).Return(nil)
+	testObj.AddFileToUser(ctx)
+	assert.Equal(t, http.StatusCreated, w.Code)
+	var out models.File
+	assert.NoError(t, json.NewDecoder(w.Body).Decode(&out))
+	assert.Equal(t, "logo.png", out.Name)
+	assert.Equal(t, "image/png", out.ContentType)
+	assert.Equal(t, int64(len(png)), out.Size)
+}
+
+func TestUploadFileWithoutName(t *testing.T) {
+	w := httptest.NewRecorder()
+	ctx := GetTestGinContext(w)
+	mockUpload(ctx, "text/plain", []byte("content"))
+
+	testObj, _, _ := newFileTestHandler(t, DefaultMaxUploadSize)
+	testObj.AddFileToUser(ctx)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestUploadFileTooLarge(t *testing.T) {
+	w := httptest.NewRecorder()
+	ctx := GetTestGinContext(w)
+	mockUpload(ctx, "text/plain", bytes.Repeat([]byte("a"), 64))
+	ctx.Request.URL.RawQuery = "name=big.txt"
+
+	testObj, _, _ := newFileTestHandler(t, 16)
+	testObj.AddFileToUser(ctx)
+	assert.Equal(t, http.StatusRequestEntityTooLarge, w.Code)
+}
+
+func TestUploadFileDBErrorRemovesBlob(t *testing.T) {
+	w := httptest.NewRecorder()
+	ctx := GetTestGinContext(w)
+	mockUpload(ctx, "text/plain", []byte("content"))
+	ctx.Request.URL.RawQuery = "name=notes.txt"
+
+	testObj, testDB, blobs := newFileTestHandler(t, DefaultMaxUploadSize)
+	var stored models.File
+	testDB.EXPECT().AddFileToUser(gomock.Any(), "test@test.pl", gomock.Any(), dbhandler.AnyVersion).DoAndReturn(func(_ any, _ string, file models.File, _ int64) error {
+		stored = file
+		return dbhandler.ErrNotFound
+	})
+	testObj.AddFileToUser(ctx)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	_, err := blobs.Open(context.Background(), blobKey("test@test.pl", stored.ID))
+	assert.ErrorIs(t, err, storage.ErrNotFound)
+}
+
+func TestDownloadFileRange(t *testing.T) {
+	w := httptest.NewRecorder()
+	ctx := GetTestGinContext(w)
+	MockJsonGet(ctx, gin.Params{{Key: "id", Value: "test@test.pl"}, {Key: "fileId", Value: "abc123"}}, nil)
+	ctx.Request.Header.Set("Range", "bytes=6-10")
+
+	testObj, testDB, blobs := newFileTestHandler(t, DefaultMaxUploadSize)
+	_, err := blobs.Put(context.Background(), blobKey("test@test.pl", "abc123"), strings.NewReader("hello world"))
+	assert.NoError(t, err)
+	testDB.EXPECT().GetUserFiles(gomock.Any(), "test@test.pl").Return([]models.File{
+		{Name: "legacy"},
+		{ID: "abc123", Name: "hello.txt", ContentType: "text/plain", Size: 11},
+	}, nil)
+	testObj.DownloadFile(ctx)
+	assert.Equal(t, http.StatusPartialContent, w.Code)
+	assert.Equal(t, "world", w.Body.String())
+	assert.Equal(t, "5", w.Header().Get("Content-Length"))
+	assert.Equal(t, "bytes 6-10/11", w.Header().Get("Content-Range"))
+	assert.Equal(t, "text/plain", w.Header().Get("Content-Type"))
+	assert.Equal(t, `attachment; filename=hello.txt`, w.Header().Get("Content-Disposition"))
+}
+
+func TestDownloadFileOfOtherUser(t *testing.T) {
+	w := httptest.NewRecorder()
+	ctx := GetTestGinContext(w)
+	MockJsonGet(ctx, gin.Params{{Key: "id", Value: "b@test.pl"}, {Key: "fileId", Value: "abc123"}}, nil)
+
+	testObj, testDB, blobs := newFileTestHandler(t, DefaultMaxUploadSize)
+	_, err := blobs.Put(context.Background(), blobKey("a@test.pl", "abc123"), strings.NewReader("secret"))
+	assert.NoError(t, err)
+	testDB.EXPECT().GetUserFiles(gomock.Any(), "b@test.pl").Return([]models.File{{ID: "abc123", Name: "stolen.txt"}}, nil)
+	testObj.DownloadFile(ctx)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.NotContains(t, w.Body.String(), "secret")
+}
+
+func TestAddFileMetadataRejectedWithBlobStore(t *testing.T) {
+	w := httptest.NewRecorder()
+	ctx := GetTestGinContext(w)
+	MockJsonPost(ctx, models.File{ID: "abc123", Name: "stolen.txt"}, "test@test.pl")
+
+	testObj, _, _ := newFileTestHandler(t, DefaultMaxUploadSize)
+	testObj.AddFileToUser(ctx)
+	assert.Equal(t, http.StatusUnsupportedMediaType, w.Code)
+}
+
+func TestDownloadFileNotFound(t *testing.T) {
+	w := httptest.NewRecorder()
+	ctx := GetTestGinContext(w)
+	MockJsonGet(ctx, gin.Params{{Key: "id", Value: "test@test.pl"}, {Key: "fileId", Value: "missing"}}, nil)
+
+	testObj, testDB, _ := newFileTestHandler(t, DefaultMaxUploadSize)
+	testDB.EXPECT().GetUserFiles(gomock.Any(), "test@test.pl").Return([]models.File{{ID: "abc123", Name: "hello.txt"}}, nil)
+	testObj.DownloadFile(ctx)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestDeleteFilesRemovesBlobs(t *testing.T) {
+	w := httptest.NewRecorder()
+	ctx := GetTestGinContext(w)
+	MockJsonDelete(ctx, gin.Params{{Key: "id", Value: "test@test.pl"}})
+
+	testObj, testDB, blobs := newFileTestHandler(t, DefaultMaxUploadSize)
+	_, err := blobs.Put(context.Background(), blobKey("test@test.pl", "abc123"), strings.NewReader("hello"))
+	assert.NoError(t, err)
+	testDB.EXPECT().GetUserFiles(gomock.Any(), "test@test.pl").Return([]models.File{{ID: "abc123", Name: "hello.txt"}}, nil)
+	testDB.EXPECT().DeleteFilesFromUser(gomock.Any(), "test@test.pl", dbhandler.AnyVersion).Return(nil)
+	testObj.DeleteFilesFromUser(ctx)
+	assert.Equal(t, http.StatusOK, w.Code)
+	_, err = blobs.Open(context.Background(), blobKey("test@test.pl", "abc123"))
+	assert.ErrorIs(t, err, storage.ErrNotFound)
+}
@@ -0,0 +1,59 @@
+package user
+
+import (
+	"UserStorage/dbhandler"
+	"UserStorage/models"
+	"errors"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"strconv"
+)
+
+func (uh *UserHandler) GetUserHistory(c *gin.Context) {
+	id := c.Param("id")
+	limit := models.DefaultPageSize
+	if v := c.Query("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > models.MaxPageSize {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "limit must be between 1 and " + strconv.Itoa(models.MaxPageSize)})
+			return
+		}
+		limit = n
+	}
+	revisions, err := uh.dbHan.GetUserHistory(c.Request.Context(), id, limit)
+	if err != nil {
+		uh.logger.Error(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, revisions)
+}
+
+func (uh *UserHandler) RevertUser(c *gin.Context) {
+	id := c.Param("id")
+	var req models.RevertRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	revision, err := uh.dbHan.GetUserRevision(c.Request.Context(), id, req.Version)
+	if errors.Is(err, dbhandler.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "no such version"})
+		return
+	}
+	if err != nil {
+		uh.logger.Error(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	existing, ok := uh.loadUser(c, id)
+	if !ok || !uh.checkPreconditions(c, existing) {
+		return
+	}
+	reverted := existing
+	reverted.Username = revision.User.Username
+	reverted.Age = revision.User.Age
+	reverted.Role = revision.User.Role
+	reverted.Status = revision.User.Status
+	uh.saveUser(c, existing, reverted)
+}
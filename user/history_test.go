@@ -0,0 +1,110 @@
+package user
+
+import (
+	"UserStorage/dbhandler"
+	"UserStorage/models"
+	"UserStorage/queueHandler"
+	"UserStorage/secutiry"
+	"encoding/json"
+	"github.com/gin-gonic/gin"
+	"github.com/golang/mock/gomock"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestGetUserHistory(t *testing.T) {
+	w := httptest.NewRecorder()
+	ctx := GetTestGinContext(w)
+	MockJsonGet(ctx, gin.Params{gin.Param{Key: "id", Value: "test@test.pl"}}, url.Values{"limit": {"2"}})
+	ctrl := gomock.NewController(t)
+	testDB := dbhandler.NewMockDBHandler(ctrl)
+	testDB.EXPECT().GetUserHistory(gomock.Any(), "test@test.pl", 2).Return([]models.UserRevision{
+		{UserID: "test@test.pl", Version: 3, User: models.User{Email: "test@test.pl", Username: "new", Version: 3}},
+		{UserID: "test@test.pl", Version: 2, User: models.User{Email: "test@test.pl", Username: "old", Version: 2}},
+	}, nil)
+	testObj := NewUserHandler(logrus.New(), testDB, queueHandler.NewMockQueueHandler(ctrl), secutiry.NewAuthObj([]byte("test")))
+	testObj.GetUserHistory(ctx)
+	assert.Equal(t, w.Code, http.StatusOK)
+	var revisions []models.UserRevision
+	err := json.NewDecoder(w.Body).Decode(&revisions)
+	assert.NoError(t, err)
+	assert.Len(t, revisions, 2)
+	assert.Equal(t, revisions[1].User.Username, "old")
+}
+
+func TestGetUserAsOf(t *testing.T) {
+	w := httptest.NewRecorder()
+	ctx := GetTestGinContext(w)
+	at := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	MockJsonGet(ctx, gin.Params{gin.Param{Key: "id", Value: "test@test.pl"}}, url.Values{"asOf": {at.Format(time.RFC3339)}})
+	ctrl := gomock.NewController(t)
+	testDB := dbhandler.NewMockDBHandler(ctrl)
+	testDB.EXPECT().GetUserAsOf(gomock.Any(), "test@test.pl", at).Return(models.UserRevision{Version: 2, User: models.User{Email: "test@test.pl", Username: "old", Version: 2}}, nil)
+	testObj := NewUserHandler(logrus.New(), testDB, queueHandler.NewMockQueueHandler(ctrl), secutiry.NewAuthObj([]byte("test")))
+	testObj.GetUser(ctx)
+	assert.Equal(t, w.Code, http.StatusOK)
+	assert.Equal(t, w.Header().Get("ETag"), `"2"`)
+	var usr models.User
+	err := json.NewDecoder(w.Body).Decode(&usr)
+	assert.NoError(t, err)
+	assert.Equal(t, usr.Username, "old")
+}
+
+func TestGetUserAsOfDeleted(t *testing.T) {
+	w := httptest.NewRecorder()
+	ctx := GetTestGinContext(w)
+	MockJsonGet(ctx, gin.Params{gin.Param{Key: "id", Value: "test@test.pl"}}, url.Values{"asOf": {"2026-01-02T03:04:05Z"}})
+	ctrl := gomock.NewController(t)
+	testDB := dbhandler.NewMockDBHandler(ctrl)
+	deletedAt := time.Now()
+	testDB.EXPECT().GetUserAsOf(gomock.Any(), "test@test.pl", gomock.Any()).Return(models.UserRevision{User: models.User{Email: "test@test.pl", DeletedAt: &deletedAt}}, nil)
+	testObj := NewUserHandler(logrus.New(), testDB, queueHandler.NewMockQueueHandler(ctrl), secutiry.NewAuthObj([]byte("test")))
+	testObj.GetUser(ctx)
+	assert.Equal(t, w.Code, http.StatusNotFound)
+}
+
+func TestRevertUser(t *testing.T) {
+	w := httptest.NewRecorder()
+	ctx := GetTestGinContext(w)
+	MockJsonPost(ctx, models.RevertRequest{Version: 2}, "test@test.pl")
+	ctrl := gomock.NewController(t)
+	testDB := dbhandler.NewMockDBHandler(ctrl)
+	expectRevokeAccess(testDB, "test@test.pl")
+	testMQ := queueHandler.NewMockQueueHandler(ctrl)
+	testDB.EXPECT().GetUserRevision(gomock.Any(), "test@test.pl", int64(2)).Return(models.UserRevision{Version: 2, User: models.User{
+		Email: "test@test.pl", Username: "old", Age: 20, Role: models.RoleUser, Status: models.UserStatusActive, Password: "old-hash",
+	}}, nil)
+	testDB.EXPECT().GetUser(gomock.Any(), "test@test.pl").Return(models.User{
+		Email: "test@test.pl", Username: "new", Age: 30, Role: models.RoleAdmin, Status: models.UserStatusActive, Password: "new-hash", Version: 5,
+	}, nil)
+	testDB.EXPECT().UpdateUser(gomock.Any(), gomock.Any()).DoAndReturn(func(_ any, usr models.User) error {
+		assert.Equal(t, usr.Username, "old")
+		assert.Equal(t, usr.Age, 20)
+		assert.Equal(t, usr.Role, models.RoleUser)
+		assert.Equal(t, usr.Password, "new-hash")
+		assert.Equal(t, usr.Version, int64(5))
+		return nil
+	})
+	testMQ.EXPECT().Publish(gomock.Any())
+	testObj := NewUserHandler(logrus.New(), testDB, testMQ, secutiry.NewAuthObj([]byte("test")))
+	testObj.RevertUser(ctx)
+	assert.Equal(t, w.Code, http.StatusOK)
+	assert.Equal(t, w.Header().Get("ETag"), `"6"`)
+}
+
+func TestRevertUserUnknownVersion(t *testing.T) {
+	w := httptest.NewRecorder()
+	ctx := GetTestGinContext(w)
+	MockJsonPost(ctx, models.RevertRequest{Version: 9}, "test@test.pl")
+	ctrl := gomock.NewController(t)
+	testDB := dbhandler.NewMockDBHandler(ctrl)
+	testDB.EXPECT().GetUserRevision(gomock.Any(), "test@test.pl", int64(9)).Return(models.UserRevision{}, dbhandler.ErrNotFound)
+	testObj := NewUserHandler(logrus.New(), testDB, queueHandler.NewMockQueueHandler(ctrl), secutiry.NewAuthObj([]byte("test")))
+	testObj.RevertUser(ctx)
+	assert.Equal(t, w.Code, http.StatusNotFound)
+}
@@ -0,0 +1,228 @@
+package user
+
+import (
+	"UserStorage/dbhandler"
+	"UserStorage/models"
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+const (
+	importBatchSize = 500
+	maxImportRows   = 10000
+	maxImportLine   = 1 << 20
+)
+
+type importRow struct {
+	req models.NewUserRequest
+	err error
+}
+
+func (uh *UserHandler) ImportUsers(c *gin.Context) {
+	dryRun, _ := strconv.ParseBool(c.Query("dryRun"))
+	var rows []importRow
+	var err error
+	switch c.ContentType() {
+	case contentTypeCSV:
+		rows, err = readCSVRows(c.Request.Body)
+	case contentTypeNDJSON:
+		rows, err = readNDJSONRows(c.Request.Body)
+	default:
+		c.JSON(http.StatusUnsupportedMediaType, gin.H{"error": "supported formats are " + contentTypeNDJSON + " and " + contentTypeCSV})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	report := models.ImportReport{DryRun: dryRun, Results: make([]models.ImportResult, len(rows))}
+	seen := map[string]bool{}
+	var batch []models.User
+	var batchRows []int
+	var validRows []int
+	for i, row := range rows {
+		result := &report.Results[i]
+		result.Row = i + 1
+		result.Email = row.req.Email
+		if row.err != nil {
+			result.Status, result.Error = models.ImportFailed, row.err.Error()
+			continue
+		}
+		user, err := uh.validateNewUser(row.req, false)
+		if err == nil && seen[user.Email] {
+			err = errors.New("duplicate email in import")
+		}
+		if err != nil {
+			result.Status, result.Error = models.ImportFailed, err.Error()
+			continue
+		}
+		seen[user.Email] = true
+		if dryRun {
+			result.Status = models.ImportValid
+			validRows = append(validRows, i)
+			continue
+		}
+		user.Password, err = uh.hasher.Hash(row.req.Password)
+		if err != nil {
+			uh.logger.Error(err)
+			result.Status, result.Error = models.ImportFailed, err.Error()
+			continue
+		}
+		batch = append(batch, user)
+		batchRows = append(batchRows, i)
+		if len(batch) == importBatchSize {
+			uh.insertBatch(c, batch, batchRows, report.Results)
+			batch, batchRows = nil, nil
+		}
+	}
+	uh.insertBatch(c, batch, batchRows, report.Results)
+	if dryRun {
+		uh.markExisting(c, validRows, report.Results)
+	}
+
+	for _, result := range report.Results {
+		if result.Status == models.ImportFailed {
+			report.Failed++
+		} else {
+			report.Succeeded++
+		}
+	}
+	c.JSON(http.StatusOK, report)
+}
+
+func (uh *UserHandler) insertBatch(c *gin.Context, users []models.User, rows []int, results []models.ImportResult) {
+	if len(users) == 0 {
+		return
+	}
+	errs, err := uh.dbHan.CreateUsers(c.Request.Context(), users)
+	if err != nil {
+		uh.logger.Error(err)
+		for _, row := range rows {
+			results[row].Status, results[row].Error = models.ImportFailed, err.Error()
+		}
+		return
+	}
+	for i, user := range users {
+		if errs[i] != nil {
+			results[rows[i]].Status, results[rows[i]].Error = models.ImportFailed, errs[i].Error()
+			continue
+		}
+		results[rows[i]].Status = models.ImportCreated
+		uh.rabbit.Publish(models.Event{EventType: "UserCreated", UserID: user.Email, Age: user.Age, NoFiles: len(user.Files)})
+		uh.audit(c, models.AuditUserCreated, user.Email, nil, &user)
+	}
+}
+
+func (uh *UserHandler) markExisting(c *gin.Context, rows []int, results []models.ImportResult) {
+	if len(rows) == 0 {
+		return
+	}
+	emails := make([]string, len(rows))
+	for i, row := range rows {
+		emails[i] = results[row].Email
+	}
+	existing, err := uh.dbHan.ExistingUserIDs(c.Request.Context(), emails)
+	if err != nil {
+		uh.logger.Error(err)
+		for _, row := range rows {
+			results[row].Status, results[row].Error = models.ImportFailed, err.Error()
+		}
+		return
+	}
+	found := map[string]bool{}
+	for _, id := range existing {
+		found[id] = true
+	}
+	for _, row := range rows {
+		if found[results[row].Email] {
+			results[row].Status, results[row].Error = models.ImportFailed, dbhandler.ErrDuplicate.Error()
+		}
+	}
+}
+
+func readNDJSONRows(r io.Reader) ([]importRow, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), maxImportLine)
+	var rows []importRow
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		if len(rows) == maxImportRows {
+			return nil, fmt.Errorf("import is limited to %d rows", maxImportRows)
+		}
+		var row importRow
+		if err := json.Unmarshal([]byte(line), &row.req); err != nil {
+			row.err = err
+		}
+		rows = append(rows, row)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return rows, nil
+}
+
+func readCSVRows(r io.Reader) ([]importRow, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("reading csv header: %w", err)
+	}
+	columns := map[string]int{}
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	for _, required := range []string{"email", "age", "password"} {
+		if _, ok := columns[required]; !ok {
+			return nil, fmt.Errorf("csv header is missing column %q", required)
+		}
+	}
+	field := func(record []string, name string) string {
+		i, ok := columns[name]
+		if !ok || i >= len(record) {
+			return ""
+		}
+		return strings.TrimSpace(record[i])
+	}
+	var rows []importRow
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if len(rows) == maxImportRows {
+			return nil, fmt.Errorf("import is limited to %d rows", maxImportRows)
+		}
+		var row importRow
+		if err != nil {
+			var parseErr *csv.ParseError
+			if !errors.As(err, &parseErr) {
+				return nil, err
+			}
+			row.err = err
+			rows = append(rows, row)
+			continue
+		}
+		row.req.Email = field(record, "email")
+		row.req.Username = field(record, "username")
+		row.req.Role = field(record, "role")
+		row.req.Password = field(record, "password")
+		if row.req.Age, err = strconv.Atoi(field(record, "age")); err != nil {
+			row.err = fmt.Errorf("invalid age %q", field(record, "age"))
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
@@ -0,0 +1,137 @@
+package user
+
+import (
+	"UserStorage/dbhandler"
+	"UserStorage/models"
+	"UserStorage/queueHandler"
+	"UserStorage/secutiry"
+	"encoding/json"
+	"github.com/gin-gonic/gin"
+	"github.com/golang/mock/gomock"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/bcrypt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func mockImport(c *gin.Context, contentType string, body string, dryRun bool) {
+	values := url.Values{}
+	if dryRun {
+		values.Set("dryRun", "true")
+	}
+	c.Request.Method = "POST"
+	c.Request.Header.Set("Content-Type", contentType)
+	c.Request.URL.RawQuery = values.Encode()
+	c.Request.Body = io.NopCloser(strings.NewReader(body))
+}
+
+func newImportHandler(testDB dbhandler.DBHandler, testMQ queueHandler.QueueHandler) *UserHandler {
+	return NewUserHandler(logrus.New(), testDB, testMQ, secutiry.NewAuthObj([]byte("test")),
+		WithPasswordHasher(secutiry.PasswordHasher{Algorithm: secutiry.AlgorithmBcrypt, BcryptCost: bcrypt.MinCost}))
+}
+
+func TestImportUsersNDJSON(t *testing.T) {
+	w := httptest.NewRecorder()
+	ctx := GetTestGinContext(w)
+	mockImport(ctx, "application/x-ndjson", strings.Join([]string{
+		`{"email":"a@test.pl","age":20,"password":"secret"}`,
+		`{"email":"b@test.pl","age":17,"password":"secret"}`,
+		`not json`,
+		``,
+		`{"email":"a@test.pl","age":30,"password":"secret"}`,
+		`{"email":"c@test.pl","age":40,"password":"secret","role":"admin"}`,
+	}, "\n"), false)
+	ctrl := gomock.NewController(t)
+	testDB := dbhandler.NewMockDBHandler(ctrl)
+	testMQ := queueHandler.NewMockQueueHandler(ctrl)
+	testDB.EXPECT().CreateUsers(gomock.Any(), gomock.Any()).DoAndReturn(func(_ any, users []models.User) ([]error, error) {
+		assert.Len(t, users, 2)
+		assert.Equal(t, users[1].Role, models.RoleAdmin)
+		assert.Equal(t, users[0].Status, models.UserStatusActive)
+		assert.NoError(t, bcrypt.CompareHashAndPassword([]byte(users[0].Password), []byte("secret")))
+		return []error{nil, dbhandler.ErrDuplicate}, nil
+	})
+	testMQ.EXPECT().Publish(models.Event{EventType: "UserCreated", UserID: "a@test.pl", Age: 20})
+	newImportHandler(testDB, testMQ).ImportUsers(ctx)
+	assert.Equal(t, w.Code, http.StatusOK)
+	var report models.ImportReport
+	err := json.NewDecoder(w.Body).Decode(&report)
+	assert.NoError(t, err)
+	assert.Equal(t, report.Succeeded, 1)
+	assert.Equal(t, report.Failed, 4)
+	statuses := make([]string, len(report.Results))
+	for i, result := range report.Results {
+		statuses[i] = result.Status
+		assert.Equal(t, result.Row, i+1)
+	}
+	assert.Equal(t, statuses, []string{models.ImportCreated, models.ImportFailed, models.ImportFailed, models.ImportFailed, models.ImportFailed})
+	assert.Equal(t, report.Results[1].Error, "User age is less than 18")
+	assert.Equal(t, report.Results[3].Error, "duplicate email in import")
+	assert.Equal(t, report.Results[4].Error, dbhandler.ErrDuplicate.Error())
+}
+
+func TestImportUsersCSVDryRun(t *testing.T) {
+	w := httptest.NewRecorder()
+	ctx := GetTestGinContext(w)
+	mockImport(ctx, "text/csv", "email,username,age,password\n"+
+		"a@test.pl,a,20,secret\n"+
+		"b@test.pl,b,old,secret\n", true)
+	ctrl := gomock.NewController(t)
+	testDB := dbhandler.NewMockDBHandler(ctrl)
+	testMQ := queueHandler.NewMockQueueHandler(ctrl)
+	testDB.EXPECT().ExistingUserIDs(gomock.Any(), []string{"a@test.pl"}).Return(nil, nil)
+	newImportHandler(testDB, testMQ).ImportUsers(ctx)
+	assert.Equal(t, w.Code, http.StatusOK)
+	var report models.ImportReport
+	err := json.NewDecoder(w.Body).Decode(&report)
+	assert.NoError(t, err)
+	assert.True(t, report.DryRun)
+	assert.Equal(t, report.Results[0].Status, models.ImportValid)
+	assert.Equal(t, report.Results[1].Status, models.ImportFailed)
+	assert.Equal(t, report.Results[1].Error, `invalid age "old"`)
+}
+
+func TestImportUsersDryRunExistingUsers(t *testing.T) {
+	w := httptest.NewRecorder()
+	ctx := GetTestGinContext(w)
+	mockImport(ctx, "application/x-ndjson", `{"email":"a@test.pl","age":20,"password":"secret"}
+{"email":"b@test.pl","age":20,"password":"secret"}
+{"email":"c@test.pl","age":20,"password":"secret"}
+`, true)
+	ctrl := gomock.NewController(t)
+	testDB := dbhandler.NewMockDBHandler(ctrl)
+	testMQ := queueHandler.NewMockQueueHandler(ctrl)
+	testDB.EXPECT().ExistingUserIDs(gomock.Any(), []string{"a@test.pl", "b@test.pl", "c@test.pl"}).Return([]string{"b@test.pl"}, nil)
+	newImportHandler(testDB, testMQ).ImportUsers(ctx)
+	assert.Equal(t, w.Code, http.StatusOK)
+	var report models.ImportReport
+	err := json.NewDecoder(w.Body).Decode(&report)
+	assert.NoError(t, err)
+	assert.Equal(t, report.Succeeded, 2)
+	assert.Equal(t, report.Failed, 1)
+	assert.Equal(t, report.Results[1].Status, models.ImportFailed)
+	assert.Equal(t, report.Results[1].Error, dbhandler.ErrDuplicate.Error())
+}
+
+func TestImportUsersCSVMissingColumn(t *testing.T) {
+	w := httptest.NewRecorder()
+	ctx := GetTestGinContext(w)
+	mockImport(ctx, "text/csv", "email,username\na@test.pl,a\n", false)
+	ctrl := gomock.NewController(t)
+	newImportHandler(dbhandler.NewMockDBHandler(ctrl), queueHandler.NewMockQueueHandler(ctrl)).ImportUsers(ctx)
+	assert.Equal(t, w.Code, http.StatusBadRequest)
+}
+
+func TestImportUsersUnsupportedType(t *testing.T) {
+	w := httptest.NewRecorder()
+	ctx := GetTestGinContext(w)
+	mockImport(ctx, "application/json", "[]", false)
+	ctrl := gomock.NewController(t)
+	newImportHandler(dbhandler.NewMockDBHandler(ctrl), queueHandler.NewMockQueueHandler(ctrl)).ImportUsers(ctx)
+	assert.Equal(t, w.Code, http.StatusUnsupportedMediaType)
+}
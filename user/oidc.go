@@ -0,0 +1,167 @@
+package user
+
+import (
+	"UserStorage/dbhandler"
+	"UserStorage/models"
+	"UserStorage/oidc"
+	"UserStorage/secutiry"
+	"errors"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"time"
+)
+
+func (uh *UserHandler) OIDCLogin(c *gin.Context) {
+	if uh.oidc == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "oidc login is not configured"})
+		return
+	}
+	state, err := secutiry.NewOpaqueToken()
+	if err != nil {
+		uh.logger.Error(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	nonce, err := secutiry.NewOpaqueToken()
+	if err != nil {
+		uh.logger.Error(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	verifier, challenge, err := oidc.NewPKCE()
+	if err != nil {
+		uh.logger.Error(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	err = uh.dbHan.SaveToken(c.Request.Context(), models.Token{
+		ID:        secutiry.HashToken(state),
+		Kind:      models.TokenOIDCState,
+		ExpiresAt: time.Now().Add(secutiry.OIDCStateTTL),
+		Data:      map[string]string{"nonce": nonce, "verifier": verifier},
+	})
+	if err != nil {
+		uh.logger.Error(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.Redirect(http.StatusFound, uh.oidc.AuthCodeURL(state, nonce, challenge))
+}
+
+func (uh *UserHandler) OIDCCallback(c *gin.Context) {
+	if uh.oidc == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "oidc login is not configured"})
+		return
+	}
+	if errCode := c.Query("error"); errCode != "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "oidc login failed: " + errCode})
+		return
+	}
+	code, state := c.Query("code"), c.Query("state")
+	if code == "" || state == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "code and state are required"})
+		return
+	}
+	stored, err := uh.consumeToken(c.Request.Context(), state, models.TokenOIDCState)
+	if errors.Is(err, dbhandler.ErrNotFound) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid state"})
+		return
+	}
+	if err != nil {
+		uh.logger.Error(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	idToken, err := uh.oidc.Exchange(c.Request.Context(), code, stored.Data["verifier"])
+	if err != nil {
+		uh.logger.Error(err)
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "oidc login failed"})
+		return
+	}
+	claims, err := uh.oidc.Verify(c.Request.Context(), idToken, stored.Data["nonce"])
+	if err != nil {
+		uh.logger.Error(err)
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "oidc login failed"})
+		return
+	}
+	user, ok := uh.federatedUser(c, claims)
+	if !ok {
+		return
+	}
+	if user.Lockout.LockedUntil.After(time.Now()) {
+		retryAfter(c, user.Lockout.LockedUntil, time.Now())
+		c.JSON(http.StatusLocked, gin.H{"error": "account locked"})
+		return
+	}
+	if user.Status == models.UserStatusPending {
+		c.JSON(http.StatusForbidden, gin.H{"error": "account not verified"})
+		return
+	}
+	if user.TOTP.Enabled {
+		uh.mfaChallenge(c, user)
+		return
+	}
+	if mfaEnrollmentRequired(user) {
+		uh.mfaEnrollment(c, user)
+		return
+	}
+	uh.issueTokens(c, user, "")
+}
+
+func (uh *UserHandler) federatedUser(c *gin.Context, claims *oidc.IDTokenClaims) (models.User, bool) {
+	identity := models.Identity{Issuer: uh.oidc.Issuer(), Subject: claims.Subject}
+	user, err := uh.dbHan.GetUserByIdentity(c.Request.Context(), identity)
+	if err == nil {
+		return user, true
+	}
+	if !errors.Is(err, dbhandler.ErrNotFound) {
+		uh.logger.Error(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return models.User{}, false
+	}
+	if claims.Email == "" || !claims.EmailVerified {
+		c.JSON(http.StatusForbidden, gin.H{"error": "identity provider did not return a verified email"})
+		return models.User{}, false
+	}
+	user, err = uh.dbHan.GetUser(c.Request.Context(), claims.Email)
+	if err == nil {
+		if err = uh.dbHan.AddIdentity(c.Request.Context(), user.Email, identity); err != nil {
+			uh.logger.Error(err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return models.User{}, false
+		}
+		user.Identities = append(user.Identities, identity)
+		uh.rabbit.Publish(models.Event{EventType: "UserIdentityLinked", UserID: user.Email, Age: user.Age, NoFiles: len(user.Files)})
+		return user, true
+	}
+	if !errors.Is(err, dbhandler.ErrNotFound) {
+		uh.logger.Error(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return models.User{}, false
+	}
+	age, ok := claims.Age(time.Now())
+	if !ok {
+		c.JSON(http.StatusForbidden, gin.H{"error": "identity provider did not return a birthdate"})
+		return models.User{}, false
+	}
+	if age < 18 {
+		c.JSON(http.StatusForbidden, gin.H{"error": "User age is less than 18"})
+		return models.User{}, false
+	}
+	user = models.User{
+		Email:      claims.Email,
+		Username:   claims.Name,
+		Age:        age,
+		Status:     models.UserStatusActive,
+		Role:       models.RoleUser,
+		Identities: []models.Identity{identity},
+	}
+	if err = uh.dbHan.CreateUser(c.Request.Context(), user); err != nil {
+		uh.logger.Error(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return models.User{}, false
+	}
+	uh.rabbit.Publish(models.Event{EventType: "UserCreated", UserID: user.Email, Age: user.Age})
+	uh.audit(c, models.AuditUserCreated, user.Email, nil, &user)
+	return user, true
+}
@@ -0,0 +1,175 @@
+package user
+
+import (
+	"UserStorage/dbhandler"
+	"UserStorage/models"
+	"UserStorage/oidc"
+	"UserStorage/oidc/oidctest"
+	"UserStorage/queueHandler"
+	"UserStorage/secutiry"
+	"context"
+	"encoding/json"
+	"github.com/golang/mock/gomock"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+)
+
+func newOIDCProvider(t *testing.T, user oidctest.User) (*oidctest.Provider, *oidc.Client) {
+	provider := oidctest.NewProvider("userstorage", "secret")
+	t.Cleanup(provider.Close)
+	provider.SetUser(user)
+	client, err := oidc.NewClient(context.Background(), oidc.Config{
+		Issuer:       provider.Issuer(),
+		ClientID:     provider.ClientID,
+		ClientSecret: provider.ClientSecret,
+		RedirectURL:  "http://localhost/auth/oidc/callback",
+		HTTPClient:   provider.Client(),
+	})
+	assert.NoError(t, err)
+	return provider, client
+}
+
+func oidcLogin(t *testing.T, provider *oidctest.Provider, testObj *UserHandler, testDB *dbhandler.MockDBHandler) url.Values {
+	var state models.Token
+	testDB.EXPECT().SaveToken(gomock.Any(), gomock.Any()).DoAndReturn(func(_ any, token models.Token) error {
+		assert.Equal(t, token.Kind, models.TokenOIDCState)
+		state = token
+		return nil
+	})
+	w := httptest.NewRecorder()
+	ctx := GetTestGinContext(w)
+	MockJsonGet(ctx, nil, url.Values{})
+	testObj.OIDCLogin(ctx)
+	assert.Equal(t, w.Code, http.StatusFound)
+
+	httpClient := provider.Client()
+	httpClient.CheckRedirect = func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }
+	resp, err := httpClient.Get(w.Header().Get("Location"))
+	assert.NoError(t, err)
+	defer resp.Body.Close()
+	location, err := url.Parse(resp.Header.Get("Location"))
+	assert.NoError(t, err)
+	query := location.Query()
+	testDB.EXPECT().ConsumeToken(gomock.Any(), secutiry.HashToken(query.Get("state")), models.TokenOIDCState).Return(state, nil)
+	return query
+}
+
+func TestOIDCLinksExistingUser(t *testing.T) {
+	var logger = logrus.New()
+	provider, client := newOIDCProvider(t, oidctest.User{Subject: "ext-1", Email: "test@test.pl", EmailVerified: true})
+	ctrl := gomock.NewController(t)
+	testDB := dbhandler.NewMockDBHandler(ctrl)
+	testMQ := queueHandler.NewMockQueueHandler(ctrl)
+	auth := secutiry.NewAuthObj([]byte("test"))
+	testObj := NewUserHandler(logger, testDB, testMQ, auth, WithOIDC(client))
+	query := oidcLogin(t, provider, testObj, testDB)
+
+	identity := models.Identity{Issuer: provider.Issuer(), Subject: "ext-1"}
+	testDB.EXPECT().GetUserByIdentity(gomock.Any(), identity).Return(models.User{}, dbhandler.ErrNotFound)
+	testDB.EXPECT().GetUser(gomock.Any(), "test@test.pl").Return(models.User{Email: "test@test.pl", Role: models.RoleUser, Status: models.UserStatusActive}, nil)
+	testDB.EXPECT().AddIdentity(gomock.Any(), "test@test.pl", identity).Return(nil)
+	testMQ.EXPECT().Publish(models.Event{EventType: "UserIdentityLinked", UserID: "test@test.pl"})
+	testDB.EXPECT().SaveToken(gomock.Any(), gomock.Any()).Return(nil)
+	w := httptest.NewRecorder()
+	ctx := GetTestGinContext(w)
+	MockJsonGet(ctx, nil, query)
+	testObj.OIDCCallback(ctx)
+	assert.Equal(t, w.Code, http.StatusOK)
+	var out msgTokens
+	err := json.NewDecoder(w.Body).Decode(&out)
+	assert.NoError(t, err)
+	claims, err := auth.ValidateToken(out.Token)
+	assert.NoError(t, err)
+	assert.Equal(t, claims.Subject, "test@test.pl")
+}
+
+func TestOIDCCreatesUser(t *testing.T) {
+	var logger = logrus.New()
+	provider, client := newOIDCProvider(t, oidctest.User{Subject: "ext-2", Email: "new@test.pl", EmailVerified: true, Name: "New", Birthdate: "1990-01-01"})
+	ctrl := gomock.NewController(t)
+	testDB := dbhandler.NewMockDBHandler(ctrl)
+	testMQ := queueHandler.NewMockQueueHandler(ctrl)
+	auth := secutiry.NewAuthObj([]byte("test"))
+	testObj := NewUserHandler(logger, testDB, testMQ, auth, WithOIDC(client))
+	query := oidcLogin(t, provider, testObj, testDB)
+
+	testDB.EXPECT().GetUserByIdentity(gomock.Any(), gomock.Any()).Return(models.User{}, dbhandler.ErrNotFound)
+	testDB.EXPECT().GetUser(gomock.Any(), "new@test.pl").Return(models.User{}, dbhandler.ErrNotFound)
+	age, _ := oidc.IDTokenClaims{Birthdate: "1990-01-01"}.Age(time.Now())
+	testDB.EXPECT().CreateUser(gomock.Any(), gomock.Any()).DoAndReturn(func(_ any, usr models.User) error {
+		assert.Equal(t, usr.Username, "New")
+		assert.Equal(t, usr.Age, age)
+		assert.Equal(t, usr.Status, models.UserStatusActive)
+		assert.Equal(t, usr.Identities, []models.Identity{{Issuer: provider.Issuer(), Subject: "ext-2"}})
+		assert.Empty(t, usr.Password)
+		return nil
+	})
+	testMQ.EXPECT().Publish(models.Event{EventType: "UserCreated", UserID: "new@test.pl", Age: age})
+	testDB.EXPECT().SaveToken(gomock.Any(), gomock.Any()).Return(nil)
+	w := httptest.NewRecorder()
+	ctx := GetTestGinContext(w)
+	MockJsonGet(ctx, nil, query)
+	testObj.OIDCCallback(ctx)
+	assert.Equal(t, w.Code, http.StatusOK)
+}
+
+func TestOIDCRequiresAdultBirthdate(t *testing.T) {
+	minor := time.Now().AddDate(-17, 0, 0).Format(time.DateOnly)
+	for _, birthdate := range []string{"", minor} {
+		var logger = logrus.New()
+		provider, client := newOIDCProvider(t, oidctest.User{Subject: "ext-4", Email: "new@test.pl", EmailVerified: true, Birthdate: birthdate})
+		ctrl := gomock.NewController(t)
+		testDB := dbhandler.NewMockDBHandler(ctrl)
+		testMQ := queueHandler.NewMockQueueHandler(ctrl)
+		auth := secutiry.NewAuthObj([]byte("test"))
+		testObj := NewUserHandler(logger, testDB, testMQ, auth, WithOIDC(client))
+		query := oidcLogin(t, provider, testObj, testDB)
+
+		testDB.EXPECT().GetUserByIdentity(gomock.Any(), gomock.Any()).Return(models.User{}, dbhandler.ErrNotFound)
+		testDB.EXPECT().GetUser(gomock.Any(), "new@test.pl").Return(models.User{}, dbhandler.ErrNotFound)
+		w := httptest.NewRecorder()
+		ctx := GetTestGinContext(w)
+		MockJsonGet(ctx, nil, query)
+		testObj.OIDCCallback(ctx)
+		assert.Equal(t, w.Code, http.StatusForbidden)
+	}
+}
+
+func TestOIDCUnverifiedEmail(t *testing.T) {
+	var logger = logrus.New()
+	provider, client := newOIDCProvider(t, oidctest.User{Subject: "ext-3", Email: "test@test.pl"})
+	ctrl := gomock.NewController(t)
+	testDB := dbhandler.NewMockDBHandler(ctrl)
+	testMQ := queueHandler.NewMockQueueHandler(ctrl)
+	auth := secutiry.NewAuthObj([]byte("test"))
+	testObj := NewUserHandler(logger, testDB, testMQ, auth, WithOIDC(client))
+	query := oidcLogin(t, provider, testObj, testDB)
+
+	testDB.EXPECT().GetUserByIdentity(gomock.Any(), gomock.Any()).Return(models.User{}, dbhandler.ErrNotFound)
+	w := httptest.NewRecorder()
+	ctx := GetTestGinContext(w)
+	MockJsonGet(ctx, nil, query)
+	testObj.OIDCCallback(ctx)
+	assert.Equal(t, w.Code, http.StatusForbidden)
+}
+
+func TestOIDCInvalidState(t *testing.T) {
+	var logger = logrus.New()
+	_, client := newOIDCProvider(t, oidctest.User{})
+	ctrl := gomock.NewController(t)
+	testDB := dbhandler.NewMockDBHandler(ctrl)
+	testMQ := queueHandler.NewMockQueueHandler(ctrl)
+	auth := secutiry.NewAuthObj([]byte("test"))
+	testObj := NewUserHandler(logger, testDB, testMQ, auth, WithOIDC(client))
+	testDB.EXPECT().ConsumeToken(gomock.Any(), secutiry.HashToken("forged"), models.TokenOIDCState).Return(models.Token{}, dbhandler.ErrNotFound)
+	w := httptest.NewRecorder()
+	ctx := GetTestGinContext(w)
+	MockJsonGet(ctx, nil, url.Values{"code": {"code"}, "state": {"forged"}})
+	testObj.OIDCCallback(ctx)
+	assert.Equal(t, w.Code, http.StatusBadRequest)
+}
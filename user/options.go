@@ -0,0 +1,48 @@
+package user
+
+import (
+	"UserStorage/dbhandler"
+	"UserStorage/oidc"
+	"UserStorage/secutiry"
+	"UserStorage/storage"
+)
+
+type Option func(*UserHandler)
+
+func WithLockoutPolicy(account secutiry.LockoutPolicy, ip secutiry.LockoutPolicy) Option {
+	return func(uh *UserHandler) {
+		uh.accountLockout = account
+		uh.ipAttempts = secutiry.NewAttemptTracker(ip)
+	}
+}
+
+func WithPasswordPolicy(policy secutiry.PasswordPolicy) Option {
+	return func(uh *UserHandler) {
+		uh.passwordPolicy = policy
+	}
+}
+
+func WithPasswordHasher(hasher secutiry.PasswordHasher) Option {
+	return func(uh *UserHandler) {
+		uh.hasher = hasher
+	}
+}
+
+func WithOIDC(client *oidc.Client) Option {
+	return func(uh *UserHandler) {
+		uh.oidc = client
+	}
+}
+
+func WithAuditLog(store dbhandler.AuditStore) Option {
+	return func(uh *UserHandler) {
+		uh.auditLog = store
+	}
+}
+
+func WithBlobStore(store storage.BlobStore, maxUploadSize int64) Option {
+	return func(uh *UserHandler) {
+		uh.blobs = store
+		uh.maxUploadSize = maxUploadSize
+	}
+}
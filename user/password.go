@@ -0,0 +1,157 @@
+package user
+
+import (
+	"UserStorage/dbhandler"
+	"UserStorage/models"
+	"UserStorage/secutiry"
+	"errors"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"time"
+)
+
+func (uh *UserHandler) ChangePassword(c *gin.Context) {
+	id := c.Param("id")
+	var req models.ChangePasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	now := time.Now()
+	ip := c.ClientIP()
+	if until, locked := uh.ipAttempts.LockedUntil(ip, now); locked {
+		retryAfter(c, until, now)
+		c.JSON(http.StatusTooManyRequests, gin.H{"error": "too many failed login attempts"})
+		return
+	}
+	user, err := uh.dbHan.GetUser(c.Request.Context(), id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		uh.logger.Error(err)
+		return
+	}
+	if user.Lockout.LockedUntil.After(now) {
+		retryAfter(c, user.Lockout.LockedUntil, now)
+		c.JSON(http.StatusLocked, gin.H{"error": "account locked"})
+		return
+	}
+	ok, _, err := uh.hasher.Verify(user.Password, req.OldPassword)
+	if err != nil {
+		uh.logger.Error(err)
+	}
+	if !ok {
+		uh.loginFailedFromIP(ip, id, now)
+		uh.loginFailed(c, user, ip, now)
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "wrong password"})
+		return
+	}
+	if user.Lockout != (models.Lockout{}) {
+		if err = uh.dbHan.SetLockout(c.Request.Context(), id, models.Lockout{}); err != nil {
+			uh.logger.Error(err)
+		}
+	}
+	hashed, ok := uh.hashPassword(c, req.NewPassword)
+	if !ok || !uh.storePassword(c, id, hashed) {
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "password changed"})
+}
+
+func (uh *UserHandler) ForgotPassword(c *gin.Context) {
+	var req models.ForgotPasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	accepted := gin.H{"message": "if the account exists a reset token has been sent"}
+	user, err := uh.dbHan.GetUser(c.Request.Context(), req.Email)
+	if err == nil && user.Kind == models.UserKindService {
+		err = errors.New("service accounts have no password")
+	}
+	if err != nil {
+		uh.logger.Error(err)
+		c.JSON(http.StatusAccepted, accepted)
+		return
+	}
+	if err = uh.dbHan.DeleteTokens(c.Request.Context(), user.Email, models.TokenPasswordReset); err != nil {
+		uh.logger.Error(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	token, expiresAt, err := uh.saveToken(c.Request.Context(), models.TokenPasswordReset, user.Email, secutiry.PasswordResetTokenTTL)
+	if err != nil {
+		uh.logger.Error(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	uh.rabbit.Publish(models.TokenEvent{EventType: "PasswordResetRequested", UserID: user.Email, Token: token, ExpiresAt: expiresAt})
+	c.JSON(http.StatusAccepted, accepted)
+}
+
+func (uh *UserHandler) ResetPassword(c *gin.Context) {
+	var req models.ResetPasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	hashed, ok := uh.hashPassword(c, req.NewPassword)
+	if !ok {
+		return
+	}
+	token, err := uh.consumeToken(c.Request.Context(), req.Token, models.TokenPasswordReset)
+	if errors.Is(err, dbhandler.ErrNotFound) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid reset token"})
+		return
+	}
+	if err != nil {
+		uh.logger.Error(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if !uh.storePassword(c, token.UserID, hashed) {
+		return
+	}
+	if err = uh.auth.RevokeUser(c.Request.Context(), token.UserID); err != nil {
+		uh.logger.Error(err)
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "password reset"})
+}
+
+func (uh *UserHandler) validatePassword(password string) error {
+	if err := uh.passwordPolicy.Validate(password); err != nil {
+		return err
+	}
+	return uh.hasher.Validate(password)
+}
+
+func (uh *UserHandler) hashPassword(c *gin.Context, password string) (string, bool) {
+	if err := uh.validatePassword(password); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return "", false
+	}
+	hashed, err := uh.hasher.Hash(password)
+	if err != nil {
+		uh.logger.Error(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return "", false
+	}
+	return hashed, true
+}
+
+func (uh *UserHandler) storePassword(c *gin.Context, id string, hashed string) bool {
+	err := uh.dbHan.UpdatePassword(c.Request.Context(), id, hashed)
+	if errors.Is(err, dbhandler.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return false
+	}
+	if err != nil {
+		uh.logger.Error(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return false
+	}
+	if err = uh.dbHan.DeleteTokens(c.Request.Context(), id, models.TokenRefresh); err != nil {
+		uh.logger.Error(err)
+	}
+	uh.rabbit.Publish(models.Event{EventType: "PasswordChanged", UserID: id})
+	return true
+}
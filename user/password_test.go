@@ -0,0 +1,207 @@
+package user
+
+import (
+	"UserStorage/dbhandler"
+	"UserStorage/models"
+	"UserStorage/queueHandler"
+	"UserStorage/secutiry"
+	"github.com/golang/mock/gomock"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/bcrypt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestChangePassword(t *testing.T) {
+	w := httptest.NewRecorder()
+	ctx := GetTestGinContext(w)
+	var logger = logrus.New()
+	MockJsonPost(ctx, models.ChangePasswordRequest{OldPassword: "old", NewPassword: "new"}, "test@test.pl")
+	ctrl := gomock.NewController(t)
+	testDB := dbhandler.NewMockDBHandler(ctrl)
+	testMQ := queueHandler.NewMockQueueHandler(ctrl)
+	auth := secutiry.NewAuthObj([]byte("test"))
+	hash, err := bcrypt.GenerateFromPassword([]byte("old"), bcrypt.DefaultCost)
+	assert.NoError(t, err)
+	testDB.EXPECT().GetUser(gomock.Any(), "test@test.pl").Return(models.User{Email: "test@test.pl", Password: string(hash)}, nil)
+	testDB.EXPECT().UpdatePassword(gomock.Any(), "test@test.pl", gomock.Any()).DoAndReturn(func(_ any, _ string, password string) error {
+		assert.NoError(t, bcrypt.CompareHashAndPassword([]byte(password), []byte("new")))
+		return nil
+	})
+	testDB.EXPECT().DeleteTokens(gomock.Any(), "test@test.pl", models.TokenRefresh).Return(nil)
+	testMQ.EXPECT().Publish(gomock.Any())
+	testObj := NewUserHandler(logger, testDB, testMQ, auth)
+	testObj.ChangePassword(ctx)
+	assert.Equal(t, w.Code, http.StatusOK)
+}
+
+func TestChangePasswordWrongOld(t *testing.T) {
+	w := httptest.NewRecorder()
+	ctx := GetTestGinContext(w)
+	var logger = logrus.New()
+	MockJsonPost(ctx, models.ChangePasswordRequest{OldPassword: "wrong", NewPassword: "new"}, "test@test.pl")
+	ctrl := gomock.NewController(t)
+	testDB := dbhandler.NewMockDBHandler(ctrl)
+	testMQ := queueHandler.NewMockQueueHandler(ctrl)
+	auth := secutiry.NewAuthObj([]byte("test"))
+	hash, err := bcrypt.GenerateFromPassword([]byte("old"), bcrypt.DefaultCost)
+	assert.NoError(t, err)
+	testDB.EXPECT().GetUser(gomock.Any(), "test@test.pl").Return(models.User{Email: "test@test.pl", Password: string(hash)}, nil)
+	testDB.EXPECT().RecordFailedLogin(gomock.Any(), "test@test.pl").Return(models.Lockout{FailedAttempts: 1}, nil)
+	testObj := NewUserHandler(logger, testDB, testMQ, auth)
+	testObj.ChangePassword(ctx)
+	assert.Equal(t, w.Code, http.StatusUnauthorized)
+}
+
+func TestChangePasswordLockedAccount(t *testing.T) {
+	w := httptest.NewRecorder()
+	ctx := GetTestGinContext(w)
+	var logger = logrus.New()
+	MockJsonPost(ctx, models.ChangePasswordRequest{OldPassword: "old", NewPassword: "new"}, "test@test.pl")
+	ctrl := gomock.NewController(t)
+	testDB := dbhandler.NewMockDBHandler(ctrl)
+	testMQ := queueHandler.NewMockQueueHandler(ctrl)
+	auth := secutiry.NewAuthObj([]byte("test"))
+	testDB.EXPECT().GetUser(gomock.Any(), "test@test.pl").Return(models.User{
+		Email:   "test@test.pl",
+		Lockout: models.Lockout{FailedAttempts: 5, Count: 1, LockedUntil: time.Now().Add(time.Minute)},
+	}, nil)
+	testObj := NewUserHandler(logger, testDB, testMQ, auth)
+	testObj.ChangePassword(ctx)
+	assert.Equal(t, w.Code, http.StatusLocked)
+	assert.NotEmpty(t, w.Header().Get("Retry-After"))
+}
+
+func TestForgotPassword(t *testing.T) {
+	w := httptest.NewRecorder()
+	ctx := GetTestGinContext(w)
+	var logger = logrus.New()
+	MockJsonPost(ctx, models.ForgotPasswordRequest{Email: "test@test.pl"}, "")
+	ctrl := gomock.NewController(t)
+	testDB := dbhandler.NewMockDBHandler(ctrl)
+	testMQ := queueHandler.NewMockQueueHandler(ctrl)
+	auth := secutiry.NewAuthObj([]byte("test"))
+	testDB.EXPECT().GetUser(gomock.Any(), "test@test.pl").Return(models.User{Email: "test@test.pl"}, nil)
+	testDB.EXPECT().DeleteTokens(gomock.Any(), "test@test.pl", models.TokenPasswordReset).Return(nil)
+	var saved models.Token
+	testDB.EXPECT().SaveToken(gomock.Any(), gomock.Any()).DoAndReturn(func(_ any, token models.Token) error {
+		saved = token
+		return nil
+	})
+	testMQ.EXPECT().Publish(gomock.AssignableToTypeOf(models.TokenEvent{})).Do(func(ev any) {
+		event := ev.(models.TokenEvent)
+		assert.Equal(t, event.EventType, "PasswordResetRequested")
+		assert.Equal(t, secutiry.HashToken(event.Token), saved.ID)
+	})
+	testObj := NewUserHandler(logger, testDB, testMQ, auth)
+	testObj.ForgotPassword(ctx)
+	assert.Equal(t, w.Code, http.StatusAccepted)
+	assert.Equal(t, saved.Kind, models.TokenPasswordReset)
+}
+
+func TestForgotPasswordUnknownUser(t *testing.T) {
+	w := httptest.NewRecorder()
+	ctx := GetTestGinContext(w)
+	var logger = logrus.New()
+	MockJsonPost(ctx, models.ForgotPasswordRequest{Email: "nobody@test.pl"}, "")
+	ctrl := gomock.NewController(t)
+	testDB := dbhandler.NewMockDBHandler(ctrl)
+	testMQ := queueHandler.NewMockQueueHandler(ctrl)
+	auth := secutiry.NewAuthObj([]byte("test"))
+	testDB.EXPECT().GetUser(gomock.Any(), "nobody@test.pl").Return(models.User{}, dbhandler.ErrNotFound)
+	testObj := NewUserHandler(logger, testDB, testMQ, auth)
+	testObj.ForgotPassword(ctx)
+	assert.Equal(t, w.Code, http.StatusAccepted)
+}
+
+func TestResetPassword(t *testing.T) {
+	w := httptest.NewRecorder()
+	ctx := GetTestGinContext(w)
+	var logger = logrus.New()
+	MockJsonPost(ctx, models.ResetPasswordRequest{Token: "reset", NewPassword: "new"}, "")
+	ctrl := gomock.NewController(t)
+	testDB := dbhandler.NewMockDBHandler(ctrl)
+	testMQ := queueHandler.NewMockQueueHandler(ctrl)
+	store := dbhandler.NewMemoryRevocationStore()
+	auth := secutiry.NewAuthObj([]byte("test"), secutiry.WithRevocationStore(store))
+	testDB.EXPECT().ConsumeToken(gomock.Any(), secutiry.HashToken("reset"), models.TokenPasswordReset).Return(models.Token{
+		UserID:    "test@test.pl",
+		ExpiresAt: time.Now().Add(time.Minute),
+	}, nil)
+	testDB.EXPECT().UpdatePassword(gomock.Any(), "test@test.pl", gomock.Any()).Return(nil)
+	testDB.EXPECT().DeleteTokens(gomock.Any(), "test@test.pl", models.TokenRefresh).Return(nil)
+	testMQ.EXPECT().Publish(gomock.Any())
+	testObj := NewUserHandler(logger, testDB, testMQ, auth)
+	testObj.ResetPassword(ctx)
+	assert.Equal(t, w.Code, http.StatusOK)
+	revoked, err := store.IsRevoked(ctx, "", secutiry.HashToken("test@test.pl"), time.Now().Add(-time.Minute))
+	assert.NoError(t, err)
+	assert.True(t, revoked)
+}
+
+func TestResetPasswordExpired(t *testing.T) {
+	w := httptest.NewRecorder()
+	ctx := GetTestGinContext(w)
+	var logger = logrus.New()
+	MockJsonPost(ctx, models.ResetPasswordRequest{Token: "reset", NewPassword: "new"}, "")
+	ctrl := gomock.NewController(t)
+	testDB := dbhandler.NewMockDBHandler(ctrl)
+	testMQ := queueHandler.NewMockQueueHandler(ctrl)
+	auth := secutiry.NewAuthObj([]byte("test"))
+	testDB.EXPECT().ConsumeToken(gomock.Any(), gomock.Any(), gomock.Any()).Return(models.Token{
+		UserID:    "test@test.pl",
+		ExpiresAt: time.Now().Add(-time.Minute),
+	}, nil)
+	testObj := NewUserHandler(logger, testDB, testMQ, auth)
+	testObj.ResetPassword(ctx)
+	assert.Equal(t, w.Code, http.StatusBadRequest)
+}
+
+func TestChangePasswordPolicy(t *testing.T) {
+	w := httptest.NewRecorder()
+	ctx := GetTestGinContext(w)
+	var logger = logrus.New()
+	MockJsonPost(ctx, models.ChangePasswordRequest{OldPassword: "old", NewPassword: "short"}, "test@test.pl")
+	ctrl := gomock.NewController(t)
+	testDB := dbhandler.NewMockDBHandler(ctrl)
+	testMQ := queueHandler.NewMockQueueHandler(ctrl)
+	auth := secutiry.NewAuthObj([]byte("test"))
+	hash, err := bcrypt.GenerateFromPassword([]byte("old"), bcrypt.DefaultCost)
+	assert.NoError(t, err)
+	testDB.EXPECT().GetUser(gomock.Any(), "test@test.pl").Return(models.User{Email: "test@test.pl", Password: string(hash)}, nil)
+	testObj := NewUserHandler(logger, testDB, testMQ, auth, WithPasswordPolicy(secutiry.PasswordPolicy{MinLength: 8}))
+	testObj.ChangePassword(ctx)
+	assert.Equal(t, w.Code, http.StatusBadRequest)
+}
+
+func TestResetPasswordPolicyKeepsToken(t *testing.T) {
+	w := httptest.NewRecorder()
+	ctx := GetTestGinContext(w)
+	var logger = logrus.New()
+	MockJsonPost(ctx, models.ResetPasswordRequest{Token: "reset", NewPassword: "short"}, "")
+	ctrl := gomock.NewController(t)
+	testDB := dbhandler.NewMockDBHandler(ctrl)
+	testMQ := queueHandler.NewMockQueueHandler(ctrl)
+	auth := secutiry.NewAuthObj([]byte("test"))
+	testObj := NewUserHandler(logger, testDB, testMQ, auth, WithPasswordPolicy(secutiry.PasswordPolicy{MinLength: 8}))
+	testObj.ResetPassword(ctx)
+	assert.Equal(t, w.Code, http.StatusBadRequest)
+}
+
+func TestResetPasswordTooLong(t *testing.T) {
+	w := httptest.NewRecorder()
+	ctx := GetTestGinContext(w)
+	var logger = logrus.New()
+	MockJsonPost(ctx, models.ResetPasswordRequest{Token: "reset", NewPassword: strings.Repeat("a", 100)}, "")
+	ctrl := gomock.NewController(t)
+	testDB := dbhandler.NewMockDBHandler(ctrl)
+	testMQ := queueHandler.NewMockQueueHandler(ctrl)
+	auth := secutiry.NewAuthObj([]byte("test"))
+	testObj := NewUserHandler(logger, testDB, testMQ, auth)
+	testObj.ResetPassword(ctx)
+	assert.Equal(t, w.Code, http.StatusBadRequest)
+}
@@ -0,0 +1,81 @@
+package user
+
+import (
+	"UserStorage/models"
+	"UserStorage/patch"
+	"UserStorage/secutiry"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"github.com/gin-gonic/gin"
+	"io"
+	"net/http"
+)
+
+const (
+	contentTypeMergePatch = "application/merge-patch+json"
+	contentTypeJSONPatch  = "application/json-patch+json"
+)
+
+func (uh *UserHandler) PatchUser(c *gin.Context) {
+	id := c.Param("id")
+	contentType := c.ContentType()
+	if contentType != contentTypeMergePatch && contentType != contentTypeJSONPatch {
+		c.Header("Accept-Patch", contentTypeMergePatch+", "+contentTypeJSONPatch)
+		c.JSON(http.StatusUnsupportedMediaType, gin.H{"error": "unsupported patch content type"})
+		return
+	}
+	body, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	existing, ok := uh.loadUser(c, id)
+	if !ok || !uh.checkPreconditions(c, existing) {
+		return
+	}
+	doc, err := json.Marshal(models.UserUpdate{Username: existing.Username, Age: existing.Age, Role: existing.Role})
+	if err != nil {
+		uh.logger.Error(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	var patched []byte
+	if contentType == contentTypeMergePatch {
+		patched, err = patch.MergePatch(doc, body)
+	} else {
+		var ops []patch.Operation
+		if err = json.Unmarshal(body, &ops); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		patched, err = patch.ApplyJSONPatch(doc, ops)
+	}
+	if errors.Is(err, patch.ErrTestFailed) {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var upd models.UserUpdate
+	dec := json.NewDecoder(bytes.NewReader(patched))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&upd); err != nil {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+		return
+	}
+	if upd.Role != existing.Role && !secutiry.IsAdmin(c) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "role can only be changed by an admin"})
+		return
+	}
+	updUsr, err := replaceUser(existing, upd)
+	if err != nil {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+		return
+	}
+	uh.saveUser(c, existing, updUsr)
+}
@@ -0,0 +1,98 @@
+package user
+
+import (
+	"UserStorage/dbhandler"
+	"UserStorage/models"
+	"UserStorage/queueHandler"
+	"UserStorage/secutiry"
+	"bytes"
+	"github.com/gin-gonic/gin"
+	"github.com/golang/mock/gomock"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func mockPatch(c *gin.Context, id string, contentType string, body string) {
+	c.Request.Method = "PATCH"
+	c.Request.Header.Set("Content-Type", contentType)
+	c.Params = gin.Params{gin.Param{Key: "id", Value: id}}
+	c.Request.Body = io.NopCloser(bytes.NewBufferString(body))
+}
+
+func TestPatchUserMerge(t *testing.T) {
+	w := httptest.NewRecorder()
+	ctx := GetTestGinContext(w)
+	mockPatch(ctx, "test@test.pl", contentTypeMergePatch, `{"username":"new"}`)
+	ctx.Set(secutiry.ContextRole, models.RoleUser)
+	ctrl := gomock.NewController(t)
+	testDB := dbhandler.NewMockDBHandler(ctrl)
+	testMQ := queueHandler.NewMockQueueHandler(ctrl)
+	existing := models.User{Email: "test@test.pl", Username: "old", Age: 30, Password: "hash", Role: models.RoleUser,
+		Status: models.UserStatusActive, Files: []models.File{{Name: "a"}}, TOTP: models.TOTP{Enabled: true}}
+	testDB.EXPECT().GetUser(gomock.Any(), "test@test.pl").Return(existing, nil)
+	testDB.EXPECT().UpdateUser(gomock.Any(), gomock.Any()).DoAndReturn(func(_ any, usr models.User) error {
+		assert.Equal(t, usr.Username, "new")
+		assert.Equal(t, usr.Age, 30)
+		assert.Equal(t, usr.Files, []models.File{{Name: "a"}})
+		assert.Equal(t, usr.Password, "hash")
+		assert.True(t, usr.TOTP.Enabled)
+		return nil
+	})
+	testMQ.EXPECT().Publish(gomock.Any())
+	testObj := NewUserHandler(logrus.New(), testDB, testMQ, secutiry.NewAuthObj([]byte("test")))
+	testObj.PatchUser(ctx)
+	assert.Equal(t, w.Code, http.StatusOK)
+}
+
+func TestPatchUserJSONPatch(t *testing.T) {
+	w := httptest.NewRecorder()
+	ctx := GetTestGinContext(w)
+	mockPatch(ctx, "test@test.pl", contentTypeJSONPatch, `[{"op":"test","path":"/age","value":30},{"op":"replace","path":"/username","value":"b"}]`)
+	ctrl := gomock.NewController(t)
+	testDB := dbhandler.NewMockDBHandler(ctrl)
+	testMQ := queueHandler.NewMockQueueHandler(ctrl)
+	testDB.EXPECT().GetUser(gomock.Any(), "test@test.pl").Return(models.User{Email: "test@test.pl", Age: 30, Role: models.RoleUser,
+		Files: []models.File{{ID: "abc123", Name: "a"}}}, nil)
+	testDB.EXPECT().UpdateUser(gomock.Any(), gomock.Any()).DoAndReturn(func(_ any, usr models.User) error {
+		assert.Equal(t, usr.Username, "b")
+		assert.Equal(t, usr.Files, []models.File{{ID: "abc123", Name: "a"}})
+		return nil
+	})
+	testMQ.EXPECT().Publish(gomock.Any())
+	testObj := NewUserHandler(logrus.New(), testDB, testMQ, secutiry.NewAuthObj([]byte("test")))
+	testObj.PatchUser(ctx)
+	assert.Equal(t, w.Code, http.StatusOK)
+}
+
+func TestPatchUserRejected(t *testing.T) {
+	cases := []struct {
+		contentType string
+		body        string
+		code        int
+	}{
+		{"application/json", `{}`, http.StatusUnsupportedMediaType},
+		{contentTypeMergePatch, `{"status":"pending"}`, http.StatusUnprocessableEntity},
+		{contentTypeMergePatch, `{"age":12}`, http.StatusUnprocessableEntity},
+		{contentTypeMergePatch, `{"files":[{"id":"abc123","name":"stolen.txt"}]}`, http.StatusUnprocessableEntity},
+		{contentTypeJSONPatch, `[{"op":"add","path":"/files","value":[{"id":"abc123"}]}]`, http.StatusUnprocessableEntity},
+		{contentTypeMergePatch, `{"role":"admin"}`, http.StatusForbidden},
+		{contentTypeJSONPatch, `[{"op":"test","path":"/age","value":31}]`, http.StatusConflict},
+		{contentTypeJSONPatch, `[{"op":"remove","path":"/nope"}]`, http.StatusBadRequest},
+	}
+	for _, tc := range cases {
+		w := httptest.NewRecorder()
+		ctx := GetTestGinContext(w)
+		mockPatch(ctx, "test@test.pl", tc.contentType, tc.body)
+		ctx.Set(secutiry.ContextRole, models.RoleUser)
+		ctrl := gomock.NewController(t)
+		testDB := dbhandler.NewMockDBHandler(ctrl)
+		testDB.EXPECT().GetUser(gomock.Any(), gomock.Any()).Return(models.User{Email: "test@test.pl", Age: 30, Role: models.RoleUser}, nil).AnyTimes()
+		testObj := NewUserHandler(logrus.New(), testDB, queueHandler.NewMockQueueHandler(ctrl), secutiry.NewAuthObj([]byte("test")))
+		testObj.PatchUser(ctx)
+		assert.Equal(t, w.Code, tc.code, tc.body)
+	}
+}
@@ -0,0 +1,152 @@
+package user
+
+import (
+	"UserStorage/dbhandler"
+	"UserStorage/models"
+	"UserStorage/secutiry"
+	"archive/zip"
+	"encoding/json"
+	"errors"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const contentTypeZip = "application/zip"
+
+func (uh *UserHandler) ExportUserData(c *gin.Context) {
+	id := c.Param("id")
+	usr, ok := uh.loadUser(c, id)
+	if !ok {
+		return
+	}
+	export, err := uh.collectUserData(c, usr)
+	if err != nil {
+		uh.logger.Error(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	name := "user-data-" + strings.NewReplacer("@", "_at_", "/", "_").Replace(id)
+	if c.Query("format") != "zip" && c.NegotiateFormat(gin.MIMEJSON, contentTypeZip) != contentTypeZip {
+		c.Header("Content-Disposition", `attachment; filename="`+name+`.json"`)
+		c.JSON(http.StatusOK, export)
+		return
+	}
+	c.Header("Content-Type", contentTypeZip)
+	c.Header("Content-Disposition", `attachment; filename="`+name+`.zip"`)
+	c.Status(http.StatusOK)
+	if err = writeDataArchive(c.Writer, export); err != nil {
+		uh.logger.Error(err)
+	}
+}
+
+func (uh *UserHandler) collectUserData(c *gin.Context, usr models.User) (models.DataExport, error) {
+	export := models.DataExport{
+		ExportedAt: time.Now().UTC(),
+		User:       usr,
+		Files:      usr.Files,
+		Sessions:   []models.Session{},
+		APIKeys:    []models.APIKey{},
+		History:    []models.UserRevision{},
+		Audit:      []models.AuditEntry{},
+	}
+	if export.Files == nil {
+		export.Files = []models.File{}
+	}
+	sessions, err := uh.auth.Sessions(c.Request.Context(), usr.Email)
+	if err != nil && !errors.Is(err, secutiry.ErrSessionsDisabled) {
+		return models.DataExport{}, err
+	}
+	if sessions != nil {
+		export.Sessions = sessions
+	}
+	keys, err := uh.auth.APIKeys(c.Request.Context(), usr.Email)
+	if err != nil && !errors.Is(err, secutiry.ErrAPIKeysDisabled) {
+		return models.DataExport{}, err
+	}
+	if keys != nil {
+		export.APIKeys = keys
+	}
+	history, err := uh.dbHan.GetUserHistory(c.Request.Context(), usr.Email, dbhandler.AllRevisions)
+	if err != nil {
+		return models.DataExport{}, err
+	}
+	if history != nil {
+		export.History = history
+	}
+	if uh.auditLog != nil {
+		subject, err := uh.auditQuerySubject(c.Request.Context(), usr.Email)
+		if err != nil {
+			return models.DataExport{}, err
+		}
+		if export.Audit, err = uh.auditLog.QueryAudit(c.Request.Context(), models.AuditQuery{Target: subject}); err != nil {
+			return models.DataExport{}, err
+		}
+		if err = uh.openAudit(c.Request.Context(), export.Audit); err != nil {
+			return models.DataExport{}, err
+		}
+	}
+	return export, nil
+}
+
+func writeDataArchive(w http.ResponseWriter, export models.DataExport) error {
+	archive := zip.NewWriter(w)
+	parts := []struct {
+		name  string
+		value any
+	}{
+		{"user.json", export.User},
+		{"files.json", export.Files},
+		{"sessions.json", export.Sessions},
+		{"apikeys.json", export.APIKeys},
+		{"history.json", export.History},
+		{"audit.json", export.Audit},
+	}
+	for _, part := range parts {
+		f, err := archive.CreateHeader(&zip.FileHeader{Name: part.name, Method: zip.Deflate, Modified: export.ExportedAt})
+		if err != nil {
+			return err
+		}
+		enc := json.NewEncoder(f)
+		enc.SetIndent("", "  ")
+		if err = enc.Encode(part.value); err != nil {
+			return err
+		}
+	}
+	return archive.Close()
+}
+
+func (uh *UserHandler) EraseUser(c *gin.Context) {
+	id := c.Param("id")
+	usr, err := uh.dbHan.GetUserIncludingDeleted(c.Request.Context(), id)
+	if errors.Is(err, dbhandler.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		uh.logger.Error(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if err = uh.auth.ForgetUser(c.Request.Context(), id); err != nil {
+		uh.logger.Error(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if err = uh.dbHan.EraseUser(c.Request.Context(), id); err != nil && !errors.Is(err, dbhandler.ErrNotFound) {
+		uh.logger.Error(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	uh.deleteBlobs(c.Request.Context(), id, usr.Files)
+	uh.rabbit.Publish(models.Event{EventType: "UserErased", UserID: id})
+	uh.audit(c, models.AuditUserErased, id, nil, nil)
+	if uh.auditLog != nil {
+		if err = uh.auditLog.ForgetAuditSubject(c.Request.Context(), id); err != nil {
+			uh.logger.Error(err)
+		}
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "user erased"})
+}
@@ -0,0 +1,180 @@
+package user
+
+import (
+	"UserStorage/dbhandler"
+	"UserStorage/models"
+	"UserStorage/queueHandler"
+	"UserStorage/secutiry"
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"github.com/gin-gonic/gin"
+	"github.com/golang/mock/gomock"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestExportUserData(t *testing.T) {
+	w := httptest.NewRecorder()
+	ctx := GetTestGinContext(w)
+	MockJsonGet(ctx, gin.Params{gin.Param{Key: "id", Value: "test@test.pl"}}, url.Values{})
+	ctrl := gomock.NewController(t)
+	testDB := dbhandler.NewMockDBHandler(ctrl)
+	sessions := dbhandler.NewMemorySessionStore()
+	err := sessions.CreateSession(ctx, models.Session{ID: "s1", UserID: "test@test.pl", ExpiresAt: time.Now().Add(time.Hour)})
+	assert.NoError(t, err)
+	auth := secutiry.NewAuthObj([]byte("test"), secutiry.WithSessionStore(sessions))
+	testDB.EXPECT().GetUser(gomock.Any(), "test@test.pl").Return(models.User{Email: "test@test.pl", Password: "hash", Files: []models.File{{Name: "a"}}}, nil)
+	testDB.EXPECT().GetUserHistory(gomock.Any(), "test@test.pl", dbhandler.AllRevisions).Return([]models.UserRevision{
+		{UserID: "test@test.pl", Version: 1, User: models.User{Email: "test@test.pl", Username: "old", Age: 20}},
+	}, nil)
+	testObj := NewUserHandler(logrus.New(), testDB, queueHandler.NewMockQueueHandler(ctrl), auth)
+	testObj.ExportUserData(ctx)
+	assert.Equal(t, w.Code, http.StatusOK)
+	assert.Contains(t, w.Header().Get("Content-Disposition"), "user-data-test_at_test.pl.json")
+	assert.NotContains(t, w.Body.String(), "hash")
+	var export models.DataExport
+	err = json.NewDecoder(w.Body).Decode(&export)
+	assert.NoError(t, err)
+	assert.Equal(t, export.User.Email, "test@test.pl")
+	assert.Equal(t, export.Files, []models.File{{Name: "a"}})
+	assert.Len(t, export.Sessions, 1)
+	assert.Equal(t, export.APIKeys, []models.APIKey{})
+	assert.Len(t, export.History, 1)
+	assert.Equal(t, export.History[0].User.Username, "old")
+}
+
+func TestExportUserDataZip(t *testing.T) {
+	w := httptest.NewRecorder()
+	ctx := GetTestGinContext(w)
+	MockJsonGet(ctx, gin.Params{gin.Param{Key: "id", Value: "test@test.pl"}}, url.Values{"format": {"zip"}})
+	ctrl := gomock.NewController(t)
+	testDB := dbhandler.NewMockDBHandler(ctrl)
+	testDB.EXPECT().GetUser(gomock.Any(), "test@test.pl").Return(models.User{Email: "test@test.pl"}, nil)
+	testDB.EXPECT().GetUserHistory(gomock.Any(), "test@test.pl", dbhandler.AllRevisions).Return(nil, nil)
+	testObj := NewUserHandler(logrus.New(), testDB, queueHandler.NewMockQueueHandler(ctrl), secutiry.NewAuthObj([]byte("test")))
+	testObj.ExportUserData(ctx)
+	assert.Equal(t, w.Code, http.StatusOK)
+	assert.Equal(t, w.Header().Get("Content-Type"), contentTypeZip)
+	archive, err := zip.NewReader(bytes.NewReader(w.Body.Bytes()), int64(w.Body.Len()))
+	assert.NoError(t, err)
+	var names []string
+	for _, f := range archive.File {
+		names = append(names, f.Name)
+	}
+	assert.Equal(t, names, []string{"user.json", "files.json", "sessions.json", "apikeys.json", "history.json", "audit.json"})
+}
+
+func TestEraseUser(t *testing.T) {
+	w := httptest.NewRecorder()
+	ctx := GetTestGinContext(w)
+	MockJsonPost(ctx, nil, "test@test.pl")
+	ctrl := gomock.NewController(t)
+	testDB := dbhandler.NewMockDBHandler(ctrl)
+	testMQ := queueHandler.NewMockQueueHandler(ctrl)
+	sessions := dbhandler.NewMemorySessionStore()
+	err := sessions.CreateSession(ctx, models.Session{ID: "s1", UserID: "test@test.pl"})
+	assert.NoError(t, err)
+	keys := dbhandler.NewMemoryAPIKeyStore()
+	err = keys.CreateAPIKey(ctx, models.APIKey{ID: "k1", UserID: "test@test.pl"})
+	assert.NoError(t, err)
+	auth := secutiry.NewAuthObj([]byte("test"), secutiry.WithSessionStore(sessions), secutiry.WithAPIKeyStore(keys))
+	testDB.EXPECT().GetUserIncludingDeleted(gomock.Any(), "test@test.pl").Return(models.User{Email: "test@test.pl"}, nil)
+	testDB.EXPECT().EraseUser(gomock.Any(), "test@test.pl").Return(nil)
+	testMQ.EXPECT().Publish(models.Event{EventType: "UserErased", UserID: "test@test.pl"})
+	testObj := NewUserHandler(logrus.New(), testDB, testMQ, auth)
+	testObj.EraseUser(ctx)
+	assert.Equal(t, w.Code, http.StatusOK)
+	left, _ := sessions.ListSessions(ctx, "test@test.pl")
+	assert.Empty(t, left)
+	leftKeys, _ := keys.ListAPIKeys(ctx, "test@test.pl")
+	assert.Empty(t, leftKeys)
+}
+
+func TestEraseUserLeavesNoPersonalData(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	testDB := dbhandler.NewMockDBHandler(ctrl)
+	testMQ := queueHandler.NewMockQueueHandler(ctrl)
+	audit := dbhandler.NewMemoryAuditStore()
+	sessions := dbhandler.NewMemorySessionStore()
+	keys := dbhandler.NewMemoryAPIKeyStore()
+	revocations := dbhandler.NewMemoryRevocationStore()
+	auth := secutiry.NewAuthObj([]byte("test"),
+		secutiry.WithSessionStore(sessions), secutiry.WithAPIKeyStore(keys), secutiry.WithRevocationStore(revocations))
+	testObj := NewUserHandler(logrus.New(), testDB, testMQ, auth, WithAuditLog(audit))
+	stored := models.User{
+		Email:    "alice@test.pl",
+		Username: "alice-wonder",
+		Age:      31,
+		Role:     models.RoleUser,
+		Files:    []models.File{{Name: "alice-passport.pdf"}},
+	}
+	pii := []string{stored.Email, stored.Username, stored.Files[0].Name}
+
+	w := httptest.NewRecorder()
+	ctx := GetTestGinContext(w)
+	MockJsonPost(ctx, models.UserUpdate{Username: "alice-updated", Age: 32}, stored.Email)
+	ctx.Set(secutiry.ContextSubject, stored.Email)
+	ctx.Set(secutiry.ContextRole, models.RoleUser)
+	pii = append(pii, "alice-updated")
+	testDB.EXPECT().GetUser(gomock.Any(), stored.Email).Return(stored, nil)
+	testDB.EXPECT().UpdateUser(gomock.Any(), gomock.Any()).Return(nil)
+	testMQ.EXPECT().Publish(gomock.Any())
+	testObj.UpdateUser(ctx)
+	assert.Equal(t, w.Code, http.StatusOK)
+
+	w = httptest.NewRecorder()
+	ctx = GetTestGinContext(w)
+	MockJsonPost(ctx, nil, stored.Email)
+	ctx.Set(secutiry.ContextSubject, stored.Email)
+	err := sessions.CreateSession(ctx, models.Session{ID: "s1", UserID: stored.Email, ExpiresAt: time.Now().Add(time.Hour)})
+	assert.NoError(t, err)
+	testDB.EXPECT().GetUserIncludingDeleted(gomock.Any(), stored.Email).Return(stored, nil)
+	testDB.EXPECT().EraseUser(gomock.Any(), stored.Email).Return(nil)
+	testMQ.EXPECT().Publish(gomock.Any())
+	testObj.EraseUser(ctx)
+	assert.Equal(t, w.Code, http.StatusOK)
+
+	entries, err := audit.QueryAudit(ctx, models.AuditQuery{})
+	assert.NoError(t, err)
+	assert.Len(t, entries, 2)
+	raw, err := json.Marshal(entries)
+	assert.NoError(t, err)
+	for _, value := range pii {
+		assert.NotContains(t, string(raw), value)
+	}
+	assert.Equal(t, entries[1].Action, models.AuditUserErased)
+	assert.Equal(t, entries[1].Target, entries[0].Target)
+	assert.NoError(t, testObj.openAudit(ctx, entries))
+	assert.True(t, entries[0].Changes[0].Redacted)
+	assert.Nil(t, entries[0].Changes[0].After)
+	_, err = audit.AuditSubject(ctx, stored.Email, false)
+	assert.ErrorIs(t, err, dbhandler.ErrNotFound)
+	left, _ := sessions.ListSessions(ctx, stored.Email)
+	assert.Empty(t, left)
+	leftKeys, _ := keys.ListAPIKeys(ctx, stored.Email)
+	assert.Empty(t, leftKeys)
+	revoked, err := revocations.IsRevoked(ctx, "", stored.Email, time.Now().Add(-time.Minute))
+	assert.NoError(t, err)
+	assert.False(t, revoked)
+	revoked, err = revocations.IsRevoked(ctx, "", secutiry.HashToken(stored.Email), time.Now().Add(-time.Minute))
+	assert.NoError(t, err)
+	assert.True(t, revoked)
+}
+
+func TestEraseUserNotFound(t *testing.T) {
+	w := httptest.NewRecorder()
+	ctx := GetTestGinContext(w)
+	MockJsonPost(ctx, nil, "test@test.pl")
+	ctrl := gomock.NewController(t)
+	testDB := dbhandler.NewMockDBHandler(ctrl)
+	testDB.EXPECT().GetUserIncludingDeleted(gomock.Any(), "test@test.pl").Return(models.User{}, dbhandler.ErrNotFound)
+	testObj := NewUserHandler(logrus.New(), testDB, queueHandler.NewMockQueueHandler(ctrl), secutiry.NewAuthObj([]byte("test")))
+	testObj.EraseUser(ctx)
+	assert.Equal(t, w.Code, http.StatusNotFound)
+}
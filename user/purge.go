@@ -0,0 +1,36 @@
+package user
+
+import (
+	"UserStorage/models"
+	"context"
+	"time"
+)
+
+func (uh *UserHandler) PurgeDeletedUsers(ctx context.Context, retention time.Duration) error {
+	users, err := uh.dbHan.PurgeDeletedUsers(ctx, time.Now().Add(-retention))
+	for _, user := range users {
+		uh.revokeAccess(ctx, user.Email)
+		uh.deleteBlobs(ctx, user.Email, user.Files)
+		uh.rabbit.Publish(models.Event{EventType: "UserPurged", UserID: user.Email})
+	}
+	return err
+}
+
+func (uh *UserHandler) RunPurger(ctx context.Context, retention time.Duration, interval time.Duration) {
+	if interval <= 0 || retention < 0 {
+		uh.logger.Warn("purger disabled")
+		return
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if err := uh.PurgeDeletedUsers(ctx, retention); err != nil {
+			uh.logger.Error(err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
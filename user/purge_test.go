@@ -0,0 +1,119 @@
+package user
+
+import (
+	"UserStorage/dbhandler"
+	"UserStorage/models"
+	"UserStorage/queueHandler"
+	"UserStorage/secutiry"
+	"context"
+	"errors"
+	"github.com/gin-gonic/gin"
+	"github.com/golang/mock/gomock"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestPurgeDeletedUsers(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	testDB := dbhandler.NewMockDBHandler(ctrl)
+	testMQ := queueHandler.NewMockQueueHandler(ctrl)
+	testDB.EXPECT().PurgeDeletedUsers(gomock.Any(), gomock.Any()).DoAndReturn(func(_ any, before time.Time) ([]models.User, error) {
+		assert.WithinDuration(t, before, time.Now().Add(-48*time.Hour), time.Minute)
+		return []models.User{{Email: "a@test.pl"}, {Email: "b@test.pl"}}, nil
+	})
+	expectRevokeAccess(testDB, "a@test.pl")
+	expectRevokeAccess(testDB, "b@test.pl")
+	testMQ.EXPECT().Publish(models.Event{EventType: "UserPurged", UserID: "a@test.pl"})
+	testMQ.EXPECT().Publish(models.Event{EventType: "UserPurged", UserID: "b@test.pl"})
+	testObj := NewUserHandler(logrus.New(), testDB, testMQ, secutiry.NewAuthObj([]byte("test")))
+	err := testObj.PurgeDeletedUsers(context.Background(), 48*time.Hour)
+	assert.NoError(t, err)
+}
+
+func TestPurgeRevokesAPIKeys(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	testDB := dbhandler.NewMockDBHandler(ctrl)
+	testMQ := queueHandler.NewMockQueueHandler(ctrl)
+	auth := secutiry.NewAuthObj([]byte("test"), secutiry.WithAPIKeyStore(dbhandler.NewMemoryAPIKeyStore()))
+	_, _, err := auth.IssueAPIKey(context.Background(), models.APIKey{UserID: "a@test.pl", ExpiresAt: time.Now().Add(time.Hour)})
+	assert.NoError(t, err)
+	testDB.EXPECT().PurgeDeletedUsers(gomock.Any(), gomock.Any()).Return([]models.User{{Email: "a@test.pl"}}, errors.New("connection reset"))
+	expectRevokeAccess(testDB, "a@test.pl")
+	testMQ.EXPECT().Publish(models.Event{EventType: "UserPurged", UserID: "a@test.pl"})
+	testObj := NewUserHandler(logrus.New(), testDB, testMQ, auth)
+	err = testObj.PurgeDeletedUsers(context.Background(), time.Hour)
+	assert.Error(t, err)
+	keys, err := auth.APIKeys(context.Background(), "a@test.pl")
+	assert.NoError(t, err)
+	assert.Empty(t, keys)
+}
+
+func TestRunPurgerDisabled(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	testObj := NewUserHandler(logrus.New(), dbhandler.NewMockDBHandler(ctrl), queueHandler.NewMockQueueHandler(ctrl), secutiry.NewAuthObj([]byte("test")))
+	for _, tc := range []struct{ retention, interval time.Duration }{
+		{time.Hour, 0},
+		{time.Hour, -time.Second},
+		{-time.Hour, time.Hour},
+	} {
+		testObj.RunPurger(context.Background(), tc.retention, tc.interval)
+	}
+}
+
+func TestRestoreUser(t *testing.T) {
+	w := httptest.NewRecorder()
+	ctx := GetTestGinContext(w)
+	MockJsonPost(ctx, nil, "test@test.pl")
+	ctrl := gomock.NewController(t)
+	testDB := dbhandler.NewMockDBHandler(ctrl)
+	testMQ := queueHandler.NewMockQueueHandler(ctrl)
+	testDB.EXPECT().RestoreUser(gomock.Any(), "test@test.pl").Return(nil)
+	testMQ.EXPECT().Publish(models.Event{EventType: "UserRestored", UserID: "test@test.pl"})
+	testObj := NewUserHandler(logrus.New(), testDB, testMQ, secutiry.NewAuthObj([]byte("test")))
+	testObj.RestoreUser(ctx)
+	assert.Equal(t, w.Code, http.StatusOK)
+}
+
+func TestRestoreUserNotDeleted(t *testing.T) {
+	w := httptest.NewRecorder()
+	ctx := GetTestGinContext(w)
+	MockJsonPost(ctx, nil, "test@test.pl")
+	ctrl := gomock.NewController(t)
+	testDB := dbhandler.NewMockDBHandler(ctrl)
+	testDB.EXPECT().RestoreUser(gomock.Any(), "test@test.pl").Return(dbhandler.ErrNotFound)
+	testObj := NewUserHandler(logrus.New(), testDB, queueHandler.NewMockQueueHandler(ctrl), secutiry.NewAuthObj([]byte("test")))
+	testObj.RestoreUser(ctx)
+	assert.Equal(t, w.Code, http.StatusNotFound)
+}
+
+func TestGetUserIncludeDeleted(t *testing.T) {
+	w := httptest.NewRecorder()
+	ctx := GetTestGinContext(w)
+	MockJsonGet(ctx, gin.Params{gin.Param{Key: "id", Value: "test@test.pl"}}, url.Values{"includeDeleted": {"true"}})
+	ctx.Set(secutiry.ContextRole, models.RoleAdmin)
+	ctrl := gomock.NewController(t)
+	testDB := dbhandler.NewMockDBHandler(ctrl)
+	deletedAt := time.Now()
+	testDB.EXPECT().GetUserIncludingDeleted(gomock.Any(), "test@test.pl").Return(models.User{Email: "test@test.pl", DeletedAt: &deletedAt}, nil)
+	testObj := NewUserHandler(logrus.New(), testDB, queueHandler.NewMockQueueHandler(ctrl), secutiry.NewAuthObj([]byte("test")))
+	testObj.GetUser(ctx)
+	assert.Equal(t, w.Code, http.StatusOK)
+}
+
+func TestGetUserIncludeDeletedNotAdmin(t *testing.T) {
+	w := httptest.NewRecorder()
+	ctx := GetTestGinContext(w)
+	MockJsonGet(ctx, gin.Params{gin.Param{Key: "id", Value: "test@test.pl"}}, url.Values{"includeDeleted": {"true"}})
+	ctx.Set(secutiry.ContextRole, models.RoleUser)
+	ctrl := gomock.NewController(t)
+	testDB := dbhandler.NewMockDBHandler(ctrl)
+	testDB.EXPECT().GetUser(gomock.Any(), "test@test.pl").Return(models.User{}, dbhandler.ErrNotFound)
+	testObj := NewUserHandler(logrus.New(), testDB, queueHandler.NewMockQueueHandler(ctrl), secutiry.NewAuthObj([]byte("test")))
+	testObj.GetUser(ctx)
+	assert.Equal(t, w.Code, http.StatusNotFound)
+}
@@ -0,0 +1,87 @@
+package user
+
+import (
+	"UserStorage/models"
+	"errors"
+	"github.com/gin-gonic/gin"
+	"strconv"
+	"time"
+)
+
+func parseUserQuery(c *gin.Context) (models.UserQuery, error) {
+	query := models.UserQuery{
+		Limit:          models.DefaultPageSize,
+		Cursor:         c.Query("cursor"),
+		Sort:           c.DefaultQuery("sort", models.SortEmail),
+		UsernamePrefix: c.Query("username"),
+	}
+	if v := c.Query("limit"); v != "" {
+		limit, err := strconv.Atoi(v)
+		if err != nil || limit < 1 || limit > models.MaxPageSize {
+			return models.UserQuery{}, errors.New("limit must be between 1 and " + strconv.Itoa(models.MaxPageSize))
+		}
+		query.Limit = limit
+	}
+	if !models.ValidSort(query.Sort) {
+		return models.UserQuery{}, errors.New("sort must be one of email, username, age")
+	}
+	switch c.DefaultQuery("order", "asc") {
+	case "asc":
+	case "desc":
+		query.Desc = true
+	default:
+		return models.UserQuery{}, errors.New("order must be asc or desc")
+	}
+	var err error
+	if query.MinAge, err = optionalInt(c, "minAge"); err != nil {
+		return models.UserQuery{}, err
+	}
+	if query.MaxAge, err = optionalInt(c, "maxAge"); err != nil {
+		return models.UserQuery{}, err
+	}
+	if query.HasFiles, err = optionalBool(c, "hasFiles"); err != nil {
+		return models.UserQuery{}, err
+	}
+	includeDeleted, err := optionalBool(c, "includeDeleted")
+	if err != nil {
+		return models.UserQuery{}, err
+	}
+	query.IncludeDeleted = includeDeleted != nil && *includeDeleted
+	return query, nil
+}
+
+func optionalInt(c *gin.Context, param string) (*int, error) {
+	v := c.Query(param)
+	if v == "" {
+		return nil, nil
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil {
+		return nil, errors.New(param + " must be a number")
+	}
+	return &n, nil
+}
+
+func optionalBool(c *gin.Context, param string) (*bool, error) {
+	v := c.Query(param)
+	if v == "" {
+		return nil, nil
+	}
+	b, err := strconv.ParseBool(v)
+	if err != nil {
+		return nil, errors.New(param + " must be true or false")
+	}
+	return &b, nil
+}
+
+func optionalTime(c *gin.Context, param string) (time.Time, error) {
+	v := c.Query(param)
+	if v == "" {
+		return time.Time{}, nil
+	}
+	t, err := time.Parse(time.RFC3339, v)
+	if err != nil {
+		return time.Time{}, errors.New(param + " must be an RFC 3339 timestamp")
+	}
+	return t, nil
+}
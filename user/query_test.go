@@ -0,0 +1,85 @@
+package user
+
+import (
+	"UserStorage/dbhandler"
+	"UserStorage/models"
+	"UserStorage/queueHandler"
+	"UserStorage/secutiry"
+	"github.com/gin-gonic/gin"
+	"github.com/golang/mock/gomock"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+)
+
+func TestGetAllUsersQuery(t *testing.T) {
+	w := httptest.NewRecorder()
+	ctx := GetTestGinContext(w)
+	var logger = logrus.New()
+	MockJsonGet(ctx, gin.Params{}, url.Values{
+		"limit":    {"2"},
+		"sort":     {"age"},
+		"order":    {"desc"},
+		"username": {"te"},
+		"minAge":   {"20"},
+		"hasFiles": {"true"},
+		"cursor":   {"abc"},
+
+		"includeDeleted": {"true"},
+	})
+	ctrl := gomock.NewController(t)
+	testDB := dbhandler.NewMockDBHandler(ctrl)
+	testMQ := queueHandler.NewMockQueueHandler(ctrl)
+	auth := secutiry.NewAuthObj([]byte("test"))
+	testObj := NewUserHandler(logger, testDB, testMQ, auth)
+	minAge, hasFiles := 20, true
+	testDB.EXPECT().GetUsers(gomock.Any(), models.UserQuery{
+		Limit:          2,
+		Cursor:         "abc",
+		Sort:           models.SortAge,
+		Desc:           true,
+		UsernamePrefix: "te",
+		MinAge:         &minAge,
+		HasFiles:       &hasFiles,
+		IncludeDeleted: true,
+	}).Return(models.UserPage{Users: []models.User{{Email: "a@test.pl"}, {Email: "b@test.pl"}}, NextCursor: "next"}, nil)
+	testObj.GetAllUsers(ctx)
+	assert.Equal(t, w.Code, http.StatusOK)
+	assert.Equal(t, w.Header().Get("X-Next-Cursor"), "next")
+	assert.Contains(t, w.Header().Get("Link"), "cursor=next")
+	assert.Contains(t, w.Header().Get("Link"), `rel="next"`)
+}
+
+func TestGetAllUsersBadQuery(t *testing.T) {
+	for _, values := range []url.Values{
+		{"limit": {"0"}},
+		{"limit": {"1000"}},
+		{"sort": {"password"}},
+		{"order": {"up"}},
+		{"minAge": {"x"}},
+		{"hasFiles": {"maybe"}},
+	} {
+		w := httptest.NewRecorder()
+		ctx := GetTestGinContext(w)
+		MockJsonGet(ctx, gin.Params{}, values)
+		ctrl := gomock.NewController(t)
+		testObj := NewUserHandler(logrus.New(), dbhandler.NewMockDBHandler(ctrl), queueHandler.NewMockQueueHandler(ctrl), secutiry.NewAuthObj([]byte("test")))
+		testObj.GetAllUsers(ctx)
+		assert.Equal(t, w.Code, http.StatusBadRequest, values.Encode())
+	}
+}
+
+func TestGetAllUsersInvalidCursor(t *testing.T) {
+	w := httptest.NewRecorder()
+	ctx := GetTestGinContext(w)
+	MockJsonGet(ctx, gin.Params{}, url.Values{"cursor": {"bogus"}})
+	ctrl := gomock.NewController(t)
+	testDB := dbhandler.NewMockDBHandler(ctrl)
+	testDB.EXPECT().GetUsers(gomock.Any(), gomock.Any()).Return(models.UserPage{}, dbhandler.ErrInvalidCursor)
+	testObj := NewUserHandler(logrus.New(), testDB, queueHandler.NewMockQueueHandler(ctrl), secutiry.NewAuthObj([]byte("test")))
+	testObj.GetAllUsers(ctx)
+	assert.Equal(t, w.Code, http.StatusBadRequest)
+}
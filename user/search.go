@@ -0,0 +1,102 @@
+package user
+
+import (
+	"UserStorage/models"
+	"github.com/gin-gonic/gin"
+	"html"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+const (
+	defaultSearchLimit = 20
+	maxSearchLimit     = 100
+
+	exactMatchBonus     = 3
+	prefixMatchBonus    = 2
+	substringMatchBonus = 1
+)
+
+func (uh *UserHandler) SearchUsers(c *gin.Context) {
+	q := strings.TrimSpace(c.Query("q"))
+	if q == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "q is required"})
+		return
+	}
+	limit := defaultSearchLimit
+	if v := c.Query("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > maxSearchLimit {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "limit must be between 1 and " + strconv.Itoa(maxSearchLimit)})
+			return
+		}
+		limit = n
+	}
+	hits, err := uh.dbHan.SearchUsers(c.Request.Context(), q, limit)
+	if err != nil {
+		uh.logger.Error(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, rankHits(q, hits, limit))
+}
+
+func rankHits(q string, hits []models.SearchHit, limit int) []models.SearchHit {
+	ranked := make([]models.SearchHit, 0, len(hits))
+	for _, hit := range hits {
+		hit.User.Password = ""
+		bonus, highlight := matchHit(q, hit.User)
+		hit.Score += bonus
+		hit.Highlight = highlight
+		ranked = append(ranked, hit)
+	}
+	slices.SortStableFunc(ranked, func(a, b models.SearchHit) int {
+		if a.Score != b.Score {
+			if a.Score > b.Score {
+				return -1
+			}
+			return 1
+		}
+		return strings.Compare(a.User.Email, b.User.Email)
+	})
+	if len(ranked) > limit {
+		ranked = ranked[:limit]
+	}
+	return ranked
+}
+
+func matchHit(q string, user models.User) (float64, *models.Highlight) {
+	var best float64
+	var highlight *models.Highlight
+	fields := []struct{ name, value string }{{"username", user.Username}, {"email", user.Email}}
+	for _, term := range append([]string{q}, strings.Fields(q)...) {
+		needle := strings.ToLower(term)
+		for _, field := range fields {
+			value := strings.ToLower(field.value)
+			i := strings.Index(value, needle)
+			if i < 0 || len(value) != len(field.value) {
+				continue
+			}
+			bonus := float64(substringMatchBonus)
+			if value == needle {
+				bonus = exactMatchBonus
+			} else if i == 0 {
+				bonus = prefixMatchBonus
+			}
+			if bonus > best {
+				best = bonus
+				highlight = &models.Highlight{Field: field.name, Fragment: emphasize(field.value, i, i+len(needle))}
+			}
+		}
+		if highlight != nil {
+			break
+		}
+	}
+	return best, highlight
+}
+
+func emphasize(value string, start int, end int) string {
+	return html.EscapeString(value[:start]) + "<em>" + html.EscapeString(value[start:end]) + "</em>" + html.EscapeString(value[end:])
+}
@@ -0,0 +1,63 @@
+package user
+
+import (
+	"UserStorage/dbhandler"
+	"UserStorage/models"
+	"UserStorage/queueHandler"
+	"UserStorage/secutiry"
+	"encoding/json"
+	"github.com/gin-gonic/gin"
+	"github.com/golang/mock/gomock"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+)
+
+func TestSearchUsers(t *testing.T) {
+	w := httptest.NewRecorder()
+	ctx := GetTestGinContext(w)
+	MockJsonGet(ctx, gin.Params{}, url.Values{"q": {"Jo"}, "limit": {"3"}})
+	ctrl := gomock.NewController(t)
+	testDB := dbhandler.NewMockDBHandler(ctrl)
+	testDB.EXPECT().SearchUsers(gomock.Any(), "Jo", 3).Return([]models.SearchHit{
+		{User: models.User{Email: "mary@test.pl", Username: "Mary Jones"}},
+		{User: models.User{Email: "jo@test.pl", Username: "jo"}},
+		{User: models.User{Email: "john@test.pl", Username: "<John>"}},
+		{User: models.User{Email: "major@test.pl", Username: "major"}},
+	}, nil)
+	testObj := NewUserHandler(logrus.New(), testDB, queueHandler.NewMockQueueHandler(ctrl), secutiry.NewAuthObj([]byte("test")))
+	testObj.SearchUsers(ctx)
+	assert.Equal(t, w.Code, http.StatusOK)
+	var hits []models.SearchHit
+	err := json.NewDecoder(w.Body).Decode(&hits)
+	assert.NoError(t, err)
+	assert.Len(t, hits, 3)
+	assert.Equal(t, hits[0].User.Email, "jo@test.pl")
+	assert.Equal(t, hits[0].Highlight, &models.Highlight{Field: "username", Fragment: "<em>jo</em>"})
+	assert.Equal(t, hits[1].User.Email, "john@test.pl")
+	assert.Equal(t, hits[1].Highlight, &models.Highlight{Field: "email", Fragment: "<em>jo</em>hn@test.pl"})
+	assert.Equal(t, hits[2].User.Email, "major@test.pl")
+	assert.Equal(t, hits[2].Highlight.Fragment, "ma<em>jo</em>r")
+}
+
+func TestSearchUsersTextScore(t *testing.T) {
+	hits := rankHits("smith", []models.SearchHit{
+		{User: models.User{Email: "a@test.pl", Username: "anna smithson"}, Score: 0.6},
+		{User: models.User{Email: "b@test.pl", Username: "bob smith"}, Score: 0.75},
+	}, 10)
+	assert.Equal(t, hits[0].User.Email, "b@test.pl")
+	assert.Equal(t, hits[0].Highlight.Fragment, "bob <em>smith</em>")
+}
+
+func TestSearchUsersRequiresQuery(t *testing.T) {
+	w := httptest.NewRecorder()
+	ctx := GetTestGinContext(w)
+	MockJsonGet(ctx, gin.Params{}, url.Values{"q": {"  "}})
+	ctrl := gomock.NewController(t)
+	testObj := NewUserHandler(logrus.New(), dbhandler.NewMockDBHandler(ctrl), queueHandler.NewMockQueueHandler(ctrl), secutiry.NewAuthObj([]byte("test")))
+	testObj.SearchUsers(ctx)
+	assert.Equal(t, w.Code, http.StatusBadRequest)
+}
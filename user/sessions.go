@@ -0,0 +1,44 @@
+package user
+
+import (
+	"UserStorage/dbhandler"
+	"UserStorage/models"
+	"UserStorage/secutiry"
+	"errors"
+	"github.com/gin-gonic/gin"
+	"net/http"
+)
+
+func (uh *UserHandler) GetSessions(c *gin.Context) {
+	id := c.Param("id")
+	sessions, err := uh.auth.Sessions(c.Request.Context(), id)
+	if err != nil {
+		uh.logger.Error(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	for i := range sessions {
+		sessions[i].Current = sessions[i].ID == secutiry.SessionID(c)
+	}
+	c.JSON(http.StatusOK, sessions)
+}
+
+func (uh *UserHandler) DeleteSession(c *gin.Context) {
+	id := c.Param("id")
+	session, err := uh.auth.EndSession(c.Request.Context(), id, c.Param("sid"))
+	if errors.Is(err, dbhandler.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		uh.logger.Error(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	_, err = uh.dbHan.ConsumeToken(c.Request.Context(), session.RefreshTokenID, models.TokenRefresh)
+	if err != nil && !errors.Is(err, dbhandler.ErrNotFound) {
+		uh.logger.Error(err)
+	}
+	uh.rabbit.Publish(models.Event{EventType: "SessionTerminated", UserID: id})
+	c.JSON(http.StatusOK, gin.H{"message": "session terminated"})
+}
@@ -0,0 +1,112 @@
+package user
+
+import (
+	"UserStorage/dbhandler"
+	"UserStorage/models"
+	"UserStorage/queueHandler"
+	"UserStorage/secutiry"
+	"encoding/json"
+	"github.com/gin-gonic/gin"
+	"github.com/golang/mock/gomock"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/bcrypt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestLoginStartsSession(t *testing.T) {
+	w := httptest.NewRecorder()
+	ctx := GetTestGinContext(w)
+	var logger = logrus.New()
+	MockJsonPost(ctx, models.LoginRequest{Username: "test@test.pl", Password: "test"}, "")
+	ctx.Request.Header.Set("User-Agent", "test-agent")
+	ctrl := gomock.NewController(t)
+	testDB := dbhandler.NewMockDBHandler(ctrl)
+	testMQ := queueHandler.NewMockQueueHandler(ctrl)
+	sessions := dbhandler.NewMemorySessionStore()
+	auth := secutiry.NewAuthObj([]byte("test"), secutiry.WithSessionStore(sessions))
+	hash, err := bcrypt.GenerateFromPassword([]byte("test"), bcrypt.DefaultCost)
+	assert.NoError(t, err)
+	testDB.EXPECT().GetUser(gomock.Any(), "test@test.pl").Return(models.User{Email: "test@test.pl", Password: string(hash)}, nil)
+	var refresh models.Token
+	testDB.EXPECT().SaveToken(gomock.Any(), gomock.Any()).DoAndReturn(func(_ any, token models.Token) error {
+		refresh = token
+		return nil
+	})
+	testObj := NewUserHandler(logger, testDB, testMQ, auth)
+	testObj.Login(ctx)
+	assert.Equal(t, w.Code, http.StatusOK)
+	var out msgTokens
+	err = json.NewDecoder(w.Body).Decode(&out)
+	assert.NoError(t, err)
+	claims, err := auth.ValidateToken(out.Token)
+	assert.NoError(t, err)
+
+	list, err := sessions.ListSessions(ctx, "test@test.pl")
+	assert.NoError(t, err)
+	assert.Len(t, list, 1)
+	assert.Equal(t, list[0].ID, claims.SID)
+	assert.Equal(t, list[0].UserAgent, "test-agent")
+	assert.Equal(t, list[0].RefreshTokenID, secutiry.HashToken(out.RefreshToken))
+	assert.Equal(t, refresh.Data["sid"], claims.SID)
+}
+
+func TestRefreshTerminatedSession(t *testing.T) {
+	w := httptest.NewRecorder()
+	ctx := GetTestGinContext(w)
+	var logger = logrus.New()
+	MockJsonPost(ctx, models.RefreshRequest{RefreshToken: "refresh"}, "")
+	ctrl := gomock.NewController(t)
+	testDB := dbhandler.NewMockDBHandler(ctrl)
+	testMQ := queueHandler.NewMockQueueHandler(ctrl)
+	auth := secutiry.NewAuthObj([]byte("test"), secutiry.WithSessionStore(dbhandler.NewMemorySessionStore()))
+	testDB.EXPECT().ConsumeToken(gomock.Any(), secutiry.HashToken("refresh"), models.TokenRefresh).Return(models.Token{
+		UserID:    "test@test.pl",
+		ExpiresAt: time.Now().Add(time.Hour),
+		Data:      map[string]string{"sid": "gone"},
+	}, nil)
+	testDB.EXPECT().GetUser(gomock.Any(), "test@test.pl").Return(models.User{Email: "test@test.pl"}, nil)
+	testObj := NewUserHandler(logger, testDB, testMQ, auth)
+	testObj.Refresh(ctx)
+	assert.Equal(t, w.Code, http.StatusUnauthorized)
+}
+
+func TestGetAndDeleteSession(t *testing.T) {
+	var logger = logrus.New()
+	ctrl := gomock.NewController(t)
+	testDB := dbhandler.NewMockDBHandler(ctrl)
+	testMQ := queueHandler.NewMockQueueHandler(ctrl)
+	sessions := dbhandler.NewMemorySessionStore()
+	auth := secutiry.NewAuthObj([]byte("test"), secutiry.WithSessionStore(sessions))
+	assert.NoError(t, sessions.CreateSession(t.Context(), models.Session{ID: "s1", UserID: "test@test.pl", RefreshTokenID: "r1"}))
+	assert.NoError(t, sessions.CreateSession(t.Context(), models.Session{ID: "s2", UserID: "test@test.pl", RefreshTokenID: "r2"}))
+	testObj := NewUserHandler(logger, testDB, testMQ, auth)
+
+	w := httptest.NewRecorder()
+	ctx := GetTestGinContext(w)
+	MockJsonGet(ctx, gin.Params{{Key: "id", Value: "test@test.pl"}}, nil)
+	ctx.Set(secutiry.ContextSessionID, "s1")
+	testObj.GetSessions(ctx)
+	assert.Equal(t, w.Code, http.StatusOK)
+	var list []models.Session
+	err := json.NewDecoder(w.Body).Decode(&list)
+	assert.NoError(t, err)
+	assert.Len(t, list, 2)
+	for _, s := range list {
+		assert.Equal(t, s.Current, s.ID == "s1")
+	}
+
+	w = httptest.NewRecorder()
+	ctx = GetTestGinContext(w)
+	MockJsonDelete(ctx, gin.Params{{Key: "id", Value: "test@test.pl"}, {Key: "sid", Value: "s2"}})
+	testDB.EXPECT().ConsumeToken(gomock.Any(), "r2", models.TokenRefresh).Return(models.Token{}, nil)
+	testMQ.EXPECT().Publish(models.Event{EventType: "SessionTerminated", UserID: "test@test.pl"})
+	testObj.DeleteSession(ctx)
+	assert.Equal(t, w.Code, http.StatusOK)
+	remaining, err := sessions.ListSessions(t.Context(), "test@test.pl")
+	assert.NoError(t, err)
+	assert.Len(t, remaining, 1)
+}
@@ -0,0 +1,38 @@
+package user
+
+import (
+	"UserStorage/dbhandler"
+	"UserStorage/models"
+	"UserStorage/secutiry"
+	"context"
+	"time"
+)
+
+func (uh *UserHandler) saveToken(ctx context.Context, kind string, userID string, ttl time.Duration) (string, time.Time, error) {
+	token, err := secutiry.NewOpaqueToken()
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	expiresAt := time.Now().Add(ttl)
+	err = uh.dbHan.SaveToken(ctx, models.Token{
+		ID:        secutiry.HashToken(token),
+		Kind:      kind,
+		UserID:    userID,
+		ExpiresAt: expiresAt,
+	})
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	return token, expiresAt, nil
+}
+
+func (uh *UserHandler) consumeToken(ctx context.Context, token string, kind string) (models.Token, error) {
+	stored, err := uh.dbHan.ConsumeToken(ctx, secutiry.HashToken(token), kind)
+	if err != nil {
+		return models.Token{}, err
+	}
+	if stored.ExpiresAt.Before(time.Now()) {
+		return models.Token{}, dbhandler.ErrNotFound
+	}
+	return stored, nil
+}
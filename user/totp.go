@@ -0,0 +1,342 @@
+package user
+
+import (
+	"UserStorage/dbhandler"
+	"UserStorage/models"
+	"UserStorage/secutiry"
+	"crypto/subtle"
+	"errors"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+)
+
+const (
+	totpIssuer        = "UserStorage"
+	recoveryCodeCount = 10
+)
+
+func (uh *UserHandler) EnrollTOTP(c *gin.Context) {
+	id := c.Param("id")
+	user, err := uh.dbHan.GetUser(c.Request.Context(), id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		uh.logger.Error(err)
+		return
+	}
+	if user.TOTP.Enabled {
+		c.JSON(http.StatusConflict, gin.H{"error": "2fa already enabled"})
+		return
+	}
+	secret, err := secutiry.NewTOTPSecret()
+	if err != nil {
+		uh.logger.Error(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if err = uh.dbHan.SetTOTP(c.Request.Context(), id, models.TOTP{Secret: secret}); err != nil {
+		uh.logger.Error(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"secret": secret, "uri": secutiry.TOTPURI(totpIssuer, id, secret)})
+}
+
+func (uh *UserHandler) ConfirmTOTP(c *gin.Context) {
+	id := c.Param("id")
+	var req models.TOTPCodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	user, err := uh.dbHan.GetUser(c.Request.Context(), id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		uh.logger.Error(err)
+		return
+	}
+	if user.TOTP.Enabled {
+		c.JSON(http.StatusConflict, gin.H{"error": "2fa already enabled"})
+		return
+	}
+	if user.TOTP.Secret == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "2fa enrollment not started"})
+		return
+	}
+	step, ok := secutiry.ValidateTOTP(user.TOTP.Secret, req.Code, time.Now(), user.TOTP.LastStep)
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid code"})
+		return
+	}
+	codes, ok := uh.enableTOTP(c, user, step)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"recoveryCodes": codes})
+}
+
+func (uh *UserHandler) enableTOTP(c *gin.Context, user models.User, step int64) ([]string, bool) {
+	codes, err := secutiry.NewRecoveryCodes(recoveryCodeCount)
+	if err != nil {
+		uh.logger.Error(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return nil, false
+	}
+	hashed := make([]string, len(codes))
+	for i, code := range codes {
+		if hashed[i], err = uh.hasher.Hash(code); err != nil {
+			uh.logger.Error(err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return nil, false
+		}
+	}
+	err = uh.dbHan.SetTOTP(c.Request.Context(), user.Email, models.TOTP{
+		Secret:        user.TOTP.Secret,
+		Enabled:       true,
+		RecoveryCodes: hashed,
+		LastStep:      step,
+	})
+	if err != nil {
+		uh.logger.Error(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return nil, false
+	}
+	uh.rabbit.Publish(models.Event{EventType: "TOTPEnabled", UserID: user.Email})
+	return codes, true
+}
+
+func (uh *UserHandler) DisableTOTP(c *gin.Context) {
+	id := c.Param("id")
+	var req models.TOTPCodeRequest
+	if !secutiry.IsAdmin(c) {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+	user, err := uh.dbHan.GetUser(c.Request.Context(), id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		uh.logger.Error(err)
+		return
+	}
+	if !secutiry.IsAdmin(c) {
+		if _, ok := secutiry.ValidateTOTP(user.TOTP.Secret, req.Code, time.Now(), user.TOTP.LastStep); !ok {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid code"})
+			return
+		}
+	}
+	if err = uh.dbHan.SetTOTP(c.Request.Context(), id, models.TOTP{}); err != nil {
+		uh.logger.Error(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	uh.rabbit.Publish(models.Event{EventType: "TOTPDisabled", UserID: id})
+	c.JSON(http.StatusOK, gin.H{"message": "2fa disabled"})
+}
+
+func (uh *UserHandler) LoginTOTP(c *gin.Context) {
+	var req models.LoginTOTPRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if (req.Code == "") == (req.RecoveryCode == "") {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "exactly one of code or recoveryCode is required"})
+		return
+	}
+	now := time.Now()
+	ip := c.ClientIP()
+	if until, locked := uh.ipAttempts.LockedUntil(ip, now); locked {
+		retryAfter(c, until, now)
+		c.JSON(http.StatusTooManyRequests, gin.H{"error": "too many failed login attempts"})
+		return
+	}
+	challenge, err := uh.consumeToken(c.Request.Context(), req.Challenge, models.TokenMFAChallenge)
+	if errors.Is(err, dbhandler.ErrNotFound) {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid challenge"})
+		return
+	}
+	if err != nil {
+		uh.logger.Error(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	user, err := uh.dbHan.GetUser(c.Request.Context(), challenge.UserID)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid challenge"})
+		uh.logger.Error(err)
+		return
+	}
+	if user.Lockout.LockedUntil.After(now) {
+		retryAfter(c, user.Lockout.LockedUntil, now)
+		c.JSON(http.StatusLocked, gin.H{"error": "account locked"})
+		return
+	}
+	var ok bool
+	if req.Code != "" {
+		ok = uh.useTOTPCode(c, user, req.Code, now)
+	} else {
+		ok = uh.useRecoveryCode(c, user, req.RecoveryCode)
+	}
+	if !ok {
+		uh.loginFailedFromIP(ip, user.Email, now)
+		uh.loginFailed(c, user, ip, now)
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid code"})
+		return
+	}
+	if user.Lockout != (models.Lockout{}) {
+		if err = uh.dbHan.SetLockout(c.Request.Context(), user.Email, models.Lockout{}); err != nil {
+			uh.logger.Error(err)
+		}
+	}
+	uh.issueTokens(c, user, "")
+}
+
+func (uh *UserHandler) LoginTOTPEnroll(c *gin.Context) {
+	var req models.LoginTOTPEnrollRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	now := time.Now()
+	ip := c.ClientIP()
+	if until, locked := uh.ipAttempts.LockedUntil(ip, now); locked {
+		retryAfter(c, until, now)
+		c.JSON(http.StatusTooManyRequests, gin.H{"error": "too many failed login attempts"})
+		return
+	}
+	challenge, err := uh.consumeToken(c.Request.Context(), req.Challenge, models.TokenMFAEnrollment)
+	if errors.Is(err, dbhandler.ErrNotFound) {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid challenge"})
+		return
+	}
+	if err != nil {
+		uh.logger.Error(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	user, err := uh.dbHan.GetUser(c.Request.Context(), challenge.UserID)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid challenge"})
+		uh.logger.Error(err)
+		return
+	}
+	if user.Lockout.LockedUntil.After(now) {
+		retryAfter(c, user.Lockout.LockedUntil, now)
+		c.JSON(http.StatusLocked, gin.H{"error": "account locked"})
+		return
+	}
+	if user.TOTP.Enabled {
+		c.JSON(http.StatusConflict, gin.H{"error": "2fa already enabled"})
+		return
+	}
+	step, ok := secutiry.ValidateTOTP(user.TOTP.Secret, req.Code, now, user.TOTP.LastStep)
+	if !ok {
+		uh.loginFailedFromIP(ip, user.Email, now)
+		uh.loginFailed(c, user, ip, now)
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid code"})
+		return
+	}
+	if user.Lockout != (models.Lockout{}) {
+		if err = uh.dbHan.SetLockout(c.Request.Context(), user.Email, models.Lockout{}); err != nil {
+			uh.logger.Error(err)
+		}
+	}
+	codes, ok := uh.enableTOTP(c, user, step)
+	if !ok {
+		return
+	}
+	tokens, ok := uh.newTokens(c, user, "")
+	if !ok {
+		return
+	}
+	tokens["recoveryCodes"] = codes
+	c.JSON(http.StatusOK, tokens)
+}
+
+func (uh *UserHandler) useTOTPCode(c *gin.Context, user models.User, code string, now time.Time) bool {
+	step, ok := secutiry.ValidateTOTP(user.TOTP.Secret, code, now, user.TOTP.LastStep)
+	if !ok {
+		return false
+	}
+	err := uh.dbHan.UseTOTPStep(c.Request.Context(), user.Email, step)
+	if err != nil && !errors.Is(err, dbhandler.ErrNotFound) {
+		uh.logger.Error(err)
+	}
+	return err == nil
+}
+
+func (uh *UserHandler) useRecoveryCode(c *gin.Context, user models.User, code string) bool {
+	i := slices.IndexFunc(user.TOTP.RecoveryCodes, func(stored string) bool {
+		return uh.verifyRecoveryCode(stored, code)
+	})
+	if i < 0 {
+		return false
+	}
+	totp := user.TOTP
+	totp.RecoveryCodes = slices.Delete(slices.Clone(totp.RecoveryCodes), i, i+1)
+	if err := uh.dbHan.SetTOTP(c.Request.Context(), user.Email, totp); err != nil {
+		uh.logger.Error(err)
+		return false
+	}
+	return true
+}
+
+func (uh *UserHandler) verifyRecoveryCode(stored string, code string) bool {
+	if !strings.HasPrefix(stored, "$") {
+		return subtle.ConstantTimeCompare([]byte(stored), []byte(secutiry.HashToken(code))) == 1
+	}
+	ok, _, err := uh.hasher.Verify(stored, code)
+	if err != nil {
+		uh.logger.Error(err)
+	}
+	return ok
+}
+
+func (uh *UserHandler) mfaChallenge(c *gin.Context, user models.User) {
+	challenge, _, err := uh.saveToken(c.Request.Context(), models.TokenMFAChallenge, user.Email, secutiry.MFAChallengeTTL)
+	if err != nil {
+		uh.logger.Error(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"mfaRequired": true,
+		"challenge":   challenge,
+		"expiresIn":   int(secutiry.MFAChallengeTTL.Seconds()),
+	})
+}
+
+func mfaEnrollmentRequired(user models.User) bool {
+	return user.Role == models.RoleAdmin && !user.TOTP.Enabled
+}
+
+func (uh *UserHandler) mfaEnrollment(c *gin.Context, user models.User) {
+	secret, err := secutiry.NewTOTPSecret()
+	if err != nil {
+		uh.logger.Error(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if err = uh.dbHan.SetTOTP(c.Request.Context(), user.Email, models.TOTP{Secret: secret}); err != nil {
+		uh.logger.Error(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	challenge, _, err := uh.saveToken(c.Request.Context(), models.TokenMFAEnrollment, user.Email, secutiry.MFAChallengeTTL)
+	if err != nil {
+		uh.logger.Error(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"mfaEnrollmentRequired": true,
+		"challenge":             challenge,
+		"secret":                secret,
+		"uri":                   secutiry.TOTPURI(totpIssuer, user.Email, secret),
+		"expiresIn":             int(secutiry.MFAChallengeTTL.Seconds()),
+	})
+}
@@ -0,0 +1,405 @@
+package user
+
+import (
+	"UserStorage/dbhandler"
+	"UserStorage/models"
+	"UserStorage/queueHandler"
+	"UserStorage/secutiry"
+	"encoding/json"
+	"github.com/golang/mock/gomock"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/bcrypt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+type msgChallenge struct {
+	MFARequired bool   `json:"mfaRequired"`
+	Challenge   string `json:"challenge"`
+	Token       string `json:"token"`
+}
+
+func TestLoginWithTOTPReturnsChallenge(t *testing.T) {
+	w := httptest.NewRecorder()
+	ctx := GetTestGinContext(w)
+	var logger = logrus.New()
+	MockJsonPost(ctx, models.LoginRequest{Username: "test@test.pl", Password: "test"}, "")
+	ctrl := gomock.NewController(t)
+	testDB := dbhandler.NewMockDBHandler(ctrl)
+	testMQ := queueHandler.NewMockQueueHandler(ctrl)
+	auth := secutiry.NewAuthObj([]byte("test"))
+	hash, err := bcrypt.GenerateFromPassword([]byte("test"), bcrypt.DefaultCost)
+	assert.NoError(t, err)
+	testDB.EXPECT().GetUser(gomock.Any(), "test@test.pl").Return(models.User{
+		Email:    "test@test.pl",
+		Password: string(hash),
+		TOTP:     models.TOTP{Secret: "JBSWY3DPEHPK3PXP", Enabled: true},
+	}, nil)
+	testDB.EXPECT().SaveToken(gomock.Any(), gomock.Any()).DoAndReturn(func(_ any, token models.Token) error {
+		assert.Equal(t, token.Kind, models.TokenMFAChallenge)
+		assert.Equal(t, token.UserID, "test@test.pl")
+		return nil
+	})
+	testObj := NewUserHandler(logger, testDB, testMQ, auth)
+	testObj.Login(ctx)
+	assert.Equal(t, w.Code, http.StatusOK)
+	var out msgChallenge
+	err = json.NewDecoder(w.Body).Decode(&out)
+	assert.NoError(t, err)
+	assert.True(t, out.MFARequired)
+	assert.NotEmpty(t, out.Challenge)
+	assert.Empty(t, out.Token)
+}
+
+func TestLoginTOTP(t *testing.T) {
+	w := httptest.NewRecorder()
+	ctx := GetTestGinContext(w)
+	var logger = logrus.New()
+	secret, err := secutiry.NewTOTPSecret()
+	assert.NoError(t, err)
+	code, err := secutiry.TOTPCode(secret, time.Now())
+	assert.NoError(t, err)
+	MockJsonPost(ctx, models.LoginTOTPRequest{Challenge: "challenge", Code: code}, "")
+	ctrl := gomock.NewController(t)
+	testDB := dbhandler.NewMockDBHandler(ctrl)
+	testMQ := queueHandler.NewMockQueueHandler(ctrl)
+	auth := secutiry.NewAuthObj([]byte("test"))
+	testDB.EXPECT().ConsumeToken(gomock.Any(), secutiry.HashToken("challenge"), models.TokenMFAChallenge).Return(models.Token{
+		UserID:    "test@test.pl",
+		ExpiresAt: time.Now().Add(time.Minute),
+	}, nil)
+	testDB.EXPECT().GetUser(gomock.Any(), "test@test.pl").Return(models.User{
+		Email: "test@test.pl",
+		Role:  models.RoleAdmin,
+		TOTP:  models.TOTP{Secret: secret, Enabled: true},
+	}, nil)
+	testDB.EXPECT().UseTOTPStep(gomock.Any(), "test@test.pl", gomock.Any()).Return(nil)
+	testDB.EXPECT().SaveToken(gomock.Any(), gomock.Any()).Return(nil)
+	testObj := NewUserHandler(logger, testDB, testMQ, auth)
+	testObj.LoginTOTP(ctx)
+	assert.Equal(t, w.Code, http.StatusOK)
+	var out msgTokens
+	err = json.NewDecoder(w.Body).Decode(&out)
+	assert.NoError(t, err)
+	claims, err := auth.ValidateToken(out.Token)
+	assert.NoError(t, err)
+	assert.Equal(t, claims.Role, models.RoleAdmin)
+}
+
+func TestLoginTOTPWrongCode(t *testing.T) {
+	w := httptest.NewRecorder()
+	ctx := GetTestGinContext(w)
+	var logger = logrus.New()
+	MockJsonPost(ctx, models.LoginTOTPRequest{Challenge: "challenge", Code: "000000"}, "")
+	ctrl := gomock.NewController(t)
+	testDB := dbhandler.NewMockDBHandler(ctrl)
+	testMQ := queueHandler.NewMockQueueHandler(ctrl)
+	auth := secutiry.NewAuthObj([]byte("test"))
+	testDB.EXPECT().ConsumeToken(gomock.Any(), gomock.Any(), models.TokenMFAChallenge).Return(models.Token{
+		UserID:    "test@test.pl",
+		ExpiresAt: time.Now().Add(time.Minute),
+	}, nil)
+	testDB.EXPECT().GetUser(gomock.Any(), "test@test.pl").Return(models.User{
+		Email: "test@test.pl",
+		TOTP:  models.TOTP{Secret: "JBSWY3DPEHPK3PXP", Enabled: true},
+	}, nil)
+	testDB.EXPECT().RecordFailedLogin(gomock.Any(), "test@test.pl").Return(models.Lockout{FailedAttempts: 1}, nil)
+	testObj := NewUserHandler(logger, testDB, testMQ, auth)
+	testObj.LoginTOTP(ctx)
+	assert.Equal(t, w.Code, http.StatusUnauthorized)
+	msgErr := msgErr{}
+	err := json.NewDecoder(w.Body).Decode(&msgErr)
+	assert.NoError(t, err)
+	assert.Equal(t, msgErr.Err, "invalid code")
+}
+
+func TestLoginTOTPReplayedCode(t *testing.T) {
+	w := httptest.NewRecorder()
+	ctx := GetTestGinContext(w)
+	var logger = logrus.New()
+	secret, err := secutiry.NewTOTPSecret()
+	assert.NoError(t, err)
+	now := time.Now()
+	code, err := secutiry.TOTPCode(secret, now)
+	assert.NoError(t, err)
+	MockJsonPost(ctx, models.LoginTOTPRequest{Challenge: "challenge", Code: code}, "")
+	ctrl := gomock.NewController(t)
+	testDB := dbhandler.NewMockDBHandler(ctrl)
+	testMQ := queueHandler.NewMockQueueHandler(ctrl)
+	auth := secutiry.NewAuthObj([]byte("test"))
+	testDB.EXPECT().ConsumeToken(gomock.Any(), gomock.Any(), models.TokenMFAChallenge).Return(models.Token{
+		UserID:    "test@test.pl",
+		ExpiresAt: time.Now().Add(time.Minute),
+	}, nil)
+	testDB.EXPECT().GetUser(gomock.Any(), "test@test.pl").Return(models.User{
+		Email: "test@test.pl",
+		TOTP:  models.TOTP{Secret: secret, Enabled: true, LastStep: now.Unix() / 30},
+	}, nil)
+	testDB.EXPECT().RecordFailedLogin(gomock.Any(), "test@test.pl").Return(models.Lockout{FailedAttempts: 1}, nil)
+	testObj := NewUserHandler(logger, testDB, testMQ, auth)
+	testObj.LoginTOTP(ctx)
+	assert.Equal(t, w.Code, http.StatusUnauthorized)
+}
+
+func TestLoginTOTPConcurrentReplay(t *testing.T) {
+	w := httptest.NewRecorder()
+	ctx := GetTestGinContext(w)
+	var logger = logrus.New()
+	secret, err := secutiry.NewTOTPSecret()
+	assert.NoError(t, err)
+	code, err := secutiry.TOTPCode(secret, time.Now())
+	assert.NoError(t, err)
+	MockJsonPost(ctx, models.LoginTOTPRequest{Challenge: "challenge", Code: code}, "")
+	ctrl := gomock.NewController(t)
+	testDB := dbhandler.NewMockDBHandler(ctrl)
+	testMQ := queueHandler.NewMockQueueHandler(ctrl)
+	auth := secutiry.NewAuthObj([]byte("test"))
+	testDB.EXPECT().ConsumeToken(gomock.Any(), gomock.Any(), models.TokenMFAChallenge).Return(models.Token{
+		UserID:    "test@test.pl",
+		ExpiresAt: time.Now().Add(time.Minute),
+	}, nil)
+	testDB.EXPECT().GetUser(gomock.Any(), "test@test.pl").Return(models.User{
+		Email: "test@test.pl",
+		TOTP:  models.TOTP{Secret: secret, Enabled: true},
+	}, nil)
+	testDB.EXPECT().UseTOTPStep(gomock.Any(), "test@test.pl", gomock.Any()).Return(dbhandler.ErrNotFound)
+	testDB.EXPECT().RecordFailedLogin(gomock.Any(), "test@test.pl").Return(models.Lockout{FailedAttempts: 1}, nil)
+	testObj := NewUserHandler(logger, testDB, testMQ, auth)
+	testObj.LoginTOTP(ctx)
+	assert.Equal(t, w.Code, http.StatusUnauthorized)
+}
+
+func TestLoginAdminWithoutTOTPRequiresEnrollment(t *testing.T) {
+	w := httptest.NewRecorder()
+	ctx := GetTestGinContext(w)
+	var logger = logrus.New()
+	MockJsonPost(ctx, models.LoginRequest{Username: "admin@test.pl", Password: "test"}, "")
+	ctrl := gomock.NewController(t)
+	testDB := dbhandler.NewMockDBHandler(ctrl)
+	testMQ := queueHandler.NewMockQueueHandler(ctrl)
+	auth := secutiry.NewAuthObj([]byte("test"))
+	hash, err := bcrypt.GenerateFromPassword([]byte("test"), bcrypt.DefaultCost)
+	assert.NoError(t, err)
+	testDB.EXPECT().GetUser(gomock.Any(), "admin@test.pl").Return(models.User{
+		Email:    "admin@test.pl",
+		Password: string(hash),
+		Role:     models.RoleAdmin,
+	}, nil)
+	testDB.EXPECT().SetTOTP(gomock.Any(), "admin@test.pl", gomock.Any()).DoAndReturn(func(_ any, _ string, totp models.TOTP) error {
+		assert.NotEmpty(t, totp.Secret)
+		assert.False(t, totp.Enabled)
+		return nil
+	})
+	testDB.EXPECT().SaveToken(gomock.Any(), gomock.Any()).DoAndReturn(func(_ any, token models.Token) error {
+		assert.Equal(t, token.Kind, models.TokenMFAEnrollment)
+		return nil
+	})
+	testObj := NewUserHandler(logger, testDB, testMQ, auth)
+	testObj.Login(ctx)
+	assert.Equal(t, w.Code, http.StatusOK)
+	var out struct {
+		MFAEnrollmentRequired bool   `json:"mfaEnrollmentRequired"`
+		Challenge             string `json:"challenge"`
+		Secret                string `json:"secret"`
+		Token                 string `json:"token"`
+	}
+	err = json.NewDecoder(w.Body).Decode(&out)
+	assert.NoError(t, err)
+	assert.True(t, out.MFAEnrollmentRequired)
+	assert.NotEmpty(t, out.Challenge)
+	assert.NotEmpty(t, out.Secret)
+	assert.Empty(t, out.Token)
+}
+
+func TestLoginTOTPEnroll(t *testing.T) {
+	w := httptest.NewRecorder()
+	ctx := GetTestGinContext(w)
+	var logger = logrus.New()
+	secret, err := secutiry.NewTOTPSecret()
+	assert.NoError(t, err)
+	code, err := secutiry.TOTPCode(secret, time.Now())
+	assert.NoError(t, err)
+	MockJsonPost(ctx, models.LoginTOTPEnrollRequest{Challenge: "challenge", Code: code}, "")
+	ctrl := gomock.NewController(t)
+	testDB := dbhandler.NewMockDBHandler(ctrl)
+	testMQ := queueHandler.NewMockQueueHandler(ctrl)
+	auth := secutiry.NewAuthObj([]byte("test"))
+	testDB.EXPECT().ConsumeToken(gomock.Any(), secutiry.HashToken("challenge"), models.TokenMFAEnrollment).Return(models.Token{
+		UserID:    "admin@test.pl",
+		ExpiresAt: time.Now().Add(time.Minute),
+	}, nil)
+	testDB.EXPECT().GetUser(gomock.Any(), "admin@test.pl").Return(models.User{
+		Email: "admin@test.pl",
+		Role:  models.RoleAdmin,
+		TOTP:  models.TOTP{Secret: secret},
+	}, nil)
+	var stored models.TOTP
+	testDB.EXPECT().SetTOTP(gomock.Any(), "admin@test.pl", gomock.Any()).DoAndReturn(func(_ any, _ string, totp models.TOTP) error {
+		stored = totp
+		return nil
+	})
+	testMQ.EXPECT().Publish(gomock.Any())
+	testDB.EXPECT().SaveToken(gomock.Any(), gomock.Any()).Return(nil)
+	testObj := NewUserHandler(logger, testDB, testMQ, auth)
+	testObj.LoginTOTPEnroll(ctx)
+	assert.Equal(t, w.Code, http.StatusOK)
+	var out struct {
+		Token         string   `json:"token"`
+		RecoveryCodes []string `json:"recoveryCodes"`
+	}
+	err = json.NewDecoder(w.Body).Decode(&out)
+	assert.NoError(t, err)
+	assert.NotEmpty(t, out.Token)
+	assert.Len(t, out.RecoveryCodes, recoveryCodeCount)
+	assert.True(t, stored.Enabled)
+	assert.NotZero(t, stored.LastStep)
+}
+
+func TestRefreshAdminWithoutTOTP(t *testing.T) {
+	w := httptest.NewRecorder()
+	ctx := GetTestGinContext(w)
+	var logger = logrus.New()
+	MockJsonPost(ctx, models.RefreshRequest{RefreshToken: "refresh"}, "")
+	ctrl := gomock.NewController(t)
+	testDB := dbhandler.NewMockDBHandler(ctrl)
+	testMQ := queueHandler.NewMockQueueHandler(ctrl)
+	auth := secutiry.NewAuthObj([]byte("test"))
+	testDB.EXPECT().ConsumeToken(gomock.Any(), secutiry.HashToken("refresh"), models.TokenRefresh).Return(models.Token{
+		UserID:    "admin@test.pl",
+		ExpiresAt: time.Now().Add(time.Minute),
+	}, nil)
+	testDB.EXPECT().GetUser(gomock.Any(), "admin@test.pl").Return(models.User{Email: "admin@test.pl", Role: models.RoleAdmin}, nil)
+	testObj := NewUserHandler(logger, testDB, testMQ, auth)
+	testObj.Refresh(ctx)
+	assert.Equal(t, w.Code, http.StatusForbidden)
+}
+
+func TestLoginTOTPRecoveryCode(t *testing.T) {
+	hasher := secutiry.PasswordHasher{Algorithm: secutiry.AlgorithmBcrypt, BcryptCost: bcrypt.MinCost}
+	first, err := hasher.Hash("1234-5678")
+	assert.NoError(t, err)
+	second, err := hasher.Hash("abcd-efgh")
+	assert.NoError(t, err)
+	for name, codes := range map[string][]string{
+		"hashed": {first, second},
+		"legacy": {secutiry.HashToken("1234-5678"), secutiry.HashToken("abcd-efgh")},
+	} {
+		t.Run(name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			ctx := GetTestGinContext(w)
+			var logger = logrus.New()
+			MockJsonPost(ctx, models.LoginTOTPRequest{Challenge: "challenge", RecoveryCode: "abcd-efgh"}, "")
+			ctrl := gomock.NewController(t)
+			testDB := dbhandler.NewMockDBHandler(ctrl)
+			testMQ := queueHandler.NewMockQueueHandler(ctrl)
+			auth := secutiry.NewAuthObj([]byte("test"))
+			totp := models.TOTP{
+				Secret:        "JBSWY3DPEHPK3PXP",
+				Enabled:       true,
+				RecoveryCodes: codes,
+			}
+			testDB.EXPECT().ConsumeToken(gomock.Any(), gomock.Any(), models.TokenMFAChallenge).Return(models.Token{
+				UserID:    "test@test.pl",
+				ExpiresAt: time.Now().Add(time.Minute),
+			}, nil)
+			testDB.EXPECT().GetUser(gomock.Any(), "test@test.pl").Return(models.User{Email: "test@test.pl", TOTP: totp}, nil)
+			testDB.EXPECT().SetTOTP(gomock.Any(), "test@test.pl", models.TOTP{
+				Secret:        "JBSWY3DPEHPK3PXP",
+				Enabled:       true,
+				RecoveryCodes: codes[:1],
+			}).Return(nil)
+			testDB.EXPECT().SaveToken(gomock.Any(), gomock.Any()).Return(nil)
+			testObj := NewUserHandler(logger, testDB, testMQ, auth)
+			testObj.LoginTOTP(ctx)
+			assert.Equal(t, w.Code, http.StatusOK)
+		})
+	}
+}
+
+func TestEnrollTOTP(t *testing.T) {
+	w := httptest.NewRecorder()
+	ctx := GetTestGinContext(w)
+	var logger = logrus.New()
+	MockJsonPost(ctx, nil, "test@test.pl")
+	ctrl := gomock.NewController(t)
+	testDB := dbhandler.NewMockDBHandler(ctrl)
+	testMQ := queueHandler.NewMockQueueHandler(ctrl)
+	auth := secutiry.NewAuthObj([]byte("test"))
+	testDB.EXPECT().GetUser(gomock.Any(), "test@test.pl").Return(models.User{Email: "test@test.pl"}, nil)
+	testDB.EXPECT().SetTOTP(gomock.Any(), "test@test.pl", gomock.Any()).DoAndReturn(func(_ any, _ string, totp models.TOTP) error {
+		assert.NotEmpty(t, totp.Secret)
+		assert.False(t, totp.Enabled)
+		return nil
+	})
+	testObj := NewUserHandler(logger, testDB, testMQ, auth)
+	testObj.EnrollTOTP(ctx)
+	assert.Equal(t, w.Code, http.StatusOK)
+	var out struct {
+		Secret string `json:"secret"`
+		URI    string `json:"uri"`
+	}
+	err := json.NewDecoder(w.Body).Decode(&out)
+	assert.NoError(t, err)
+	assert.NotEmpty(t, out.Secret)
+	assert.Contains(t, out.URI, "otpauth://totp/")
+}
+
+func TestConfirmTOTP(t *testing.T) {
+	w := httptest.NewRecorder()
+	ctx := GetTestGinContext(w)
+	var logger = logrus.New()
+	secret, err := secutiry.NewTOTPSecret()
+	assert.NoError(t, err)
+	code, err := secutiry.TOTPCode(secret, time.Now())
+	assert.NoError(t, err)
+	MockJsonPost(ctx, models.TOTPCodeRequest{Code: code}, "test@test.pl")
+	ctrl := gomock.NewController(t)
+	testDB := dbhandler.NewMockDBHandler(ctrl)
+	testMQ := queueHandler.NewMockQueueHandler(ctrl)
+	auth := secutiry.NewAuthObj([]byte("test"))
+	testDB.EXPECT().GetUser(gomock.Any(), "test@test.pl").Return(models.User{Email: "test@test.pl", TOTP: models.TOTP{Secret: secret}}, nil)
+	var stored models.TOTP
+	testDB.EXPECT().SetTOTP(gomock.Any(), "test@test.pl", gomock.Any()).DoAndReturn(func(_ any, _ string, totp models.TOTP) error {
+		stored = totp
+		return nil
+	})
+	testMQ.EXPECT().Publish(gomock.Any())
+	testObj := NewUserHandler(logger, testDB, testMQ, auth)
+	testObj.ConfirmTOTP(ctx)
+	assert.Equal(t, w.Code, http.StatusOK)
+	var out struct {
+		RecoveryCodes []string `json:"recoveryCodes"`
+	}
+	err = json.NewDecoder(w.Body).Decode(&out)
+	assert.NoError(t, err)
+	assert.Len(t, out.RecoveryCodes, recoveryCodeCount)
+	assert.True(t, stored.Enabled)
+	assert.NotZero(t, stored.LastStep)
+	assert.NotEqual(t, stored.RecoveryCodes[0], secutiry.HashToken(out.RecoveryCodes[0]))
+	ok, _, err := secutiry.PasswordHasher{}.Verify(stored.RecoveryCodes[0], out.RecoveryCodes[0])
+	assert.NoError(t, err)
+	assert.True(t, ok)
+}
+
+func TestDisableTOTPRequiresCode(t *testing.T) {
+	w := httptest.NewRecorder()
+	ctx := GetTestGinContext(w)
+	var logger = logrus.New()
+	MockJsonPost(ctx, models.TOTPCodeRequest{Code: "000000"}, "test@test.pl")
+	ctx.Set(secutiry.ContextSubject, "test@test.pl")
+	ctx.Set(secutiry.ContextRole, models.RoleUser)
+	ctrl := gomock.NewController(t)
+	testDB := dbhandler.NewMockDBHandler(ctrl)
+	testMQ := queueHandler.NewMockQueueHandler(ctrl)
+	auth := secutiry.NewAuthObj([]byte("test"))
+	testDB.EXPECT().GetUser(gomock.Any(), "test@test.pl").Return(models.User{
+		Email: "test@test.pl",
+		TOTP:  models.TOTP{Secret: "JBSWY3DPEHPK3PXP", Enabled: true},
+	}, nil)
+	testObj := NewUserHandler(logger, testDB, testMQ, auth)
+	testObj.DisableTOTP(ctx)
+	assert.Equal(t, w.Code, http.StatusBadRequest)
+}
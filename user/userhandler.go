@@ -3,133 +3,351 @@ package user
 import (
 	"UserStorage/dbhandler"
 	"UserStorage/models"
+	"UserStorage/oidc"
 	"UserStorage/queueHandler"
 	"UserStorage/secutiry"
+	"UserStorage/storage"
+	"context"
+	"errors"
 	"github.com/gin-gonic/gin"
 	"github.com/sirupsen/logrus"
 	"golang.org/x/crypto/bcrypt"
 	"net/http"
+	"strings"
+	"time"
 )
 
 type UserHandler struct {
-	logger *logrus.Logger
-	dbHan  dbhandler.DBHandler
-	rabbit queueHandler.QueueHandler
-	auth   *secutiry.AuthObj
+	logger         *logrus.Logger
+	dbHan          dbhandler.DBHandler
+	rabbit         queueHandler.QueueHandler
+	auth           *secutiry.AuthObj
+	accountLockout secutiry.LockoutPolicy
+	ipAttempts     *secutiry.AttemptTracker
+	passwordPolicy secutiry.PasswordPolicy
+	hasher         secutiry.PasswordHasher
+	oidc           *oidc.Client
+	auditLog       dbhandler.AuditStore
+	blobs          storage.BlobStore
+	maxUploadSize  int64
 }
 
-func NewUserHandler(logger *logrus.Logger, client dbhandler.DBHandler, han queueHandler.QueueHandler, auth *secutiry.AuthObj) *UserHandler {
-	return &UserHandler{logger, client, han, auth}
+func NewUserHandler(logger *logrus.Logger, client dbhandler.DBHandler, han queueHandler.QueueHandler, auth *secutiry.AuthObj, opts ...Option) *UserHandler {
+	uh := &UserHandler{
+		logger:        logger,
+		dbHan:         client,
+		rabbit:        han,
+		auth:          auth,
+		maxUploadSize: DefaultMaxUploadSize,
+		hasher: secutiry.PasswordHasher{
+			Algorithm:  secutiry.AlgorithmBcrypt,
+			BcryptCost: bcrypt.DefaultCost,
+			Argon2:     secutiry.DefaultArgon2Params,
+		},
+	}
+	WithLockoutPolicy(
+		secutiry.LockoutPolicy{Threshold: 5, BaseDelay: time.Minute, MaxDelay: time.Hour},
+		secutiry.LockoutPolicy{Threshold: 20, BaseDelay: time.Minute, MaxDelay: time.Hour},
+	)(uh)
+	for _, opt := range opts {
+		opt(uh)
+	}
+	return uh
 }
 
 func (uh *UserHandler) CreateUser(c *gin.Context) {
+	input, ok := uh.createUser(c, false)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusCreated, input)
+}
 
-	var input models.User
-	if err := c.ShouldBindJSON(&input); err != nil {
+func (uh *UserHandler) createUser(c *gin.Context, selfService bool) (models.User, bool) {
+	var req models.NewUserRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		uh.logger.Error(err)
-		return
+		return models.User{}, false
 	}
-	if input.Age < 18 {
-		uh.logger.Error("User age is less than 18")
-		c.JSON(http.StatusBadRequest, gin.H{"error": "User age is less than 18"})
-		return
+	input, err := uh.validateNewUser(req, selfService)
+	if err != nil {
+		uh.logger.Error(err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return models.User{}, false
 	}
-	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(input.Password), bcrypt.DefaultCost)
+	hashedPassword, err := uh.hasher.Hash(req.Password)
 	if err != nil {
 		uh.logger.Error(err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return models.User{}, false
 	}
-	input.Password = string(hashedPassword)
+	input.Password = hashedPassword
 	err = uh.dbHan.CreateUser(c.Request.Context(), input)
+	if errors.Is(err, dbhandler.ErrDuplicate) {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return models.User{}, false
+	}
 	if err != nil {
 		uh.logger.Error(err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-		return
+		return models.User{}, false
 	}
 	uh.rabbit.Publish(models.Event{EventType: "UserCreated", UserID: input.Email, Age: input.Age, NoFiles: len(input.Files)})
-	c.JSON(http.StatusCreated, input)
+	uh.audit(c, models.AuditUserCreated, input.Email, nil, &input)
+	return input, true
+}
+
+func (uh *UserHandler) validateNewUser(req models.NewUserRequest, selfService bool) (models.User, error) {
+	input := models.User{
+		Email:    req.Email,
+		Username: req.Username,
+		Age:      req.Age,
+		Role:     req.Role,
+	}
+	if input.Email == "" {
+		return models.User{}, errors.New("User email is empty")
+	}
+	if strings.HasPrefix(input.Email, models.ServiceAccountPrefix) {
+		return models.User{}, errors.New("User email is reserved for service accounts")
+	}
+	if input.Age < 18 {
+		return models.User{}, errors.New("User age is less than 18")
+	}
+	if selfService || input.Role == "" {
+		input.Role = models.RoleUser
+	}
+	if !models.ValidRole(input.Role) {
+		return models.User{}, errors.New("Invalid user role")
+	}
+	if err := uh.validatePassword(req.Password); err != nil {
+		return models.User{}, err
+	}
+	input.Status = models.UserStatusActive
+	if selfService {
+		input.Status = models.UserStatusPending
+	}
+	return input, nil
 }
 
 func (uh *UserHandler) GetUser(c *gin.Context) {
 	id := c.Param("id")
+	includeDeleted, err := optionalBool(c, "includeDeleted")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
 
-	usr, err := uh.dbHan.GetUser(c.Request.Context(), id)
+	asOf, err := optionalTime(c, "asOf")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	withDeleted := includeDeleted != nil && *includeDeleted && secutiry.IsAdmin(c)
+
+	var usr models.User
+	switch {
+	case !asOf.IsZero():
+		var revision models.UserRevision
+		revision, err = uh.dbHan.GetUserAsOf(c.Request.Context(), id, asOf)
+		usr = revision.User
+		if err == nil && usr.DeletedAt != nil && !withDeleted {
+			err = dbhandler.ErrNotFound
+		}
+	case withDeleted:
+		usr, err = uh.dbHan.GetUserIncludingDeleted(c.Request.Context(), id)
+	default:
+		usr, err = uh.dbHan.GetUser(c.Request.Context(), id)
+	}
 	if err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
 		uh.logger.Error(err)
 		return
 	}
-
+	c.Header("ETag", etag(usr.Version))
+	if ifNoneMatch := c.GetHeader("If-None-Match"); ifNoneMatch != "" && etagMatches(ifNoneMatch, usr.Version, true) {
+		c.Status(http.StatusNotModified)
+		return
+	}
 	c.JSON(http.StatusOK, usr)
 }
 
 func (uh *UserHandler) UpdateUser(c *gin.Context) {
 	id := c.Param("id")
-	updUsr := models.User{}
-	err := c.ShouldBindJSON(&updUsr)
+	var upd models.UserUpdate
+	if err := c.ShouldBindJSON(&upd); err != nil {
+		uh.logger.Error(err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	existing, ok := uh.loadUser(c, id)
+	if !ok || !uh.checkPreconditions(c, existing) {
+		return
+	}
+	if !secutiry.IsAdmin(c) {
+		upd.Role = existing.Role
+	}
+	updUsr, err := replaceUser(existing, upd)
 	if err != nil {
 		uh.logger.Error(err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
-	err = uh.dbHan.UpdateUser(c.Request.Context(), updUsr)
+	uh.saveUser(c, existing, updUsr)
+}
+
+func (uh *UserHandler) loadUser(c *gin.Context, id string) (models.User, bool) {
+	usr, err := uh.dbHan.GetUser(c.Request.Context(), id)
+	if errors.Is(err, dbhandler.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return models.User{}, false
+	}
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		uh.logger.Error(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return models.User{}, false
+	}
+	return usr, true
+}
+
+func replaceUser(existing models.User, upd models.UserUpdate) (models.User, error) {
+	if upd.Age < 18 {
+		return models.User{}, errors.New("User age is less than 18")
+	}
+	if upd.Role == "" {
+		upd.Role = models.RoleUser
+	}
+	if !models.ValidRole(upd.Role) {
+		return models.User{}, errors.New("Invalid user role")
+	}
+	existing.Username = upd.Username
+	existing.Age = upd.Age
+	existing.Role = upd.Role
+	return existing, nil
+}
+
+func (uh *UserHandler) saveUser(c *gin.Context, before models.User, usr models.User) {
+	if err := uh.dbHan.UpdateUser(c.Request.Context(), usr); err != nil {
+		uh.writeFailed(c, err)
 		return
 	}
-	uh.rabbit.Publish(models.Event{EventType: "UserUpdated", UserID: id, Age: updUsr.Age, NoFiles: len(updUsr.Files)})
-	c.JSON(http.StatusOK, updUsr)
+	usr.Version++
+	if models.EffectiveRole(usr.Role) != models.EffectiveRole(before.Role) {
+		uh.revokeAccess(c.Request.Context(), usr.Email)
+	}
+	uh.rabbit.Publish(models.Event{EventType: "UserUpdated", UserID: usr.Email, Age: usr.Age, NoFiles: len(usr.Files)})
+	uh.audit(c, models.AuditUserUpdated, usr.Email, &before, &usr)
+	c.Header("ETag", etag(usr.Version))
+	c.JSON(http.StatusOK, usr)
 }
 
 func (uh *UserHandler) DeleteUser(c *gin.Context) {
 	id := c.Param("id")
-	err := uh.dbHan.DeleteUser(c.Request.Context(), id)
+	version, ok := uh.preconditionVersion(c, id)
+	if !ok {
+		return
+	}
+	before := uh.snapshot(c, id)
+	err := uh.dbHan.DeleteUser(c.Request.Context(), id, version)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		uh.writeFailed(c, err)
 		return
 	}
+	uh.revokeAccess(c.Request.Context(), id)
 	uh.rabbit.Publish(models.Event{EventType: "UserDeleted", UserID: id})
+	uh.audit(c, models.AuditUserDeleted, id, before, uh.snapshot(c, id))
 	c.JSON(http.StatusOK, gin.H{"message": "User deleted"})
 }
 
+func (uh *UserHandler) revokeAccess(ctx context.Context, id string) {
+	if err := uh.auth.ForgetUser(ctx, id); err != nil {
+		uh.logger.Error(err)
+	}
+	for _, kind := range []string{models.TokenRefresh, models.TokenPasswordReset} {
+		if err := uh.dbHan.DeleteTokens(ctx, id, kind); err != nil {
+			uh.logger.Error(err)
+		}
+	}
+}
+
 func (uh *UserHandler) GetAllUsers(c *gin.Context) {
-	users, err := uh.dbHan.GetUsers(c.Request.Context())
+	query, err := parseUserQuery(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	page, err := uh.dbHan.GetUsers(c.Request.Context(), query)
+	if errors.Is(err, dbhandler.ErrInvalidCursor) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err})
+		uh.logger.Error(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
-	c.JSON(http.StatusOK, users)
+	if page.NextCursor != "" {
+		next := *c.Request.URL
+		q := next.Query()
+		q.Set("cursor", page.NextCursor)
+		next.RawQuery = q.Encode()
+		c.Header("X-Next-Cursor", page.NextCursor)
+		c.Header("Link", "<"+next.RequestURI()+`>; rel="next"`)
+	}
+	c.JSON(http.StatusOK, page.Users)
 }
 
 func (uh *UserHandler) AddFileToUser(c *gin.Context) {
 	id := c.Param("id")
-	file := models.File{}
-	err := c.ShouldBindJSON(&file)
+	if uh.blobs != nil {
+		if c.ContentType() == gin.MIMEJSON {
+			c.JSON(http.StatusUnsupportedMediaType, gin.H{"error": "upload the file content instead of its metadata"})
+			return
+		}
+		uh.uploadFile(c, id)
+		return
+	}
+	var req models.File
+	err := c.ShouldBindJSON(&req)
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		uh.logger.Error(err)
 		return
 	}
-	err = uh.dbHan.AddFileToUser(c.Request.Context(), id, file)
+	file := models.File{Name: req.Name}
+	version, ok := uh.preconditionVersion(c, id)
+	if !ok {
+		return
+	}
+	before := uh.snapshot(c, id)
+	err = uh.dbHan.AddFileToUser(c.Request.Context(), id, file, version)
 	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
-		uh.logger.Error(err)
+		uh.writeFailed(c, err)
 		return
 	}
+	uh.audit(c, models.AuditFileAdded, id, before, uh.snapshot(c, id))
 
 	c.JSON(http.StatusOK, gin.H{"message": "file added"})
 }
 
 func (uh *UserHandler) DeleteFilesFromUser(c *gin.Context) {
 	id := c.Param("id")
-	err := uh.dbHan.DeleteFilesFromUser(c.Request.Context(), id)
+	version, ok := uh.preconditionVersion(c, id)
+	if !ok {
+		return
+	}
+	before := uh.snapshot(c, id)
+	var files []models.File
+	if uh.blobs != nil {
+		files, _ = uh.dbHan.GetUserFiles(c.Request.Context(), id)
+	}
+	err := uh.dbHan.DeleteFilesFromUser(c.Request.Context(), id, version)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-		uh.logger.Error(err)
+		uh.writeFailed(c, err)
 		return
 	}
+	uh.deleteBlobs(c.Request.Context(), id, files)
+	uh.audit(c, models.AuditFilesDeleted, id, before, uh.snapshot(c, id))
 	c.JSON(http.StatusOK, gin.H{"message": "files deleted"})
 }
 
@@ -142,24 +360,3 @@ func (uh *UserHandler) GetUserFiles(c *gin.Context) {
 	}
 	c.JSON(http.StatusOK, files)
 }
-
-func (uh *UserHandler) Login(c *gin.Context) {
-	var req models.LoginRequest
-	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
-	}
-	user, err := uh.dbHan.GetUser(c.Request.Context(), req.Username)
-	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error user not found": err.Error()})
-		uh.logger.Error(err)
-		return
-	}
-	bytesPass := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(req.Password))
-	if bytesPass != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "wrong password"})
-		uh.logger.Error(err)
-		return
-	}
-	token, err := uh.auth.CreateToken(req.Username)
-	c.JSON(http.StatusOK, gin.H{"token": token})
-}
@@ -17,6 +17,7 @@ import (
 	"net/http/httptest"
 	"net/url"
 	"testing"
+	"time"
 )
 
 func TestNewUserHandler(t *testing.T) {
@@ -94,6 +95,102 @@ func TestNewUserHandlerErrNoCreate(t *testing.T) {
 	assert.Equal(t, msgErr.Err, "user exist")
 }
 
+func TestNewUserHandlerWeakPassword(t *testing.T) {
+	w := httptest.NewRecorder()
+	ctx := GetTestGinContext(w)
+	var logger = logrus.New()
+	testUser := models.NewUserRequest{
+		Email:    "test@test.pl",
+		Username: "test",
+		Age:      21,
+		Password: "password1",
+	}
+	MockJsonPost(ctx, testUser, "")
+	ctrl := gomock.NewController(t)
+	testDB := dbhandler.NewMockDBHandler(ctrl)
+	testMQ := queueHandler.NewMockQueueHandler(ctrl)
+	auth := secutiry.NewAuthObj([]byte("test"))
+	testObj := NewUserHandler(logger, testDB, testMQ, auth, WithPasswordPolicy(secutiry.PasswordPolicy{
+		MinLength: 8,
+		DenyList:  map[string]struct{}{"password1": {}},
+	}))
+	testObj.CreateUser(ctx)
+	assert.Equal(t, w.Code, http.StatusBadRequest)
+	msgErr := msgErr{}
+	err := json.NewDecoder(w.Body).Decode(&msgErr)
+	assert.NoError(t, err)
+	assert.Equal(t, msgErr.Err, "password is too common")
+}
+
+func TestNewUserHandlerHashesPassword(t *testing.T) {
+	w := httptest.NewRecorder()
+	ctx := GetTestGinContext(w)
+	var logger = logrus.New()
+	testUser := models.NewUserRequest{
+		Email:    "test@test.pl",
+		Username: "test",
+		Age:      21,
+		Password: "correct horse",
+	}
+	MockJsonPost(ctx, testUser, "")
+	ctrl := gomock.NewController(t)
+	testDB := dbhandler.NewMockDBHandler(ctrl)
+	testMQ := queueHandler.NewMockQueueHandler(ctrl)
+	auth := secutiry.NewAuthObj([]byte("test"))
+	hasher := secutiry.PasswordHasher{Algorithm: secutiry.AlgorithmArgon2id, Argon2: secutiry.Argon2Params{
+		Memory: 1024, Iterations: 1, Parallelism: 1, SaltLength: 16, KeyLength: 32,
+	}}
+	testDB.EXPECT().CreateUser(gomock.Any(), gomock.Any()).DoAndReturn(func(_ any, usr models.User) error {
+		ok, _, err := hasher.Verify(usr.Password, "correct horse")
+		assert.NoError(t, err)
+		assert.True(t, ok)
+		return nil
+	})
+	testMQ.EXPECT().Publish(gomock.Any())
+	testObj := NewUserHandler(logger, testDB, testMQ, auth,
+		WithPasswordPolicy(secutiry.PasswordPolicy{MinLength: 8}), WithPasswordHasher(hasher))
+	testObj.CreateUser(ctx)
+	assert.Equal(t, w.Code, http.StatusCreated)
+}
+
+func TestNewUserHandlerIgnoresServerFields(t *testing.T) {
+	w := httptest.NewRecorder()
+	ctx := GetTestGinContext(w)
+	var logger = logrus.New()
+	MockJsonPost(ctx, gin.H{
+		"email":      "test@test.pl",
+		"username":   "test",
+		"age":        21,
+		"password":   "correct horse",
+		"status":     models.UserStatusPending,
+		"files":      []models.File{{ID: "other-users-blob", Name: "secret.pdf"}},
+		"totp":       gin.H{"enabled": true},
+		"lockout":    gin.H{"failedAttempts": 3},
+		"deletedAt":  time.Now(),
+		"version":    42,
+		"identities": []models.Identity{{Issuer: "https://idp.test", Subject: "victim"}},
+	}, "")
+	ctrl := gomock.NewController(t)
+	testDB := dbhandler.NewMockDBHandler(ctrl)
+	testMQ := queueHandler.NewMockQueueHandler(ctrl)
+	auth := secutiry.NewAuthObj([]byte("test"))
+	testDB.EXPECT().CreateUser(gomock.Any(), gomock.Any()).DoAndReturn(func(_ any, usr models.User) error {
+		assert.Equal(t, models.User{
+			Email:    "test@test.pl",
+			Username: "test",
+			Age:      21,
+			Password: usr.Password,
+			Status:   models.UserStatusActive,
+			Role:     models.RoleUser,
+		}, usr)
+		return nil
+	})
+	testMQ.EXPECT().Publish(gomock.Any())
+	testObj := NewUserHandler(logger, testDB, testMQ, auth)
+	testObj.CreateUser(ctx)
+	assert.Equal(t, w.Code, http.StatusCreated)
+}
+
 func TestGetUsr(t *testing.T) {
 	w := httptest.NewRecorder()
 	ctx := GetTestGinContext(w)
@@ -178,7 +275,7 @@ func TestGetAllUsers(t *testing.T) {
 	testMQ := queueHandler.NewMockQueueHandler(ctrl)
 	auth := secutiry.NewAuthObj([]byte("test"))
 	testObj := NewUserHandler(logger, testDB, testMQ, auth)
-	testDB.EXPECT().GetUsers(gomock.Any()).Return([]models.User{{
+	testDB.EXPECT().GetUsers(gomock.Any(), gomock.Any()).Return(models.UserPage{Users: []models.User{{
 		Email:    "test2@email.com",
 		Username: "test1",
 		Age:      22,
@@ -191,7 +288,7 @@ func TestGetAllUsers(t *testing.T) {
 		Password: "",
 		Files:    nil,
 	},
-	}, nil)
+	}}, nil)
 	testObj.GetAllUsers(ctx)
 	assert.Equal(t, w.Code, http.StatusOK)
 	var msgErr []models.User
@@ -216,6 +313,7 @@ func TestUpdUser(t *testing.T) {
 	testDB := dbhandler.NewMockDBHandler(ctrl)
 	testMQ := queueHandler.NewMockQueueHandler(ctrl)
 	auth := secutiry.NewAuthObj([]byte("test"))
+	testDB.EXPECT().GetUser(gomock.Any(), gomock.Any()).Return(models.User{Email: "test@test.pl", Role: models.RoleUser}, nil)
 	testDB.EXPECT().UpdateUser(gomock.Any(), gomock.Any()).Return(nil)
 	testMQ.EXPECT().Publish(gomock.Any())
 	testObj := NewUserHandler(logger, testDB, testMQ, auth)
@@ -240,6 +338,7 @@ func TestUpdUserErr(t *testing.T) {
 	testDB := dbhandler.NewMockDBHandler(ctrl)
 	testMQ := queueHandler.NewMockQueueHandler(ctrl)
 	auth := secutiry.NewAuthObj([]byte("test"))
+	testDB.EXPECT().GetUser(gomock.Any(), gomock.Any()).Return(models.User{Email: "test@test.pl", Role: models.RoleUser}, nil)
 	testDB.EXPECT().UpdateUser(gomock.Any(), gomock.Any()).Return(fmt.Errorf("user not found"))
 	testObj := NewUserHandler(logger, testDB, testMQ, auth)
 	testObj.UpdateUser(ctx)
@@ -247,6 +346,35 @@ func TestUpdUserErr(t *testing.T) {
 
 }
 
+func TestUpdUserNoRoleEscalation(t *testing.T) {
+	w := httptest.NewRecorder()
+	ctx := GetTestGinContext(w)
+	var logger = logrus.New()
+	testUser := models.User{
+		Email:    "other@test.pl",
+		Username: "test",
+		Age:      21,
+		Role:     models.RoleAdmin,
+	}
+	MockJsonPost(ctx, testUser, "test@test.pl")
+	ctx.Set(secutiry.ContextSubject, "test@test.pl")
+	ctx.Set(secutiry.ContextRole, models.RoleUser)
+	ctrl := gomock.NewController(t)
+	testDB := dbhandler.NewMockDBHandler(ctrl)
+	testMQ := queueHandler.NewMockQueueHandler(ctrl)
+	auth := secutiry.NewAuthObj([]byte("test"))
+	testDB.EXPECT().GetUser(gomock.Any(), gomock.Any()).Return(models.User{Email: "test@test.pl", Role: models.RoleUser}, nil)
+	testDB.EXPECT().UpdateUser(gomock.Any(), gomock.Any()).DoAndReturn(func(_ any, usr models.User) error {
+		assert.Equal(t, usr.Email, "test@test.pl")
+		assert.Equal(t, usr.Role, models.RoleUser)
+		return nil
+	})
+	testMQ.EXPECT().Publish(gomock.Any())
+	testObj := NewUserHandler(logger, testDB, testMQ, auth)
+	testObj.UpdateUser(ctx)
+	assert.Equal(t, w.Code, http.StatusOK)
+}
+
 func TestDeleteUsr(t *testing.T) {
 	w := httptest.NewRecorder()
 	ctx := GetTestGinContext(w)
@@ -254,10 +382,11 @@ func TestDeleteUsr(t *testing.T) {
 	MockJsonDelete(ctx, gin.Params{gin.Param{Key: "id", Value: "test@email.com"}})
 	ctrl := gomock.NewController(t)
 	testDB := dbhandler.NewMockDBHandler(ctrl)
+	expectRevokeAccess(testDB, "test@email.com")
 	testMQ := queueHandler.NewMockQueueHandler(ctrl)
 	auth := secutiry.NewAuthObj([]byte("test"))
 	testObj := NewUserHandler(logger, testDB, testMQ, auth)
-	testDB.EXPECT().DeleteUser(gomock.Any(), gomock.Any()).Return(nil)
+	testDB.EXPECT().DeleteUser(gomock.Any(), gomock.Any(), dbhandler.AnyVersion).Return(nil)
 	testMQ.EXPECT().Publish(gomock.Any())
 	testObj.DeleteUser(ctx)
 	assert.Equal(t, w.Code, http.StatusOK)
@@ -277,7 +406,7 @@ func TestDeleteUsrErr(t *testing.T) {
 	testMQ := queueHandler.NewMockQueueHandler(ctrl)
 	auth := secutiry.NewAuthObj([]byte("test"))
 	testObj := NewUserHandler(logger, testDB, testMQ, auth)
-	testDB.EXPECT().DeleteUser(gomock.Any(), gomock.Any()).Return(fmt.Errorf("user not found"))
+	testDB.EXPECT().DeleteUser(gomock.Any(), gomock.Any(), dbhandler.AnyVersion).Return(fmt.Errorf("user not found"))
 	testObj.DeleteUser(ctx)
 	assert.Equal(t, w.Code, http.StatusInternalServerError)
 	msgOut := msgErr{}
@@ -334,13 +463,13 @@ func TestAddUsrFile(t *testing.T) {
 	w := httptest.NewRecorder()
 	ctx := GetTestGinContext(w)
 	var logger = logrus.New()
-	MockJsonPost(ctx, models.File{Name: "testFile"}, "test@email.com")
+	MockJsonPost(ctx, models.File{ID: "abc123", Name: "testFile", Size: 5}, "test@email.com")
 	ctrl := gomock.NewController(t)
 	testDB := dbhandler.NewMockDBHandler(ctrl)
 	testMQ := queueHandler.NewMockQueueHandler(ctrl)
 	auth := secutiry.NewAuthObj([]byte("test"))
 	testObj := NewUserHandler(logger, testDB, testMQ, auth)
-	testDB.EXPECT().AddFileToUser(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil)
+	testDB.EXPECT().AddFileToUser(gomock.Any(), gomock.Any(), models.File{Name: "testFile"}, dbhandler.AnyVersion).Return(nil)
 	testObj.AddFileToUser(ctx)
 	assert.Equal(t, w.Code, http.StatusOK)
 	var msgErrOut msgInf
@@ -362,7 +491,7 @@ func TestDeleteUSerFiles(t *testing.T) {
 	testMQ := queueHandler.NewMockQueueHandler(ctrl)
 	auth := secutiry.NewAuthObj([]byte("test"))
 	testObj := NewUserHandler(logger, testDB, testMQ, auth)
-	testDB.EXPECT().DeleteFilesFromUser(gomock.Any(), gomock.Any()).Return(nil)
+	testDB.EXPECT().DeleteFilesFromUser(gomock.Any(), gomock.Any(), dbhandler.AnyVersion).Return(nil)
 	testObj.DeleteFilesFromUser(ctx)
 	assert.Equal(t, w.Code, http.StatusOK)
 	var msgErrOut msgInf
@@ -424,3 +553,8 @@ func MockJsonDelete(c *gin.Context, params gin.Params) {
 	c.Set("user_id", 1)
 	c.Params = params
 }
+
+func expectRevokeAccess(testDB *dbhandler.MockDBHandler, id string) {
+	testDB.EXPECT().DeleteTokens(gomock.Any(), id, models.TokenRefresh).Return(nil)
+	testDB.EXPECT().DeleteTokens(gomock.Any(), id, models.TokenPasswordReset).Return(nil)
+}